@@ -0,0 +1,96 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+func accessKeysCreateHandler(
+	aksUC usecases.AccessKeys,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		requested := &models.AccessKey{}
+		if err := unmarshalBodyTo(r, requested); err != nil {
+			l.WithError(err).Error("accessKeysCreateHandler unmarshalBodyTo")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v := requested.Validate()
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
+		ak, err := aksUC.WithContext(r.Context()).
+			Create(mux.Vars(r)["id"], requested)
+		if err != nil {
+			switch e := err.(type) {
+			case *errors.EntityNotFoundError:
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			case *errors.OAuth2ServiceAccountKeyError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			}
+			l.WithError(err).Error("accessKeysCreateHandler aksUC.Create")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		// keySecret is only ever shown here
+		WriteJSON(w, http.StatusCreated, ak)
+	}
+}
+
+func accessKeysListHandler(
+	aksUC usecases.AccessKeys,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		akSl, err := aksUC.WithContext(r.Context()).List(mux.Vars(r)["id"])
+		if err != nil {
+			if e, ok := err.(*errors.EntityNotFoundError); ok {
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			}
+			l.WithError(err).Error("accessKeysListHandler aksUC.List")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, ListResponse{
+			Count:   int64(len(akSl)),
+			Results: akSl,
+		})
+	}
+}
+
+func accessKeysDeleteHandler(
+	aksUC usecases.AccessKeys,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		keyID := mux.Vars(r)["keyId"]
+		if _, err := uuid.FromString(keyID); err != nil {
+			e := errors.NewEntityNotFoundError(models.AccessKey{}, keyID)
+			WriteBytes(w, http.StatusNotFound, e.Serialize())
+			return
+		}
+		err := aksUC.WithContext(r.Context()).
+			Deactivate(mux.Vars(r)["id"], keyID)
+		if err != nil {
+			if e, ok := err.(*errors.EntityNotFoundError); ok {
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			}
+			l.WithError(err).Error("accessKeysDeleteHandler aksUC.Deactivate")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,173 @@
+// +build integration
+
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/models"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+)
+
+func beforeEachAccessKeysHandlers(t *testing.T) {
+	t.Helper()
+	helpers.CleanupPG(t)
+}
+
+func TestAccessKeysRotation(t *testing.T) {
+	beforeEachAccessKeysHandlers(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	app := helpers.GetApp(t)
+	rootAuth := fmt.Sprintf("KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret)
+	keysPath := fmt.Sprintf("/service_accounts/%s/keys", rootSA.ID)
+
+	bts, _ := json.Marshal(map[string]interface{}{"label": "rotated"})
+	req, _ := http.NewRequest("POST", keysPath, bytes.NewBuffer(bts))
+	req.Header.Set("Authorization", rootAuth)
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201. Got %d", rec.Code)
+	}
+	created := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	newAuth := fmt.Sprintf("KeyPair %s:%s", created["keyId"], created["keySecret"])
+
+	for _, auth := range []string{rootAuth, newAuth} {
+		req, _ = http.NewRequest("GET", "/service_accounts", nil)
+		req.Header.Set("Authorization", auth)
+		rec = helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 with both keys. Got %d", rec.Code)
+		}
+	}
+
+	req, _ = http.NewRequest("GET", keysPath, nil)
+	req.Header.Set("Authorization", newAuth)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	list := struct {
+		Count   int64                    `json:"count"`
+		Results []map[string]interface{} `json:"results"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if list.Count != 2 {
+		t.Fatalf("Expected 2 keys. Got %d", list.Count)
+	}
+	var defaultKeyID string
+	for _, k := range list.Results {
+		if _, ok := k["keySecret"]; ok {
+			t.Error("Expected keySecret not to be listed")
+		}
+		if k["keyId"] == rootSA.KeyID {
+			defaultKeyID, _ = k["id"].(string)
+		}
+	}
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("%s/%s", keysPath, defaultKeyID), nil)
+	req.Header.Set("Authorization", newAuth)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204. Got %d", rec.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", rootAuth)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a deactivated key. Got %d", rec.Code)
+	}
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", newAuth)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the remaining key. Got %d", rec.Code)
+	}
+}
+
+func TestAccessKeysExpiredKeyIsRejected(t *testing.T) {
+	beforeEachAccessKeysHandlers(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	app := helpers.GetApp(t)
+
+	bts, _ := json.Marshal(map[string]interface{}{
+		"label":     "short lived",
+		"expiresAt": time.Now().Add(time.Second),
+	})
+	req, _ := http.NewRequest(
+		"POST", fmt.Sprintf("/service_accounts/%s/keys", rootSA.ID),
+		bytes.NewBuffer(bts),
+	)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201. Got %d", rec.Code)
+	}
+	created := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", created["keyId"], created["keySecret"],
+	))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with an expired key. Got %d", rec.Code)
+	}
+}
+
+func TestAccessKeysCreateHandlerRefusals(t *testing.T) {
+	beforeEachAccessKeysHandlers(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	person := helpers.CreateRootServiceAccountWithOAuth(t, "person", "person@test.com")
+	keyPairSA := helpers.CreateServiceAccountWithPermissions(
+		t, "keyPairSA", "keypairsa@test.com", models.AuthenticationTypes.KeyPair,
+	)
+	lender := helpers.CreateServiceAccountWithPermissions(
+		t, "lender", "lender@test.com", models.AuthenticationTypes.KeyPair,
+		fmt.Sprintf("Will.IAM::RL::EditServiceAccount::%s", keyPairSA.ID),
+	)
+	app := helpers.GetApp(t)
+
+	tt := []struct {
+		name   string
+		sa     *models.ServiceAccount
+		saID   string
+		status int
+	}{
+		{"oauth2 service account", rootSA, person.ID, http.StatusUnprocessableEntity},
+		{"lender", lender, keyPairSA.ID, http.StatusForbidden},
+		{"owner", rootSA, keyPairSA.ID, http.StatusCreated},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			bts, _ := json.Marshal(map[string]interface{}{"label": "some key"})
+			req, _ := http.NewRequest(
+				"POST", fmt.Sprintf("/service_accounts/%s/keys", tt.saID),
+				bytes.NewBuffer(bts),
+			)
+			req.Header.Set("Authorization", fmt.Sprintf(
+				"KeyPair %s:%s", tt.sa.KeyID, tt.sa.KeySecret,
+			))
+			rec := helpers.DoRequest(t, req, app.GetRouter())
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d. Got %d", tt.status, rec.Code)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/topfreegames/Will.IAM/constants"
+	"github.com/topfreegames/Will.IAM/ldap"
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/oauth2"
 	"github.com/topfreegames/Will.IAM/repositories"
@@ -28,7 +30,10 @@ type App struct {
 	server          *http.Server
 	metricsReporter middleware.MetricsReporter
 	storage         *repositories.Storage
-	oauth2Provider  oauth2.Provider
+	oauth2Providers *oauth2.Providers
+	sso             *ssoConfig
+	prsConfig       usecases.PermissionsRequestsConfig
+	ldapConfig      ldap.Config
 }
 
 // NewApp creates a new app
@@ -64,8 +69,20 @@ func (a *App) configureApp() error {
 	if err := a.configurePG(); err != nil {
 		return err
 	}
+	a.configureCache()
 
-	a.configureOAuth2Provider()
+	if err := a.configureOAuth2Providers(); err != nil {
+		return err
+	}
+	if err := a.configureSSO(); err != nil {
+		return err
+	}
+	if err := a.configurePermissionsRequests(); err != nil {
+		return err
+	}
+	if err := a.configureLDAP(); err != nil {
+		return err
+	}
 	a.configureServer()
 
 	return nil
@@ -94,6 +111,17 @@ func (a *App) configurePG() error {
 	return a.storage.ConfigurePG(a.config)
 }
 
+func (a *App) configureCache() {
+	if a.storage.Cache != nil {
+		return
+	}
+	a.storage.ConfigureCache(a.config)
+	if a.storage.Cache == nil {
+		return
+	}
+	go a.storage.Cache.Listen(context.Background(), a.storage, a.logger)
+}
+
 func (a *App) configureJaeger() error {
 	opts := jaeger.Options{
 		Disabled:    a.config.GetBool("jaeger.disabled"),
@@ -107,16 +135,47 @@ func (a *App) configureJaeger() error {
 	return err
 }
 
-func (a *App) configureOAuth2Provider() {
+func (a *App) configureOAuth2Providers() error {
 	repo := repositories.New(a.storage)
-	provider := oauth2.GetOAuthProvider(a.config, repo)
+	providers, err := oauth2.GetOAuthProviders(a.config, repo)
+	if err != nil {
+		return err
+	}
 
-	a.SetOAuth2Provider(provider)
+	a.SetOAuth2Providers(providers)
+	return nil
 }
 
-// SetOAuth2Provider sets a provider in App
-func (a *App) SetOAuth2Provider(provider oauth2.Provider) {
-	a.oauth2Provider = provider
+func (a *App) configureSSO() error {
+	sso, err := getSSOConfig(a.config)
+	if err != nil {
+		return err
+	}
+	a.sso = sso
+	return nil
+}
+
+func (a *App) configurePermissionsRequests() error {
+	prsConfig, err := usecases.GetPermissionsRequestsConfig(a.config)
+	if err != nil {
+		return err
+	}
+	a.prsConfig = prsConfig
+	return nil
+}
+
+func (a *App) configureLDAP() error {
+	ldapConfig, err := ldap.GetConfig(a.config)
+	if err != nil {
+		return err
+	}
+	a.ldapConfig = ldapConfig
+	return nil
+}
+
+// SetOAuth2Providers sets the providers in App
+func (a *App) SetOAuth2Providers(providers *oauth2.Providers) {
+	a.oauth2Providers = providers
 }
 
 // GetRouter returns App's *mux.Router reference
@@ -132,28 +191,73 @@ func (a *App) GetRouter() *mux.Router {
 		usecases.NewHealthcheck(repo),
 	)).Methods("GET").Name("healthcheck")
 
+	ssUC := usecases.NewServices(repo)
+
 	r.HandleFunc("/sso/auth/do",
-		authenticationBuildURLHandler(a.oauth2Provider),
+		authenticationBuildURLHandler(a.oauth2Providers, ssUC, a.sso),
 	).Methods("GET").Name("ssoAuthDo")
 
+	r.HandleFunc("/sso/auth/providers",
+		authenticationProvidersHandler(a.oauth2Providers),
+	).Methods("GET").Name("ssoAuthProviders")
+
 	psUC := usecases.NewPermissions(repo)
-	sasUC := usecases.NewServiceAccounts(repo, a.oauth2Provider)
+	sasUC := usecases.NewServiceAccounts(repo, a.oauth2Providers)
+	atsUC := usecases.NewAccessTokens(
+		repo, usecases.GetAccessTokensConfig(a.config),
+	)
 
-	r.HandleFunc("/sso/auth/done",
-		authenticationExchangeCodeHandler(a.oauth2Provider, sasUC),
-	).Methods("GET").Name("ssoAuthDone")
+	// with accounts provisioned by SCIM, unknown people can't log in
+	createOnLogin := !a.config.GetBool("scim.provisionedOnly")
+
+	acsUC := usecases.NewAuthorizationCodes(
+		repo, usecases.GetAuthorizationCodesConfig(a.config),
+	)
+
+	r.HandleFunc("/sso/auth/done", authenticationExchangeCodeHandler(
+		a.oauth2Providers, sasUC, atsUC, acsUC, a.sso, createOnLogin,
+	)).Methods("GET").Name("ssoAuthDone")
 
 	r.HandleFunc("/sso/auth/valid",
-		authenticationValidHandler(sasUC),
-	).Methods("GET").Name("ssoAuthValid")
+		authenticationValidHandler(sasUC, atsUC, ssUC, a.sso),
+	).Methods("GET", "POST").Name("ssoAuthValid")
 
-	ssUC := usecases.NewServices(repo)
-	authMiddle := authMiddleware(sasUC)
+	if a.ldapConfig.Enabled {
+		r.HandleFunc("/auth/ldap", authenticationLDAPHandler(
+			ldap.New(a.ldapConfig), sasUC, atsUC, createOnLogin,
+		)).Methods("POST").Name("authLDAP")
+	}
+
+	r.HandleFunc("/oauth2/authorize",
+		oauth2AuthorizeHandler(a.oauth2Providers, acsUC, a.sso),
+	).Methods("GET").Name("oauth2Authorize")
+
+	r.HandleFunc("/oauth2/token",
+		oauth2TokenHandler(acsUC, sasUC, atsUC),
+	).Methods("POST").Name("oauth2Token")
+
+	r.HandleFunc("/.well-known/jwks.json",
+		jwksHandler(atsUC),
+	).Methods("GET").Name("jwks")
+
+	authMiddle := authMiddleware(sasUC, atsUC)
 
 	r.Handle("/sso/auth",
 		authMiddle(http.HandlerFunc(authenticationHandler)),
 	).Methods("GET").Name("ssoAuth")
 
+	r.Handle("/auth/token",
+		authMiddle(http.HandlerFunc(authenticationIssueTokenHandler(atsUC))),
+	).Methods("POST").Name("authToken")
+
+	r.Handle("/oauth2/introspect",
+		authMiddle(http.HandlerFunc(oauth2IntrospectHandler(atsUC))),
+	).Methods("POST").Name("oauth2Introspect")
+
+	r.Handle("/oauth2/revoke",
+		authMiddle(http.HandlerFunc(oauth2RevokeHandler(atsUC))),
+	).Methods("POST").Name("oauth2Revoke")
+
 	r.PathPrefix("/sso").Handler(http.StripPrefix("/sso", http.FileServer(
 		http.Dir("./assets/sso/")),
 	)).Methods("GET").Name("sso")
@@ -246,6 +350,68 @@ func (a *App) GetRouter() *mux.Router {
 	).
 		Methods("PUT").Name("serviceAccountsUpdateHandler")
 
+	r.Handle(
+		"/service_accounts/{id}",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionOwner(
+			"EditServiceAccount", "{id}",
+		), http.HandlerFunc(
+			serviceAccountsDeleteHandler(sasUC),
+		))),
+	).
+		Methods("DELETE").Name("serviceAccountsDeleteHandler")
+
+	r.Handle(
+		"/service_accounts/{id}/deactivate",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"EditServiceAccount", "{id}",
+		), http.HandlerFunc(
+			serviceAccountsSetActiveHandler(sasUC, false),
+		))),
+	).
+		Methods("PUT").Name("serviceAccountsDeactivateHandler")
+
+	r.Handle(
+		"/service_accounts/{id}/activate",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"EditServiceAccount", "{id}",
+		), http.HandlerFunc(
+			serviceAccountsSetActiveHandler(sasUC, true),
+		))),
+	).
+		Methods("PUT").Name("serviceAccountsActivateHandler")
+
+	aksUC := usecases.NewAccessKeys(repo)
+
+	r.Handle(
+		"/service_accounts/{id}/keys",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionOwner(
+			"EditServiceAccount", "{id}",
+		), http.HandlerFunc(
+			accessKeysCreateHandler(aksUC),
+		))),
+	).
+		Methods("POST").Name("accessKeysCreateHandler")
+
+	r.Handle(
+		"/service_accounts/{id}/keys",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"EditServiceAccount", "{id}",
+		), http.HandlerFunc(
+			accessKeysListHandler(aksUC),
+		))),
+	).
+		Methods("GET").Name("accessKeysListHandler")
+
+	r.Handle(
+		"/service_accounts/{id}/keys/{keyId}",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionOwner(
+			"EditServiceAccount", "{id}",
+		), http.HandlerFunc(
+			accessKeysDeleteHandler(aksUC),
+		))),
+	).
+		Methods("DELETE").Name("accessKeysDeleteHandler")
+
 	// roles
 
 	rsUC := usecases.NewRoles(repo)
@@ -300,6 +466,38 @@ func (a *App) GetRouter() *mux.Router {
 	).
 		Methods("POST").Name("rolesCreateHandler")
 
+	msUC := usecases.NewIdPGroupRoleMappings(repo)
+
+	r.Handle(
+		"/roles/{id}/idp_groups",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"EditRole", "{id}",
+		), http.HandlerFunc(
+			idpGroupRoleMappingsListHandler(msUC),
+		))),
+	).
+		Methods("GET").Name("idpGroupRoleMappingsListHandler")
+
+	r.Handle(
+		"/roles/{id}/idp_groups",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"EditRole", "{id}",
+		), http.HandlerFunc(
+			idpGroupRoleMappingsCreateHandler(msUC),
+		))),
+	).
+		Methods("POST").Name("idpGroupRoleMappingsCreateHandler")
+
+	r.Handle(
+		"/roles/{id}/idp_groups/{mappingId}",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"EditRole", "{id}",
+		), http.HandlerFunc(
+			idpGroupRoleMappingsDeleteHandler(msUC),
+		))),
+	).
+		Methods("DELETE").Name("idpGroupRoleMappingsDeleteHandler")
+
 	// permissions
 
 	r.Handle(
@@ -334,6 +532,14 @@ func (a *App) GetRouter() *mux.Router {
 	).
 		Methods("GET").Name("permissionsHasHandler")
 
+	r.Handle(
+		"/permissions/explain",
+		authMiddle(http.HandlerFunc(
+			permissionsExplainHandler(psUC),
+		)),
+	).
+		Methods("GET").Name("permissionsExplainHandler")
+
 	r.Handle(
 		"/permissions/hasMany",
 		authMiddle(http.HandlerFunc(
@@ -344,7 +550,7 @@ func (a *App) GetRouter() *mux.Router {
 
 	// permissions requests
 
-	prsUC := usecases.NewPermissionsRequests(repo)
+	prsUC := usecases.NewPermissionsRequests(repo, a.prsConfig)
 
 	r.Handle(
 		"/permissions/requests/open",
@@ -352,6 +558,12 @@ func (a *App) GetRouter() *mux.Router {
 	).
 		Methods("GET").Name("permissionsGetPermissionRequestsHandler")
 
+	r.Handle(
+		"/permissions/requests/mine",
+		authMiddle(http.HandlerFunc(permissionsRequestsListMineHandler(prsUC))),
+	).
+		Methods("GET").Name("permissionsGetMyPermissionRequestsHandler")
+
 	r.Handle(
 		"/permissions/requests",
 		authMiddle(http.HandlerFunc(permissionsRequestsCreateHandler(prsUC))),
@@ -370,6 +582,34 @@ func (a *App) GetRouter() *mux.Router {
 	).
 		Methods("PUT").Name("permissionsGetPermissionRequestsDenyHandler")
 
+	r.Handle(
+		"/permissions/requests/{id}/cancel",
+		authMiddle(http.HandlerFunc(permissionsRequestsCancelHandler(prsUC))),
+	).
+		Methods("PUT").Name("permissionsGetPermissionRequestsCancelHandler")
+
+	// auto-approval rules
+
+	aarsUC := usecases.NewAutoApprovalRules(repo)
+
+	r.Handle(
+		"/permissions/auto_approval_rules",
+		authMiddle(http.HandlerFunc(autoApprovalRulesListHandler(aarsUC))),
+	).
+		Methods("GET").Name("autoApprovalRulesListHandler")
+
+	r.Handle(
+		"/permissions/auto_approval_rules",
+		authMiddle(http.HandlerFunc(autoApprovalRulesCreateHandler(aarsUC))),
+	).
+		Methods("POST").Name("autoApprovalRulesCreateHandler")
+
+	r.Handle(
+		"/permissions/auto_approval_rules/{id}",
+		authMiddle(http.HandlerFunc(autoApprovalRulesDeleteHandler(aarsUC))),
+	).
+		Methods("DELETE").Name("autoApprovalRulesDeleteHandler")
+
 	amUseCase := usecases.NewAM(repo, rsUC)
 
 	r.Handle(
@@ -378,6 +618,156 @@ func (a *App) GetRouter() *mux.Router {
 	).
 		Methods("GET").Name("permissionsHasHandler")
 
+	// audit
+
+	aUC := usecases.NewAudit(repo)
+
+	r.Handle(
+		"/audit",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"ReadAudit", "*",
+		), http.HandlerFunc(
+			auditListHandler(aUC),
+		))),
+	).
+		Methods("GET").Name("auditListHandler")
+
+	r.Handle(
+		"/audit/verify",
+		authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+			"ReadAudit", "*",
+		), http.HandlerFunc(
+			auditVerifyHandler(aUC),
+		))),
+	).
+		Methods("GET").Name("auditVerifyHandler")
+
+	// scim
+
+	if a.config.GetBool("scim.enabled") {
+		scimUC := usecases.NewSCIM(repo)
+
+		r.Handle(
+			scimPath+"/Users",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimUsersListHandler(scimUC),
+			))),
+		).
+			Methods("GET").Name("scimUsersListHandler")
+
+		r.Handle(
+			scimPath+"/Users",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimUsersCreateHandler(scimUC),
+			))),
+		).
+			Methods("POST").Name("scimUsersCreateHandler")
+
+		r.Handle(
+			scimPath+"/Users/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimUsersGetHandler(scimUC),
+			))),
+		).
+			Methods("GET").Name("scimUsersGetHandler")
+
+		r.Handle(
+			scimPath+"/Users/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimUsersReplaceHandler(scimUC),
+			))),
+		).
+			Methods("PUT").Name("scimUsersReplaceHandler")
+
+		r.Handle(
+			scimPath+"/Users/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimUsersPatchHandler(scimUC),
+			))),
+		).
+			Methods("PATCH").Name("scimUsersPatchHandler")
+
+		r.Handle(
+			scimPath+"/Users/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimUsersDeleteHandler(scimUC),
+			))),
+		).
+			Methods("DELETE").Name("scimUsersDeleteHandler")
+
+		r.Handle(
+			scimPath+"/Groups",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimGroupsListHandler(scimUC),
+			))),
+		).
+			Methods("GET").Name("scimGroupsListHandler")
+
+		r.Handle(
+			scimPath+"/Groups",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimGroupsCreateHandler(scimUC),
+			))),
+		).
+			Methods("POST").Name("scimGroupsCreateHandler")
+
+		r.Handle(
+			scimPath+"/Groups/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimGroupsGetHandler(scimUC),
+			))),
+		).
+			Methods("GET").Name("scimGroupsGetHandler")
+
+		r.Handle(
+			scimPath+"/Groups/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimGroupsReplaceHandler(scimUC),
+			))),
+		).
+			Methods("PUT").Name("scimGroupsReplaceHandler")
+
+		r.Handle(
+			scimPath+"/Groups/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimGroupsPatchHandler(scimUC),
+			))),
+		).
+			Methods("PATCH").Name("scimGroupsPatchHandler")
+
+		r.Handle(
+			scimPath+"/Groups/{id}",
+			authMiddle(hasPermissionMiddle(models.BuildWillIAMPermissionLender(
+				"ProvisionSCIM", "*",
+			), http.HandlerFunc(
+				scimGroupsDeleteHandler(scimUC),
+			))),
+		).
+			Methods("DELETE").Name("scimGroupsDeleteHandler")
+	}
+
 	return r
 }
 
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+// buildAuditEventsFilter reads GET /audit filters from qs, validating
+// actorId as an uuid and since / until as RFC 3339 timestamps
+func buildAuditEventsFilter(
+	qs url.Values,
+) (*repositories.AuditEventsFilter, models.Validation) {
+	v := &models.Validation{}
+	f := &repositories.AuditEventsFilter{
+		ActorServiceAccountID: qs.Get("actorId"),
+		Action:                qs.Get("action"),
+		TargetType:            qs.Get("targetType"),
+		TargetID:              qs.Get("targetId"),
+	}
+	if f.ActorServiceAccountID != "" {
+		if _, err := uuid.FromString(f.ActorServiceAccountID); err != nil {
+			v.AddError("actorId", "must be an uuid")
+		}
+	}
+	parseTime := func(field string, t *time.Time) {
+		str := qs.Get(field)
+		if str == "" {
+			return
+		}
+		var err error
+		if *t, err = time.Parse(time.RFC3339, str); err != nil {
+			v.AddError(field, "must be a RFC 3339 timestamp")
+		}
+	}
+	parseTime("since", &f.Since)
+	parseTime("until", &f.Until)
+	return f, *v
+}
+
+func auditListHandler(
+	aUC usecases.Audit,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		listOptions, err := buildListOptions(r)
+		if err != nil {
+			Write(
+				w, http.StatusUnprocessableEntity,
+				fmt.Sprintf(`{ "error": "%s"  }`, err.Error()),
+			)
+			return
+		}
+		f, v := buildAuditEventsFilter(r.URL.Query())
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
+		events, count, err := aUC.WithContext(r.Context()).List(f, listOptions)
+		if err != nil {
+			l.WithError(err).Error("auditListHandler aUC.List")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, ListResponse{
+			Count:   count,
+			Results: events,
+		})
+	}
+}
+
+func auditVerifyHandler(
+	aUC usecases.Audit,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		v, err := aUC.WithContext(r.Context()).Verify()
+		if err != nil {
+			l.WithError(err).Error("auditVerifyHandler aUC.Verify")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, v)
+	}
+}
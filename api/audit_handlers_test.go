@@ -0,0 +1,154 @@
+// +build integration
+
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/models"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+)
+
+func TestAuditListHandler(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	sa := helpers.CreateServiceAccountWithPermissions(
+		t, "some sa", "", models.AuthenticationTypes.KeyPair,
+		"Maestro::RL::ListSchedulers::*",
+	)
+	ps, err := helpers.GetServiceAccountsUseCase(t).GetPermissions(sa.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	app := helpers.GetApp(t)
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/permissions/%s", ps[0].ID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf(
+		"/audit?action=DeletePermission&targetType=permission&targetId=%s",
+		ps[0].ID,
+	), nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	var body struct {
+		Count   int64               `json:"count"`
+		Results []models.AuditEvent `json:"results"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if body.Count != 1 || len(body.Results) != 1 {
+		t.Fatalf("Expected 1 audit event. Got %d", body.Count)
+	}
+	ae := body.Results[0]
+	if ae.ActorServiceAccountID != rootSA.ID {
+		t.Errorf("Expected actor %s. Got %s", rootSA.ID, ae.ActorServiceAccountID)
+	}
+	if ae.RequestID == "" {
+		t.Error("Expected audit event to have a request id")
+	}
+	var before models.Permission
+	if err := json.Unmarshal(ae.Before, &before); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if before.String() != ps[0].String() {
+		t.Errorf("Expected before to be %s. Got %s", ps[0].String(), before.String())
+	}
+	if string(ae.After) != "null" {
+		t.Errorf("Expected after to be null. Got %s", string(ae.After))
+	}
+}
+
+func TestAuditListHandlerWithoutReadAudit(t *testing.T) {
+	helpers.CleanupPG(t)
+	sa := helpers.CreateServiceAccountWithPermissions(
+		t, "some sa", "", models.AuthenticationTypes.KeyPair,
+		"Maestro::RL::ListSchedulers::*",
+	)
+	app := helpers.GetApp(t)
+	req, _ := http.NewRequest("GET", "/audit", nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", sa.KeyID, sa.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403. Got %d", rec.Code)
+	}
+}
+
+func TestAuditListHandlerInvalidFilters(t *testing.T) {
+	helpers.CleanupPG(t)
+	sa := helpers.CreateServiceAccountWithPermissions(
+		t, "some sa", "", models.AuthenticationTypes.KeyPair,
+		"Will.IAM::RL::ReadAudit::*",
+	)
+	app := helpers.GetApp(t)
+	req, _ := http.NewRequest("GET", "/audit?actorId=x&since=yesterday", nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", sa.KeyID, sa.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422. Got %d", rec.Code)
+	}
+}
+
+func TestAuditVerifyHandler(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	helpers.CreateServiceAccountWithPermissions(
+		t, "some sa", "", models.AuthenticationTypes.KeyPair,
+		"Maestro::RL::ListSchedulers::*",
+	)
+	app := helpers.GetApp(t)
+	verify := func() models.AuditChainVerification {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "/audit/verify", nil)
+		req.Header.Set("Authorization", fmt.Sprintf(
+			"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+		))
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200. Got %d", rec.Code)
+		}
+		var v models.AuditChainVerification
+		if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+		return v
+	}
+	if v := verify(); !v.Valid || v.Checked == 0 {
+		t.Fatalf("Expected valid audit chain. Got %+v", v)
+	}
+
+	storage := helpers.GetStorage(t)
+	for _, query := range []string{
+		"ALTER TABLE audit_events DISABLE TRIGGER audit_events_append_only",
+		"UPDATE audit_events SET target_id = 'tampered' WHERE seq = (SELECT min(seq) FROM audit_events)",
+		"ALTER TABLE audit_events ENABLE TRIGGER audit_events_append_only",
+	} {
+		if _, err := storage.PG.DB.Exec(query); err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+	}
+	v := verify()
+	if v.Valid {
+		t.Fatal("Expected tampered audit chain to be invalid")
+	}
+	if v.BrokenAt == nil || v.BrokenAt.TargetID != "tampered" {
+		t.Errorf("Expected chain to break at tampered event. Got %+v", v.BrokenAt)
+	}
+}
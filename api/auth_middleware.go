@@ -32,8 +32,20 @@ func getServiceAccountID(ctx context.Context) (string, bool) {
 	return vv, true
 }
 
+// getRequestID returns the id middleware.Logging tags request logs with
+func getRequestID(ctx context.Context) string {
+	entry, ok := middleware.GetLogger(ctx).(*logrus.Entry)
+	if !ok {
+		return ""
+	}
+	id, _ := entry.Data["requestID"].(string)
+	return id
+}
+
 // authMiddleware authenticates either access_token or key pair
-func authMiddleware(sasUC usecases.ServiceAccounts) func(http.Handler) http.Handler {
+func authMiddleware(
+	sasUC usecases.ServiceAccounts, atsUC usecases.AccessTokens,
+) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			logger := middleware.GetLogger(r.Context())
@@ -50,7 +62,7 @@ func authMiddleware(sasUC usecases.ServiceAccounts) func(http.Handler) http.Hand
 			case models.AuthenticationTypes.KeyPair:
 				ctx, err = handleKeyPairAuth(r, w, *authHeader, sasUC)
 			case models.AuthenticationTypes.OAuth2:
-				ctx, err = handleOAuth2TokenAuth(r, w, *authHeader, sasUC)
+				ctx, err = handleOAuth2TokenAuth(r, w, *authHeader, sasUC, atsUC)
 			default:
 				handleInvalidAuth(w, logger)
 				return
@@ -61,6 +73,11 @@ func authMiddleware(sasUC usecases.ServiceAccounts) func(http.Handler) http.Hand
 				return
 			}
 
+			saID, _ := getServiceAccountID(ctx)
+			ctx = usecases.WithAuditActor(ctx, usecases.AuditActor{
+				ServiceAccountID: saID,
+				RequestID:        getRequestID(ctx),
+			})
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -115,6 +132,10 @@ func handleKeyPairAuth(
 			w.WriteHeader(http.StatusUnauthorized)
 			return nil, err
 		}
+		if e, ok := err.(*errors.ServiceAccountInactiveError); ok {
+			WriteBytes(w, e.StatusCode(), e.Serialize())
+			return nil, err
+		}
 
 		w.WriteHeader(http.StatusInternalServerError)
 		return nil, err
@@ -131,15 +152,26 @@ func handleOAuth2TokenAuth(
 	w http.ResponseWriter,
 	authHeader authorizationHeader,
 	sasUC usecases.ServiceAccounts,
+	atsUC usecases.AccessTokens,
 ) (context.Context, error) {
 	accessToken := authHeader.Content
-	accessTokenAuth, err := sasUC.WithContext(r.Context()).AuthenticateAccessToken(accessToken)
+	accessTokenAuth, err := authenticateAccessToken(
+		r.Context(), accessToken, sasUC, atsUC,
+	)
 
 	if err != nil {
 		if _, ok := err.(*errors.EntityNotFoundError); ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			return nil, err
 		}
+		if e, ok := err.(*errors.ServiceAccountInactiveError); ok {
+			WriteBytes(w, e.StatusCode(), e.Serialize())
+			return nil, err
+		}
+		if e, ok := err.(*errors.InvalidAccessTokenError); ok {
+			WriteBytes(w, e.StatusCode(), e.Serialize())
+			return nil, err
+		}
 
 		w.WriteHeader(http.StatusInternalServerError)
 		return nil, err
@@ -155,6 +187,18 @@ func handleOAuth2TokenAuth(
 	return ctx, nil
 }
 
+// authenticateAccessToken authenticates either an access token Will.IAM
+// issued or one from the OAuth2 provider
+func authenticateAccessToken(
+	ctx context.Context, accessToken string,
+	sasUC usecases.ServiceAccounts, atsUC usecases.AccessTokens,
+) (*models.AccessTokenAuth, error) {
+	if atsUC.Issued(accessToken) {
+		return atsUC.WithContext(ctx).Authenticate(accessToken)
+	}
+	return sasUC.WithContext(ctx).AuthenticateAccessToken(accessToken)
+}
+
 func handleInvalidAuth(
 	w http.ResponseWriter,
 	logger logrus.FieldLogger,
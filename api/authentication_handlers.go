@@ -1,22 +1,37 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
+	"time"
 
 	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/ldap"
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/oauth2"
 	"github.com/topfreegames/Will.IAM/usecases"
 	"github.com/topfreegames/extensions/middleware"
 )
 
+// writeRedirectNotAllowed answers referers SSO can't redirect to
+func writeRedirectNotAllowed(w http.ResponseWriter) {
+	Write(
+		w, http.StatusUnprocessableEntity,
+		`{ "error": "referer is not an allowed redirect origin" }`,
+	)
+}
+
+// authenticationBuildURLHandler sends people to the provider, with a state
+// signed and bound to their browser carrying where to send them back to
 func authenticationBuildURLHandler(
-	provider oauth2.Provider,
+	providers *oauth2.Providers, ssUC usecases.Services, sso *ssoConfig,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
 		qs := r.URL.Query()
 		if len(qs["referer"]) == 0 {
 			Write(
@@ -25,13 +40,56 @@ func authenticationBuildURLHandler(
 			)
 			return
 		}
-		authURL := provider.WithContext(r.Context()).BuildAuthURL(qs["referer"][0])
-		http.Redirect(w, r, authURL, http.StatusSeeOther)
+		referer := qs["referer"][0]
+		allowed, err := redirectAllowed(
+			ssUC.WithContext(r.Context()), sso, referer,
+		)
+		if err != nil {
+			l.WithError(err).Error("authenticationBuildURLHandler redirectAllowed failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			writeRedirectNotAllowed(w)
+			return
+		}
+		name := qs.Get("provider")
+		if name == "" {
+			name = providers.Default()
+		}
+		provider, ok := providers.Get(name)
+		if !ok {
+			Write(
+				w, http.StatusUnprocessableEntity,
+				`{ "error": "querystrings.provider is not a configured provider" }`,
+			)
+			return
+		}
+		redirectToProvider(w, r, provider, sso, oauth2.State{
+			Provider: name, Referer: referer,
+		})
 	}
 }
 
+// authenticationProvidersHandler lists the providers SSO can be done with,
+// for the SSO page to let users choose one
+func authenticationProvidersHandler(
+	providers *oauth2.Providers,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"providers": providers.Names(),
+		})
+	}
+}
+
+// authenticationExchangeCodeHandler finishes SSO started by the same
+// browser, handing the access token over to the SSO page in the fragment,
+// or answering the /oauth2/authorize request it was started by
 func authenticationExchangeCodeHandler(
-	provider oauth2.Provider, sasUC usecases.ServiceAccounts,
+	providers *oauth2.Providers, sasUC usecases.ServiceAccounts,
+	atsUC usecases.AccessTokens, acsUC usecases.AuthorizationCodes,
+	sso *ssoConfig, createOnLogin bool,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l := middleware.GetLogger(r.Context())
@@ -45,8 +103,26 @@ func authenticationExchangeCodeHandler(
 			return
 		}
 		code := qs["code"][0]
+		state, err := sso.stateSigner().Verify(
+			qs["state"][0], popSSONonce(w, r), time.Now(),
+		)
+		if err != nil {
+			l.WithError(err).Error("authenticationExchangeCodeHandler invalid state")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		name, referer := state.Provider, state.Referer
+		if name == "" {
+			name = providers.Default()
+		}
+		provider, ok := providers.Get(name)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
 		authResult, err := provider.WithContext(r.Context()).ExchangeCode(code)
-		if _, ok := err.(*errors.NonAllowedEmailDomainError); ok {
+		switch err.(type) {
+		case *errors.NonAllowedEmailDomainError, *errors.UnverifiedEmailError:
 			l.WithError(err).Error("oauth2.ExchangeCode failed")
 			w.WriteHeader(http.StatusUnauthorized)
 			return
@@ -56,55 +132,211 @@ func authenticationExchangeCodeHandler(
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		sa := &models.ServiceAccount{
-			Name:               authResult.Email,
+		sa, err := serviceAccountForAuthResult(
+			r.Context(), sasUC, authResult, createOnLogin,
+		)
+		if _, ok := err.(*errors.EntityNotFoundError); ok {
+			l.WithError(err).Error("service account not provisioned")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			l.WithError(err).
+				Error("authenticationExchangeCodeHandler serviceAccountForAuthResult failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if sa.VerifiedHostedDomain != authResult.HostedDomain {
+			if err := sasUC.WithContext(r.Context()).SetVerifiedHostedDomain(
+				sa.ID, authResult.HostedDomain,
+			); err != nil {
+				l.WithError(err).
+					Error("authenticationExchangeCodeHandler sasUC.SetVerifiedHostedDomain failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		if sa.Active {
+			if err := sasUC.WithContext(r.Context()).SyncGroupRoles(
+				sa.ID, name, authResult.Groups,
+			); err != nil {
+				l.WithError(err).
+					Error("authenticationExchangeCodeHandler sasUC.SyncGroupRoles failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		if state.Authorization != "" {
+			oauth2AnswerAuthorization(w, r, acsUC, state.Authorization, sa.ID)
+			return
+		}
+		issued, err := atsUC.WithContext(r.Context()).Issue(sa.ID)
+		if err != nil {
+			l.WithError(err).
+				Error("authenticationExchangeCodeHandler atsUC.Issue failed")
+			if _, ok := err.(*errors.ServiceAccountInactiveError); ok {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		v := url.Values{}
+		v.Add("accessToken", issued.AccessToken)
+		v.Add("email", authResult.Email)
+		v.Add("referer", referer)
+		http.Redirect(w, r, withFragment("/sso", v), http.StatusSeeOther)
+	}
+}
+
+// serviceAccountForAuthResult returns the service account of the user
+// authResult is about, creating it on their first login if create is set
+func serviceAccountForAuthResult(
+	ctx context.Context, sasUC usecases.ServiceAccounts,
+	authResult *models.AuthResult, create bool,
+) (*models.ServiceAccount, error) {
+	sa, err := sasUC.WithContext(ctx).ForEmail(authResult.Email)
+	if _, ok := err.(*errors.EntityNotFoundError); ok && create {
+		name := authResult.Name
+		if name == "" {
+			name = authResult.Email
+		}
+		sa = &models.ServiceAccount{
+			Name:               name,
 			Email:              authResult.Email,
 			Picture:            authResult.Picture,
 			AuthenticationType: models.AuthenticationTypes.OAuth2,
 		}
-		if _, err = sasUC.WithContext(r.Context()).ForEmail(authResult.Email); err != nil {
-			if _, ok := err.(*errors.EntityNotFoundError); ok {
-				if err = sasUC.WithContext(r.Context()).Create(sa); err != nil {
-					l.WithError(err).
-						Error("authenticationExchangeCodeHandler sasUC.Create failed")
-					w.WriteHeader(http.StatusInternalServerError)
-					return
-				}
+		if err = sasUC.WithContext(ctx).Create(sa); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return sa, nil
+}
+
+type ldapCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// authenticationLDAPHandler exchanges a directory username and password
+// for a signed access token, syncing the user groups into role bindings
+func authenticationLDAPHandler(
+	authenticator ldap.Authenticator,
+	sasUC usecases.ServiceAccounts, atsUC usecases.AccessTokens,
+	createOnLogin bool,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		body, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			l.WithError(err).Error("authenticationLDAPHandler ioutil.ReadAll failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		creds := &ldapCredentials{}
+		if err := json.Unmarshal(body, creds); err != nil {
+			Write(
+				w, http.StatusUnprocessableEntity,
+				`{ "error": "body.username and body.password are required" }`,
+			)
+			return
+		}
+		authResult, err := authenticator.Authenticate(creds.Username, creds.Password)
+		if err != nil {
+			switch e := err.(type) {
+			case *errors.InvalidCredentialsError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			case *errors.NonAllowedEmailDomainError:
+				WriteBytes(w, http.StatusUnauthorized, e.Serialize())
+				return
 			}
+			l.WithError(err).Error("authenticationLDAPHandler ldap.Authenticate failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
-		v := url.Values{}
-		v.Add("accessToken", authResult.AccessToken)
-		v.Add("email", authResult.Email)
-		v.Add("referer", qs["state"][0])
-		redirectTo := fmt.Sprintf("/sso?%s", v.Encode())
-		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+		sa, err := serviceAccountForAuthResult(
+			r.Context(), sasUC, authResult, createOnLogin,
+		)
+		if _, ok := err.(*errors.EntityNotFoundError); ok {
+			l.WithError(err).Error("service account not provisioned")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			l.WithError(err).
+				Error("authenticationLDAPHandler serviceAccountForAuthResult failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if sa.Active {
+			if err := sasUC.WithContext(r.Context()).SyncGroupRoles(
+				sa.ID, ldap.ProviderName, authResult.Groups,
+			); err != nil {
+				l.WithError(err).
+					Error("authenticationLDAPHandler sasUC.SyncGroupRoles failed")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		issued, err := atsUC.WithContext(r.Context()).Issue(sa.ID)
+		if err != nil {
+			if e, ok := err.(*errors.ServiceAccountInactiveError); ok {
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			}
+			l.WithError(err).Error("authenticationLDAPHandler atsUC.Issue failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, issued)
 	}
 }
 
+// authenticationValidHandler sends people with a valid access token back to
+// referer, along with the token in the fragment; the token is read from the
+// form, so SSO page POSTs keep it out of URLs
 func authenticationValidHandler(
-	sasUC usecases.ServiceAccounts,
+	sasUC usecases.ServiceAccounts, atsUC usecases.AccessTokens,
+	ssUC usecases.Services, sso *ssoConfig,
 ) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l := middleware.GetLogger(r.Context())
-		qs := r.URL.Query()
-		if len(qs["referer"]) == 0 {
+		referer := r.FormValue("referer")
+		if referer == "" {
 			Write(
 				w, http.StatusUnprocessableEntity,
-				`{ "error": "querystrings.referer is required" }`,
+				`{ "error": "referer is required" }`,
 			)
 			return
 		}
-		if len(qs["accessToken"]) == 0 {
+		accessToken := r.FormValue("accessToken")
+		if accessToken == "" {
 			Write(
 				w, http.StatusUnprocessableEntity,
-				`{ "error": "querystrings.accessToken is required" }`,
+				`{ "error": "accessToken is required" }`,
 			)
 			return
 		}
-		authResult, err := sasUC.WithContext(r.Context()).
-			AuthenticateAccessToken(qs["accessToken"][0])
-		referer := qs["referer"][0]
-
+		allowed, err := redirectAllowed(
+			ssUC.WithContext(r.Context()), sso, referer,
+		)
+		if err != nil {
+			l.WithError(err).Error("authenticationValidHandler redirectAllowed failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			writeRedirectNotAllowed(w)
+			return
+		}
+		authResult, err := authenticateAccessToken(
+			r.Context(), accessToken, sasUC, atsUC,
+		)
 		if err != nil {
 			l.WithError(err).Error("authenticationValidHandler AuthenticateAccessToken failed")
 			v := url.Values{}
@@ -117,15 +349,50 @@ func authenticationValidHandler(
 		v := url.Values{}
 		v.Add("accessToken", authResult.AccessToken)
 		v.Add("email", authResult.Email)
-		sep := "?"
-		if strings.Contains(referer, "?") {
-			sep = "&"
+		http.Redirect(w, r, withFragment(referer, v), http.StatusSeeOther)
+	}
+}
+
+// authenticationIssueTokenHandler exchanges the key pair the request was
+// authenticated with for a signed access token
+func authenticationIssueTokenHandler(
+	atsUC usecases.AccessTokens,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		authHeader, err := buildAuth(r.Header.Get("authorization"))
+		if err != nil || authHeader.Type != models.AuthenticationTypes.KeyPair {
+			Write(
+				w, http.StatusUnauthorized,
+				`{ "error": "a KeyPair authorization is required" }`,
+			)
+			return
+		}
+		saID, _ := getServiceAccountID(r.Context())
+		issued, err := atsUC.WithContext(r.Context()).Issue(saID)
+		if err != nil {
+			l.WithError(err).Error("authenticationIssueTokenHandler atsUC.Issue failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
 		}
+		WriteJSON(w, http.StatusOK, issued)
+	}
+}
 
-		http.Redirect(
-			w, r, fmt.Sprintf("%s%s%s", referer, sep, v.Encode()),
-			http.StatusSeeOther,
-		)
+// jwksHandler publishes the keys to verify issued access tokens with
+func jwksHandler(
+	atsUC usecases.AccessTokens,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		jwks, err := atsUC.WithContext(r.Context()).JWKS()
+		if err != nil {
+			l.WithError(err).Error("jwksHandler atsUC.JWKS failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		WriteJSON(w, http.StatusOK, jwks)
 	}
 }
 
@@ -0,0 +1,236 @@
+// +build integration
+
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/oauth2"
+	"github.com/topfreegames/Will.IAM/pkg/jwt"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+)
+
+func TestAuthenticationIssueTokenHandler(t *testing.T) {
+	helpers.CleanupPG(t)
+	sa := helpers.CreateRootServiceAccountWithKeyPair(t, "keyPairUser", "keypair.user@test.com")
+	app := helpers.GetApp(t)
+
+	req, _ := http.NewRequest("POST", "/auth/token", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("KeyPair %s:%s", sa.KeyID, sa.KeySecret))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	issued := &models.IssuedAccessToken{}
+	if err := json.Unmarshal(rec.Body.Bytes(), issued); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if issued.TokenType != "Bearer" || issued.ExpiresIn <= 0 {
+		t.Fatalf("Unexpected issued token %#v", issued)
+	}
+
+	req, _ = http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	jwks := jwt.JWKS{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &jwks); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	claims, err := jwt.Parse(issued.AccessToken, time.Now(), jwks.Key)
+	if err != nil {
+		t.Fatalf("Expected token to verify against the JWKS. Got %s", err.Error())
+	}
+	if claims.Subject != sa.ID {
+		t.Errorf("Expected subject %s. Got %s", sa.ID, claims.Subject)
+	}
+
+	bearer := fmt.Sprintf("Bearer %s", issued.AccessToken)
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", bearer)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 with the issued token. Got %d", rec.Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/auth/token", nil)
+	req.Header.Set("Authorization", bearer)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 exchanging a bearer token. Got %d", rec.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", bearer+"x")
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a tampered token. Got %d", rec.Code)
+	}
+
+	if err := helpers.GetServiceAccountsUseCase(t).SetActive(sa.ID, false); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", bearer)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 once deactivated. Got %d", rec.Code)
+	}
+}
+
+func TestAuthenticationProviders(t *testing.T) {
+	config := helpers.GetConfig(t)
+	config.Set("oauth2.providers", []string{"employees", "contractors"})
+	config.Set("oauth2.employees.type", "dev")
+	config.Set("oauth2.employees.authorizationUrl", "http://employees.idp/authorize")
+	config.Set("oauth2.contractors.type", "dev")
+	config.Set("oauth2.contractors.authorizationUrl", "http://contractors.idp/authorize")
+	app := helpers.GetApp(t)
+	providers, err := oauth2.GetOAuthProviders(config, helpers.GetRepo(t))
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	app.SetOAuth2Providers(providers)
+
+	req, _ := http.NewRequest("GET", "/sso/auth/providers", nil)
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	body := map[string][]string{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if !reflect.DeepEqual(body["providers"], []string{"employees", "contractors"}) {
+		t.Errorf("Expected providers [employees contractors]. Got %v", body["providers"])
+	}
+
+	testCases := []struct {
+		provider     string
+		wantCode     int
+		wantLocation string
+	}{
+		{"", http.StatusSeeOther, "http://employees.idp/authorize"},
+		{"contractors", http.StatusSeeOther, "http://contractors.idp/authorize"},
+		{"other", http.StatusUnprocessableEntity, ""},
+	}
+	for _, tt := range testCases {
+		v := url.Values{}
+		v.Add("provider", tt.provider)
+		v.Add("referer", "http://some.referer/?some=query")
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/sso/auth/do?%s", v.Encode()), nil)
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != tt.wantCode {
+			t.Errorf("Expected status %d for provider %q. Got %d", tt.wantCode, tt.provider, rec.Code)
+			continue
+		}
+		if tt.wantLocation == "" {
+			continue
+		}
+		location := rec.Header().Get("Location")
+		if !strings.HasPrefix(location, tt.wantLocation) {
+			t.Errorf("Expected redirect to %s. Got %s", tt.wantLocation, location)
+		}
+		u, _ := url.Parse(location)
+		if strings.Contains(u.Query().Get("state"), "some.referer") {
+			t.Errorf("Expected an opaque signed state. Got %s", u.Query().Get("state"))
+		}
+		if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Value == "" {
+			t.Errorf("Expected the state nonce cookie. Got %v", cookies)
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/sso/auth/do?referer=http%3A%2F%2Fevil.com%2F", nil)
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a non allowed referer. Got %d", rec.Code)
+	}
+}
+
+func TestAuthenticationExchangeCodeHandlerState(t *testing.T) {
+	app := helpers.GetApp(t)
+	req, _ := http.NewRequest("GET", "/sso/auth/do?referer=%2Fsome%2Fpath", nil)
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status 303. Got %d", rec.Code)
+	}
+	u, _ := url.Parse(rec.Header().Get("Location"))
+	state := u.Query().Get("state")
+	nonce := rec.Result().Cookies()[0]
+
+	testCases := []struct {
+		name   string
+		state  string
+		cookie *http.Cookie
+	}{
+		{"tampered state", state + "x", nonce},
+		{"forged state", "referer=http%3A%2F%2Fevil.com", nonce},
+		{"other browser", state, nil},
+	}
+	for _, tt := range testCases {
+		v := url.Values{}
+		v.Add("code", "some-code")
+		v.Add("state", tt.state)
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/sso/auth/done?%s", v.Encode()), nil)
+		if tt.cookie != nil {
+			req.AddCookie(tt.cookie)
+		}
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for %s. Got %d", tt.name, rec.Code)
+		}
+	}
+}
+
+func TestAuthenticationValidHandler(t *testing.T) {
+	helpers.CleanupPG(t)
+	sa := helpers.CreateRootServiceAccountWithKeyPair(t, "keyPairUser", "keypair.user@test.com")
+	app := helpers.GetApp(t)
+	req, _ := http.NewRequest("POST", "/auth/token", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("KeyPair %s:%s", sa.KeyID, sa.KeySecret))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	issued := &models.IssuedAccessToken{}
+	json.Unmarshal(rec.Body.Bytes(), issued)
+
+	testCases := []struct {
+		referer      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"http://some.referer/path?some=query#old", http.StatusSeeOther, "http://some.referer/path?some=query#accessToken="},
+		{"/some/path", http.StatusSeeOther, "/some/path#accessToken="},
+		{"http://evil.com/", http.StatusUnprocessableEntity, ""},
+		{"//evil.com/", http.StatusUnprocessableEntity, ""},
+		{"/\\evil.com/", http.StatusUnprocessableEntity, ""},
+		{"/\t/evil.com/", http.StatusUnprocessableEntity, ""},
+		{"/\n/evil.com/", http.StatusUnprocessableEntity, ""},
+		{"/\r\n/evil.com/", http.StatusUnprocessableEntity, ""},
+		{"/%09/evil.com/", http.StatusUnprocessableEntity, ""},
+		{"/%5Cevil.com/", http.StatusUnprocessableEntity, ""},
+		{"/%2F/evil.com/", http.StatusUnprocessableEntity, ""},
+	}
+	for _, tt := range testCases {
+		v := url.Values{}
+		v.Add("accessToken", issued.AccessToken)
+		v.Add("referer", tt.referer)
+		req, _ := http.NewRequest("POST", "/sso/auth/valid", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != tt.wantCode {
+			t.Errorf("Expected status %d for %s. Got %d", tt.wantCode, tt.referer, rec.Code)
+			continue
+		}
+		if location := rec.Header().Get("Location"); !strings.HasPrefix(location, tt.wantLocation) {
+			t.Errorf("Expected redirect to %s. Got %s", tt.wantLocation, location)
+		}
+	}
+}
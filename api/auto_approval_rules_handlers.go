@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+func autoApprovalRulesCreateHandler(
+	rsUC usecases.AutoApprovalRules,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		rule := &models.AutoApprovalRule{}
+		if err := unmarshalBodyTo(r, rule); err != nil {
+			l.WithError(err).Error("autoApprovalRulesCreateHandler unmarshalBodyTo")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v := rule.Validate()
+		if rule.RoleID != "" {
+			if _, err := uuid.FromString(rule.RoleID); err != nil {
+				v.AddError("roleId", "must be a role id")
+			}
+		}
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
+		saID, _ := getServiceAccountID(r.Context())
+		err := rsUC.WithContext(r.Context()).Create(saID, rule)
+		if err != nil {
+			switch e := err.(type) {
+			case *errors.EntityNotFoundError:
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+			case *errors.UserDoesntHavePermissionError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+			default:
+				l.WithError(err).Error("autoApprovalRulesCreateHandler rsUC.Create")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		WriteJSON(w, http.StatusCreated, rule)
+	}
+}
+
+func autoApprovalRulesListHandler(
+	rsUC usecases.AutoApprovalRules,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		saID, _ := getServiceAccountID(r.Context())
+		rSl, err := rsUC.WithContext(r.Context()).List(saID)
+		if err != nil {
+			l.WithError(err).Error("autoApprovalRulesListHandler rsUC.List")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, ListResponse{
+			Count:   int64(len(rSl)),
+			Results: rSl,
+		})
+	}
+}
+
+func autoApprovalRulesDeleteHandler(
+	rsUC usecases.AutoApprovalRules,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		ruleID := mux.Vars(r)["id"]
+		if _, err := uuid.FromString(ruleID); err != nil {
+			e := errors.NewEntityNotFoundError(models.AutoApprovalRule{}, ruleID)
+			WriteBytes(w, http.StatusNotFound, e.Serialize())
+			return
+		}
+		saID, _ := getServiceAccountID(r.Context())
+		err := rsUC.WithContext(r.Context()).Delete(saID, ruleID)
+		if err != nil {
+			switch e := err.(type) {
+			case *errors.EntityNotFoundError:
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+			case *errors.UserDoesntHavePermissionError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+			default:
+				l.WithError(err).Error("autoApprovalRulesDeleteHandler rsUC.Delete")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
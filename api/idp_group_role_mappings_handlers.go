@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gofrs/uuid"
+	"github.com/gorilla/mux"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+func idpGroupRoleMappingsCreateHandler(
+	msUC usecases.IdPGroupRoleMappings,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		m := &models.IdPGroupRoleMapping{}
+		if err := unmarshalBodyTo(r, m); err != nil {
+			l.WithError(err).Error("idpGroupRoleMappingsCreateHandler unmarshalBodyTo")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v := m.Validate()
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
+		err := msUC.WithContext(r.Context()).Create(mux.Vars(r)["id"], m)
+		if err != nil {
+			if e, ok := err.(*errors.EntityNotFoundError); ok {
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			}
+			if e, ok := err.(*errors.DuplicateIdPGroupRoleMappingError); ok {
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			}
+			l.WithError(err).Error("idpGroupRoleMappingsCreateHandler msUC.Create")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusCreated, m)
+	}
+}
+
+func idpGroupRoleMappingsListHandler(
+	msUC usecases.IdPGroupRoleMappings,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		mSl, err := msUC.WithContext(r.Context()).List(mux.Vars(r)["id"])
+		if err != nil {
+			if e, ok := err.(*errors.EntityNotFoundError); ok {
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			}
+			l.WithError(err).Error("idpGroupRoleMappingsListHandler msUC.List")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, ListResponse{
+			Count:   int64(len(mSl)),
+			Results: mSl,
+		})
+	}
+}
+
+func idpGroupRoleMappingsDeleteHandler(
+	msUC usecases.IdPGroupRoleMappings,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		mappingID := mux.Vars(r)["mappingId"]
+		if _, err := uuid.FromString(mappingID); err != nil {
+			e := errors.NewEntityNotFoundError(models.IdPGroupRoleMapping{}, mappingID)
+			WriteBytes(w, http.StatusNotFound, e.Serialize())
+			return
+		}
+		err := msUC.WithContext(r.Context()).
+			Delete(mux.Vars(r)["id"], mappingID)
+		if err != nil {
+			if e, ok := err.(*errors.EntityNotFoundError); ok {
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			}
+			l.WithError(err).Error("idpGroupRoleMappingsDeleteHandler msUC.Delete")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
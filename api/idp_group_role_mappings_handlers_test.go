@@ -0,0 +1,75 @@
+// +build integration
+
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	helpers "github.com/topfreegames/Will.IAM/testing"
+	"github.com/topfreegames/Will.IAM/usecases"
+)
+
+func TestIdPGroupRoleMappings(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	app := helpers.GetApp(t)
+	rootAuth := fmt.Sprintf("KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret)
+	rwn := &usecases.RoleWithNested{Name: "developers"}
+	if err := helpers.GetRolesUseCase(t).Create(rwn); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	mappingsPath := fmt.Sprintf("/roles/%s/idp_groups", rwn.ID)
+
+	tt := []struct {
+		body map[string]interface{}
+		want int
+	}{
+		{map[string]interface{}{"provider": "ldap"}, http.StatusUnprocessableEntity},
+		{map[string]interface{}{"provider": "ldap", "group": "cn=developers"}, http.StatusCreated},
+		{map[string]interface{}{"provider": "ldap", "group": "CN=Developers"}, http.StatusConflict},
+	}
+	created := map[string]interface{}{}
+	for _, tt := range tt {
+		bts, _ := json.Marshal(tt.body)
+		req, _ := http.NewRequest("POST", mappingsPath, bytes.NewBuffer(bts))
+		req.Header.Set("Authorization", rootAuth)
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != tt.want {
+			t.Fatalf("Expected status %d for %v. Got %d", tt.want, tt.body, rec.Code)
+		}
+		if rec.Code == http.StatusCreated {
+			json.Unmarshal(rec.Body.Bytes(), &created)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", mappingsPath, nil)
+	req.Header.Set("Authorization", rootAuth)
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	list := struct {
+		Count int64 `json:"count"`
+	}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if list.Count != 1 {
+		t.Errorf("Expected 1 mapping. Got %d", list.Count)
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		req, _ = http.NewRequest(
+			"DELETE", fmt.Sprintf("%s/%s", mappingsPath, created["id"]), nil,
+		)
+		req.Header.Set("Authorization", rootAuth)
+		rec = helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != want {
+			t.Errorf("Expected status %d. Got %d", want, rec.Code)
+		}
+	}
+}
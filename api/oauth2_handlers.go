@@ -0,0 +1,285 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/oauth2"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+// oauth2TokenFromForm reads the token parameter of RFC 7662 and RFC 7009
+// form encoded requests
+func oauth2TokenFromForm(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		Write(w, http.StatusBadRequest, `{ "error": "invalid_request" }`)
+		return "", false
+	}
+	return r.PostForm.Get("token"), true
+}
+
+// oauth2IntrospectHandler implements RFC 7662 token introspection
+func oauth2IntrospectHandler(
+	atsUC usecases.AccessTokens,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		token, ok := oauth2TokenFromForm(w, r)
+		if !ok {
+			return
+		}
+		ti, err := atsUC.WithContext(r.Context()).Introspect(token)
+		if err != nil {
+			l.WithError(err).Error("oauth2IntrospectHandler atsUC.Introspect failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, ti)
+	}
+}
+
+// oauth2RevokeHandler implements RFC 7009 token revocation; as the RFC
+// requires, unknown tokens are answered with 200 as well
+func oauth2RevokeHandler(
+	atsUC usecases.AccessTokens,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		token, ok := oauth2TokenFromForm(w, r)
+		if !ok {
+			return
+		}
+		if err := atsUC.WithContext(r.Context()).Revoke(token); err != nil {
+			l.WithError(err).Error("oauth2RevokeHandler atsUC.Revoke failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// withQuery returns rawURL with v added to its query
+func withQuery(rawURL string, v url.Values) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + v.Encode()
+}
+
+// redirectWithOAuth2Error answers ar at its redirect URI with e, as RFC 6749
+// section 4.1.2.1 defines
+func redirectWithOAuth2Error(
+	w http.ResponseWriter, r *http.Request, ar *models.AuthorizationRequest,
+	e *errors.OAuth2Error,
+) {
+	v := url.Values{}
+	v.Add("error", e.Code)
+	v.Add("error_description", e.Description)
+	if ar.State != "" {
+		v.Add("state", ar.State)
+	}
+	http.Redirect(w, r, withQuery(ar.RedirectURI, v), http.StatusSeeOther)
+}
+
+// oauth2AuthorizeHandler implements the RFC 6749 authorization endpoint for
+// the code flow with RFC 7636 PKCE: people log in through SSO, with the
+// provider of querystrings.provider or the default one, and the client is
+// answered with a code once it's finished
+func oauth2AuthorizeHandler(
+	providers *oauth2.Providers, acsUC usecases.AuthorizationCodes,
+	sso *ssoConfig,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		ar := models.BuildAuthorizationRequest(r.URL.Query())
+		_, err := acsUC.WithContext(r.Context()).Client(ar.ClientID, ar.RedirectURI)
+		if e, ok := err.(*errors.OAuth2Error); ok {
+			// the redirect URI can't be trusted, so the error isn't sent to it
+			WriteBytes(w, e.StatusCode(), e.Serialize())
+			return
+		}
+		if err != nil {
+			l.WithError(err).Error("oauth2AuthorizeHandler acsUC.Client failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if ar.ResponseType != "code" {
+			redirectWithOAuth2Error(w, r, ar, errors.NewOAuth2Error(
+				"unsupported_response_type", "response_type must be code",
+			))
+			return
+		}
+		if v := ar.Validate(); !v.Valid() {
+			redirectWithOAuth2Error(w, r, ar, errors.NewOAuth2Error(
+				"invalid_request", v.Error().Error(),
+			))
+			return
+		}
+		name := r.URL.Query().Get("provider")
+		if name == "" {
+			name = providers.Default()
+		}
+		provider, ok := providers.Get(name)
+		if !ok {
+			redirectWithOAuth2Error(w, r, ar, errors.NewOAuth2Error(
+				"invalid_request", "provider is not a configured provider",
+			))
+			return
+		}
+		redirectToProvider(w, r, provider, sso, oauth2.State{
+			Provider:      name,
+			Authorization: url.Values(ar.Query()).Encode(),
+		})
+	}
+}
+
+// oauth2AnswerAuthorization answers the /oauth2/authorize request SSO was
+// started by, as carried by its state, with a code issued on behalf of
+// service account saID
+func oauth2AnswerAuthorization(
+	w http.ResponseWriter, r *http.Request,
+	acsUC usecases.AuthorizationCodes, authorization, saID string,
+) {
+	l := middleware.GetLogger(r.Context())
+	qs, err := url.ParseQuery(authorization)
+	if err != nil {
+		l.WithError(err).Error("oauth2AnswerAuthorization url.ParseQuery failed")
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	ar := models.BuildAuthorizationRequest(qs)
+	// the client may have changed while people logged in
+	_, err = acsUC.WithContext(r.Context()).Client(ar.ClientID, ar.RedirectURI)
+	if e, ok := err.(*errors.OAuth2Error); ok {
+		WriteBytes(w, e.StatusCode(), e.Serialize())
+		return
+	}
+	if err != nil {
+		l.WithError(err).Error("oauth2AnswerAuthorization acsUC.Client failed")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	code, err := acsUC.WithContext(r.Context()).Issue(ar, saID)
+	if _, ok := err.(*errors.ServiceAccountInactiveError); ok {
+		redirectWithOAuth2Error(w, r, ar, errors.NewOAuth2Error(
+			"access_denied", err.Error(),
+		))
+		return
+	}
+	if err != nil {
+		l.WithError(err).Error("oauth2AnswerAuthorization acsUC.Issue failed")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	v := url.Values{}
+	v.Add("code", code)
+	if ar.State != "" {
+		v.Add("state", ar.State)
+	}
+	http.Redirect(w, r, withQuery(ar.RedirectURI, v), http.StatusSeeOther)
+}
+
+// oauth2ClientCredentials authenticates the key pair a client_credentials
+// grant was sent with, as client id and secret, either with HTTP Basic or
+// form params as RFC 6749 section 2.3.1 defines; it returns the id of the
+// service account of the key pair
+func oauth2ClientCredentials(
+	r *http.Request, sasUC usecases.ServiceAccounts,
+) (string, error) {
+	keyID, keySecret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 form encodes them before they're base64 encoded
+		keyID, _ = url.QueryUnescape(keyID)
+		keySecret, _ = url.QueryUnescape(keySecret)
+	} else {
+		keyID = r.PostForm.Get("client_id")
+		keySecret = r.PostForm.Get("client_secret")
+	}
+	if keyID == "" || keySecret == "" {
+		return "", errors.NewOAuth2Error(
+			"invalid_client", "a key pair is required as client credentials",
+		)
+	}
+	auth, err := sasUC.WithContext(r.Context()).AuthenticateKeyPair(keyID, keySecret)
+	switch err.(type) {
+	case nil:
+		return auth.ServiceAccountID, nil
+	case *errors.EntityNotFoundError, *errors.ServiceAccountInactiveError:
+		return "", errors.NewOAuth2Error("invalid_client", err.Error())
+	default:
+		return "", err
+	}
+}
+
+// oauth2TokenHandler implements the RFC 6749 token endpoint; codes are
+// redeemed by public clients, proving they're the ones that asked for them
+// with their PKCE code_verifier, for tokens whose audience is the client,
+// and key pairs are exchanged for tokens with client_credentials, so they
+// aren't sent on every request
+func oauth2TokenHandler(
+	acsUC usecases.AuthorizationCodes, sasUC usecases.ServiceAccounts,
+	atsUC usecases.AccessTokens,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		if err := r.ParseForm(); err != nil {
+			e := errors.NewOAuth2Error("invalid_request", err.Error())
+			WriteBytes(w, e.StatusCode(), e.Serialize())
+			return
+		}
+		// tokens for codes are only good for the client they're issued to
+		var saID, clientID string
+		var err error
+		switch grantType := r.PostForm.Get("grant_type"); grantType {
+		case "authorization_code":
+			clientID = r.PostForm.Get("client_id")
+			saID, err = acsUC.WithContext(r.Context()).Redeem(
+				r.PostForm.Get("code"), clientID,
+				r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"),
+			)
+		case "client_credentials":
+			saID, err = oauth2ClientCredentials(r, sasUC)
+		default:
+			err = errors.NewOAuth2Error(
+				"unsupported_grant_type",
+				"grant_type must be authorization_code or client_credentials",
+			)
+		}
+		if e, ok := err.(*errors.OAuth2Error); ok {
+			if e.StatusCode() == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Will.IAM"`)
+			}
+			WriteBytes(w, e.StatusCode(), e.Serialize())
+			return
+		}
+		if err != nil {
+			l.WithError(err).Error("oauth2TokenHandler grant failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var issued *models.IssuedAccessToken
+		if clientID != "" {
+			issued, err = atsUC.WithContext(r.Context()).IssueForClient(saID, clientID)
+		} else {
+			issued, err = atsUC.WithContext(r.Context()).Issue(saID)
+		}
+		if _, ok := err.(*errors.ServiceAccountInactiveError); ok {
+			e := errors.NewOAuth2Error("invalid_grant", err.Error())
+			WriteBytes(w, e.StatusCode(), e.Serialize())
+			return
+		}
+		if err != nil {
+			l.WithError(err).Error("oauth2TokenHandler atsUC.Issue failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		WriteJSON(w, http.StatusOK, models.OAuth2AccessToken(*issued))
+	}
+}
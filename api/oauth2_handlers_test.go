@@ -0,0 +1,316 @@
+// +build integration
+
+package api_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/api"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/oauth2"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+)
+
+func oauth2FormRequest(path, token, authorization string) *http.Request {
+	v := url.Values{}
+	if token != "" {
+		v.Set("token", token)
+	}
+	req, _ := http.NewRequest("POST", path, strings.NewReader(v.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", authorization)
+	return req
+}
+
+func introspect(
+	t *testing.T, app http.Handler, token, authorization string,
+) *models.TokenIntrospection {
+	t.Helper()
+	rec := helpers.DoRequest(t, oauth2FormRequest(
+		"/oauth2/introspect", token, authorization,
+	), app)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	ti := &models.TokenIntrospection{}
+	if err := json.Unmarshal(rec.Body.Bytes(), ti); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	return ti
+}
+
+func TestOAuth2IntrospectRevokeIssuedToken(t *testing.T) {
+	helpers.CleanupPG(t)
+	gateway := helpers.CreateRootServiceAccountWithKeyPair(t, "gateway", "gateway@test.com")
+	sa := helpers.CreateRootServiceAccountWithKeyPair(t, "keyPairUser", "keypair.user@test.com")
+	app := helpers.GetApp(t).GetRouter()
+	gatewayAuth := fmt.Sprintf("KeyPair %s:%s", gateway.KeyID, gateway.KeySecret)
+
+	req, _ := http.NewRequest("POST", "/auth/token", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("KeyPair %s:%s", sa.KeyID, sa.KeySecret))
+	rec := helpers.DoRequest(t, req, app)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	issued := &models.IssuedAccessToken{}
+	if err := json.Unmarshal(rec.Body.Bytes(), issued); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+
+	ti := introspect(t, app, issued.AccessToken, gatewayAuth)
+	if !ti.Active || ti.Subject != sa.ID || ti.ServiceAccountID != sa.ID || ti.ExpiresAt == 0 {
+		t.Fatalf("Expected an active token of %s. Got %#v", sa.ID, ti)
+	}
+
+	rec = helpers.DoRequest(t, oauth2FormRequest(
+		"/oauth2/revoke", issued.AccessToken, gatewayAuth,
+	), app)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	if ti := introspect(t, app, issued.AccessToken, gatewayAuth); ti.Active {
+		t.Errorf("Expected a revoked token to be inactive. Got %#v", ti)
+	}
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", issued.AccessToken))
+	rec = helpers.DoRequest(t, req, app)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a revoked token. Got %d", rec.Code)
+	}
+}
+
+func TestOAuth2IntrospectRevokeProviderToken(t *testing.T) {
+	helpers.CleanupPG(t)
+	gateway := helpers.CreateRootServiceAccountWithKeyPair(t, "gateway", "gateway@test.com")
+	oauthSA := helpers.CreateRootServiceAccountWithOAuth(t, "oauthUser", "oauth.user@test.com")
+	tokens, _ := helpers.GetRepo(t).Tokens.FindByEmail(oauthSA.Email)
+	token := tokens[0]
+	app := helpers.GetApp(t).GetRouter()
+	gatewayAuth := fmt.Sprintf("KeyPair %s:%s", gateway.KeyID, gateway.KeySecret)
+
+	ti := introspect(t, app, token.AccessToken, gatewayAuth)
+	if !ti.Active || ti.ServiceAccountID != oauthSA.ID || ti.Email != oauthSA.Email {
+		t.Fatalf("Expected an active token of %s. Got %#v", oauthSA.ID, ti)
+	}
+
+	rec := helpers.DoRequest(t, oauth2FormRequest(
+		"/oauth2/revoke", token.AccessToken, gatewayAuth,
+	), app)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	if ti := introspect(t, app, token.AccessToken, gatewayAuth); ti.Active {
+		t.Errorf("Expected a revoked token to be inactive. Got %#v", ti)
+	}
+	req, _ := http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
+	rec = helpers.DoRequest(t, req, app)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a revoked token. Got %d", rec.Code)
+	}
+}
+
+func TestOAuth2IntrospectRevokeHandlersRequireToken(t *testing.T) {
+	helpers.CleanupPG(t)
+	gateway := helpers.CreateRootServiceAccountWithKeyPair(t, "gateway", "gateway@test.com")
+	app := helpers.GetApp(t).GetRouter()
+	gatewayAuth := fmt.Sprintf("KeyPair %s:%s", gateway.KeyID, gateway.KeySecret)
+
+	for _, path := range []string{"/oauth2/introspect", "/oauth2/revoke"} {
+		rec := helpers.DoRequest(t, oauth2FormRequest(path, "", gatewayAuth), app)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 from %s. Got %d", path, rec.Code)
+		}
+	}
+	if ti := introspect(t, app, "unknown", gatewayAuth); ti.Active {
+		t.Errorf("Expected an unknown token to be inactive. Got %#v", ti)
+	}
+}
+
+// idpMock is a provider that authenticates everyone as email, sending the
+// state back as a real one would
+type idpMock struct {
+	email string
+}
+
+func (p *idpMock) BuildAuthURL(state string) (string, error) {
+	return fmt.Sprintf("http://idp.mock/authorize?state=%s", url.QueryEscape(state)), nil
+}
+
+func (p *idpMock) ExchangeCode(code string) (*models.AuthResult, error) {
+	return &models.AuthResult{AccessToken: code, Email: p.email}, nil
+}
+
+func (p *idpMock) Authenticate(accessToken string) (*models.AuthResult, error) {
+	return &models.AuthResult{AccessToken: accessToken, Email: p.email}, nil
+}
+
+func (p *idpMock) WithContext(ctx context.Context) oauth2.Provider {
+	return p
+}
+
+func TestOAuth2AuthorizationCodeFlow(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	client := &models.Service{
+		Name:                    "Some CLI",
+		PermissionName:          "SomeCLI",
+		CreatorServiceAccountID: rootSA.ID,
+		RedirectURIs:            []string{"http://127.0.0.1/callback"},
+	}
+	if err := helpers.GetServicesUseCase(t).Create(client); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	notAllowed := &models.Service{
+		Name:                    "Some Other CLI",
+		PermissionName:          "SomeOtherCLI",
+		CreatorServiceAccountID: rootSA.ID,
+		RedirectURIs:            []string{"http://127.0.0.1/callback"},
+	}
+	if err := helpers.GetServicesUseCase(t).Create(notAllowed); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	config := helpers.GetConfig(t)
+	config.Set("authorizationCodes.clients", []string{client.ID})
+	app, err := api.NewApp("0.0.0.0", 4040, config, helpers.GetLogger(t), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	app.SetOAuth2Providers(oauth2.NewProviders(helpers.GetRepo(t)).
+		Add("idp", &idpMock{email: "some.user@example.com"}))
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	authorize := func(clientID, redirectURI, challenge string) *http.Response {
+		v := url.Values{}
+		v.Add("response_type", "code")
+		v.Add("client_id", clientID)
+		v.Add("redirect_uri", redirectURI)
+		v.Add("state", "client-state")
+		v.Add("code_challenge", challenge)
+		v.Add("code_challenge_method", "S256")
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/oauth2/authorize?%s", v.Encode()), nil)
+		return helpers.DoRequest(t, req, app.GetRouter()).Result()
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	redirectURI := "http://127.0.0.1:8765/callback"
+
+	if res := authorize("e6fee046-6045-45d2-b6f1-a21b82977782", redirectURI, challenge); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown client. Got %d", res.StatusCode)
+	}
+	if res := authorize(notAllowed.ID, redirectURI, challenge); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a client not allowed. Got %d", res.StatusCode)
+	}
+	if res := authorize(client.ID, "http://evil.com/callback", challenge); res.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non registered redirect_uri. Got %d", res.StatusCode)
+	}
+	res := authorize(client.ID, redirectURI, "")
+	if location := res.Header.Get("Location"); !strings.HasPrefix(location, redirectURI+"?error=invalid_request") {
+		t.Errorf("Expected invalid_request sent to the client. Got %d %s", res.StatusCode, location)
+	}
+
+	res = authorize(client.ID, redirectURI, challenge)
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("Expected status 303. Got %d", res.StatusCode)
+	}
+	u, _ := url.Parse(res.Header.Get("Location"))
+	v := url.Values{}
+	v.Add("code", "idp-code")
+	v.Add("state", u.Query().Get("state"))
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/sso/auth/done?%s", v.Encode()), nil)
+	req.AddCookie(res.Cookies()[0])
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("Expected status 303. Got %d", rec.Code)
+	}
+	u, _ = url.Parse(rec.Header().Get("Location"))
+	if !strings.HasPrefix(u.String(), redirectURI) || u.Query().Get("state") != "client-state" {
+		t.Fatalf("Expected the client answered with its state. Got %s", u.String())
+	}
+
+	redeem := func(verifier string) (int, map[string]interface{}) {
+		v := url.Values{}
+		v.Add("grant_type", "authorization_code")
+		v.Add("code", u.Query().Get("code"))
+		v.Add("client_id", client.ID)
+		v.Add("redirect_uri", redirectURI)
+		v.Add("code_verifier", verifier)
+		req, _ := http.NewRequest("POST", "/oauth2/token", strings.NewReader(v.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		body := map[string]interface{}{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+	code, body := redeem(verifier)
+	if code != http.StatusOK || body["access_token"] == nil || body["token_type"] != "Bearer" {
+		t.Fatalf("Expected an access token. Got %d %v", code, body)
+	}
+	if ti := introspect(t, app.GetRouter(), body["access_token"].(string), fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	)); !ti.Active || ti.Email != "some.user@example.com" || ti.ClientID != client.ID {
+		t.Errorf("Expected a token of the user for the client. Got %#v", ti)
+	}
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", body["access_token"]))
+	if rec := helpers.DoRequest(t, req, app.GetRouter()); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 using the client token against Will.IAM. Got %d", rec.Code)
+	}
+	code, body = redeem(verifier)
+	if code != http.StatusBadRequest || body["error"] != "invalid_grant" {
+		t.Errorf("Expected invalid_grant redeeming a code twice. Got %d %v", code, body)
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	helpers.CleanupPG(t)
+	sa := helpers.CreateRootServiceAccountWithKeyPair(t, "keyPairUser", "keypair.user@test.com")
+	app := helpers.GetApp(t)
+	token := func(form url.Values, keyID, keySecret string) (int, map[string]interface{}) {
+		req, _ := http.NewRequest("POST", "/oauth2/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if keyID != "" {
+			req.SetBasicAuth(keyID, keySecret)
+		}
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		body := map[string]interface{}{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+
+	code, body := token(url.Values{"grant_type": {"client_credentials"}}, sa.KeyID, sa.KeySecret)
+	if code != http.StatusOK || body["token_type"] != "Bearer" {
+		t.Fatalf("Expected an access token. Got %d %v", code, body)
+	}
+	req, _ := http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", body["access_token"]))
+	if rec := helpers.DoRequest(t, req, app.GetRouter()); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the token. Got %d", rec.Code)
+	}
+
+	code, body = token(url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {sa.KeyID},
+		"client_secret": {sa.KeySecret},
+	}, "", "")
+	if code != http.StatusOK || body["access_token"] == nil {
+		t.Errorf("Expected an access token with form credentials. Got %d %v", code, body)
+	}
+
+	code, body = token(url.Values{"grant_type": {"client_credentials"}}, sa.KeyID, "wrong")
+	if code != http.StatusUnauthorized || body["error"] != "invalid_client" {
+		t.Errorf("Expected invalid_client for a wrong secret. Got %d %v", code, body)
+	}
+	code, body = token(url.Values{"grant_type": {"password"}}, sa.KeyID, sa.KeySecret)
+	if code != http.StatusBadRequest || body["error"] != "unsupported_grant_type" {
+		t.Errorf("Expected unsupported_grant_type. Got %d %v", code, body)
+	}
+}
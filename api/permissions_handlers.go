@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 
@@ -68,6 +69,11 @@ func permissionsAttributeHandler(
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		v := pa.Validate()
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
 		pa.Permissions, err = models.BuildPermissions(pa.PermissionsStrings)
 		if err != nil {
 			l.WithError(err).Error("BuildPermissions failed")
@@ -78,6 +84,9 @@ func permissionsAttributeHandler(
 			if alias, ok := pa.PermissionsAliases[pa.PermissionsStrings[i]]; ok {
 				pa.Permissions[i].Alias = alias
 			}
+			if tb, ok := pa.PermissionsTimeBounds[pa.PermissionsStrings[i]]; ok {
+				pa.Permissions[i].TimeBound = tb
+			}
 		}
 		saID, _ := getServiceAccountID(r.Context())
 		has, err := sasUC.WithContext(r.Context()).
@@ -120,6 +129,11 @@ func permissionsAttributeToEmailsHandler(
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		v := pa.Validate()
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
 		pa.Permissions, err = models.BuildPermissions(pa.PermissionsStrings)
 		if err != nil {
 			l.WithError(err).Error("BuildPermissions failed")
@@ -130,6 +144,9 @@ func permissionsAttributeToEmailsHandler(
 			if alias, ok := pa.PermissionsAliases[pa.PermissionsStrings[i]]; ok {
 				pa.Permissions[i].Alias = alias
 			}
+			if tb, ok := pa.PermissionsTimeBounds[pa.PermissionsStrings[i]]; ok {
+				pa.Permissions[i].TimeBound = tb
+			}
 		}
 		saID, _ := getServiceAccountID(r.Context())
 		has, err := sasUC.WithContext(r.Context()).
@@ -183,6 +200,40 @@ func permissionsHasHandler(
 	}
 }
 
+func permissionsExplainHandler(
+	psUC usecases.Permissions,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		qs := r.URL.Query()
+		permission, err := models.BuildPermission(qs.Get("permission"))
+		if err != nil {
+			Write(
+				w, http.StatusUnprocessableEntity,
+				fmt.Sprintf(`{"error": "%s"}`, err.Error()),
+			)
+			return
+		}
+		requesterID, _ := getServiceAccountID(r.Context())
+		saID := qs.Get("serviceAccountId")
+		if saID == "" {
+			saID = requesterID
+		}
+		pe, err := psUC.WithContext(r.Context()).
+			Explain(requesterID, saID, permission)
+		if err != nil {
+			if e, ok := err.(*errors.UserDoesntHavePermissionError); ok {
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			}
+			l.WithError(err).Error("permissionsExplainHandler psUC.Explain")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, http.StatusOK, pe)
+	}
+}
+
 func permissionsHasManyHandler(
 	sasUC usecases.ServiceAccounts,
 ) func(http.ResponseWriter, *http.Request) {
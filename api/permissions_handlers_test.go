@@ -3,6 +3,7 @@
 package api_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/topfreegames/Will.IAM/models"
 	"net/http"
@@ -189,3 +190,93 @@ func TestPermissionsHasHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestPermissionsExplainHandler(t *testing.T) {
+	beforeEachPermissionsHandlers(t)
+	sa := helpers.CreateServiceAccountWithPermissions(
+		t, "sa", "sa@test.com", models.AuthenticationTypes.KeyPair,
+		"Maestro::RL::DeleteScheduler::NA::*", "Maestro::RL::ListSchedulers::*",
+	)
+	other := helpers.CreateServiceAccountWithPermissions(
+		t, "other", "other@test.com", models.AuthenticationTypes.KeyPair,
+	)
+	app := helpers.GetApp(t)
+
+	testCases := []struct {
+		name           string
+		requester      *models.ServiceAccount
+		request        string
+		wantStatus     int
+		wantAllowed    bool
+		wantMatched    int
+		wantCandidates int
+	}{
+		{
+			name:        "Allowed",
+			requester:   sa,
+			request:     "/permissions/explain?permission=Maestro::RL::DeleteScheduler::NA::sniper",
+			wantStatus:  http.StatusOK,
+			wantAllowed: true,
+			wantMatched: 1,
+		},
+		{
+			name:           "NotAllowed",
+			requester:      sa,
+			request:        "/permissions/explain?permission=Maestro::RL::DeleteScheduler::EU::sniper",
+			wantStatus:     http.StatusOK,
+			wantAllowed:    false,
+			wantCandidates: 2,
+		},
+		{
+			name:       "MalformedPermission",
+			requester:  sa,
+			request:    "/permissions/explain?permission=Maestro",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:      "OtherServiceAccount",
+			requester: other,
+			request: fmt.Sprintf(
+				"/permissions/explain?permission=Maestro::RL::DeleteScheduler::NA::sniper&serviceAccountId=%s",
+				sa.ID,
+			),
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", testCase.request, nil)
+			req.Header.Set("Authorization", fmt.Sprintf(
+				"KeyPair %s:%s", testCase.requester.KeyID, testCase.requester.KeySecret,
+			))
+			rec := helpers.DoRequest(t, req, app.GetRouter())
+			if rec.Code != testCase.wantStatus {
+				t.Fatalf("Expected HTTP status %d. Got %d", testCase.wantStatus, rec.Code)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			pe := &models.PermissionExplanation{}
+			if err := json.Unmarshal(rec.Body.Bytes(), pe); err != nil {
+				t.Fatalf("Unexpected error %s", err.Error())
+			}
+			if pe.Allowed != testCase.wantAllowed {
+				t.Errorf("Expected allowed to be %t. Got %t", testCase.wantAllowed, pe.Allowed)
+			}
+			if len(pe.Matched) != testCase.wantMatched {
+				t.Errorf("Expected %d matched. Got %d", testCase.wantMatched, len(pe.Matched))
+			}
+			if len(pe.Candidates) != testCase.wantCandidates {
+				t.Errorf(
+					"Expected %d candidates. Got %d", testCase.wantCandidates, len(pe.Candidates),
+				)
+			}
+			for _, m := range pe.Matched {
+				if m.RoleName != fmt.Sprintf("service-account:%s", sa.ID) {
+					t.Errorf("Expected matched role name to be sa base role. Got %s", m.RoleName)
+				}
+			}
+		})
+	}
+}
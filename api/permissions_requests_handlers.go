@@ -1,9 +1,13 @@
 package api
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/usecases"
 	"github.com/topfreegames/extensions/middleware"
@@ -20,6 +24,11 @@ func permissionsRequestsCreateHandler(
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		v := pr.Validate()
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
 		saID, _ := getServiceAccountID(r.Context())
 		pr.ServiceAccountID = saID
 		if err := prsUC.WithContext(r.Context()).Create(pr); err != nil {
@@ -56,17 +65,93 @@ func permissionsRequestsGrantHandler(
 ) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l := middleware.GetLogger(r.Context())
+		// the body is optional, with the duration to grant the permission for
+		grant := &models.PermissionRequest{}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			l.WithError(err).Error("failed to read body")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, grant); err != nil {
+				WriteJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+				return
+			}
+		}
+		v := grant.Validate()
+		if !v.Valid() {
+			WriteBytes(w, http.StatusUnprocessableEntity, v.Errors())
+			return
+		}
 		saID, _ := getServiceAccountID(r.Context())
 		prID := mux.Vars(r)["id"]
-		if err := prsUC.WithContext(r.Context()).Grant(saID, prID); err != nil {
-			l.WithError(err).Error("failed to grant permission request")
-			w.WriteHeader(http.StatusInternalServerError)
+		if err := prsUC.WithContext(r.Context()).Grant(
+			saID, prID, time.Duration(grant.Duration),
+		); err != nil {
+			switch e := err.(type) {
+			case *errors.EntityNotFoundError:
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+			case *errors.SelfApprovalError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+			case *errors.PermissionRequestClosedError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+			default:
+				l.WithError(err).Error("failed to grant permission request")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func permissionsRequestsCancelHandler(
+	prsUC usecases.PermissionsRequests,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		saID, _ := getServiceAccountID(r.Context())
+		prID := mux.Vars(r)["id"]
+		if err := prsUC.WithContext(r.Context()).Cancel(saID, prID); err != nil {
+			switch e := err.(type) {
+			case *errors.EntityNotFoundError:
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+			case *errors.NotPermissionRequesterError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+			case *errors.PermissionRequestClosedError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+			default:
+				l.WithError(err).Error("failed to cancel permission request")
+				w.WriteHeader(http.StatusInternalServerError)
+			}
 			return
 		}
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
 
+func permissionsRequestsListMineHandler(
+	prsUC usecases.PermissionsRequests,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		saID, _ := getServiceAccountID(r.Context())
+		listOptions, err := buildListOptions(r)
+		if err != nil {
+			WriteJSON(w, http.StatusUnprocessableEntity, ErrorResponse{Error: err.Error()})
+			return
+		}
+		prs, count, err := prsUC.WithContext(r.Context()).ListForServiceAccount(listOptions, saID)
+		if err != nil {
+			l.Error(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		WriteJSON(w, 200, ListResponse{Count: count, Results: prs})
+	}
+}
+
 func permissionsRequestsListOpenHandler(
 	prsUC usecases.PermissionsRequests,
 ) func(http.ResponseWriter, *http.Request) {
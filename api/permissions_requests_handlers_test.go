@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/topfreegames/Will.IAM/models"
 	helpers "github.com/topfreegames/Will.IAM/testing"
 )
 
@@ -67,3 +68,102 @@ func TestPermissionsRequestsListHandler(t *testing.T) {
 		t.Errorf("Expected state to be Open. Got %s", pr["state"])
 	}
 }
+
+func TestPermissionsRequestsCancelHandler(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	sa, err := saUC.CreateKeyPairType("some sa")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err.Error())
+	}
+	other, err := saUC.CreateKeyPairType("other sa")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err.Error())
+	}
+	pr := &models.PermissionRequest{
+		ServiceAccountID:  sa.ID,
+		Service:           "SomeService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            "SomeAction",
+		ResourceHierarchy: models.BuildResourceHierarchy("*"),
+	}
+	if err := helpers.GetPermissionsRequestsUseCase(t).Create(pr); err != nil {
+		t.Fatalf("Unexpected error %v", err.Error())
+	}
+	app := helpers.GetApp(t)
+	tt := []struct {
+		name   string
+		sa     *models.ServiceAccount
+		status int
+	}{
+		{"someone else", other, http.StatusForbidden},
+		{"requester", sa, http.StatusAccepted},
+		{"already cancelled", sa, http.StatusConflict},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(
+				"PUT", fmt.Sprintf("/permissions/requests/%s/cancel", pr.ID), nil,
+			)
+			req.Header.Set("Authorization", fmt.Sprintf(
+				"KeyPair %s:%s", tt.sa.KeyID, tt.sa.KeySecret,
+			))
+			rec := helpers.DoRequest(t, req, app.GetRouter())
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d. Got %d", tt.status, rec.Code)
+			}
+		})
+	}
+	got, err := helpers.GetRepo(t).PermissionsRequests.Get(pr.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err.Error())
+	}
+	if got.State != models.PermissionRequestStates.Cancelled {
+		t.Errorf("Expected state to be cancelled. Got %s", got.State)
+	}
+}
+
+func TestPermissionsRequestsListMineHandler(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	sa, err := saUC.CreateKeyPairType("some sa")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err.Error())
+	}
+	other, err := saUC.CreateKeyPairType("other sa")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err.Error())
+	}
+	prsUC := helpers.GetPermissionsRequestsUseCase(t)
+	for _, saID := range []string{sa.ID, other.ID} {
+		if err := prsUC.Create(&models.PermissionRequest{
+			ServiceAccountID:  saID,
+			Service:           "SomeService",
+			OwnershipLevel:    models.OwnershipLevels.Lender,
+			Action:            "SomeAction",
+			ResourceHierarchy: models.BuildResourceHierarchy("*"),
+		}); err != nil {
+			t.Fatalf("Unexpected error %v", err.Error())
+		}
+	}
+	app := helpers.GetApp(t)
+	req, _ := http.NewRequest("GET", "/permissions/requests/mine", nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", sa.KeyID, sa.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	body := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(rec.Body.String()), &body); err != nil {
+		t.Fatalf("Unexpected error %v", err.Error())
+	}
+	if body["count"].(float64) != 1 {
+		t.Fatalf("Expected to have 1 permission request. Got %f", body["count"])
+	}
+	pr := body["results"].([]interface{})[0].(map[string]interface{})
+	if pr["serviceAccountId"].(string) != sa.ID {
+		t.Errorf("Expected serviceAccountId to be %s. Got %s", sa.ID, pr["serviceAccountId"])
+	}
+}
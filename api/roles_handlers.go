@@ -82,6 +82,10 @@ func rolesCreateHandler(
 		}
 		err = rsUC.WithContext(r.Context()).Create(rwn)
 		if err != nil {
+			if e, ok := err.(*errors.RoleInclusionCycleError); ok {
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			}
 			l.Error(err)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -113,6 +117,10 @@ func rolesUpdateHandler(
 		}
 		rwn.ID = mux.Vars(r)["id"]
 		if err = rsUC.WithContext(r.Context()).Update(rwn); err != nil {
+			if e, ok := err.(*errors.RoleInclusionCycleError); ok {
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			}
 			l.WithError(err).Error("rolesUpdateHandler rsUC.Update")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
@@ -144,9 +152,19 @@ func processRoleWithNestedFromReq(
 		if alias, ok := rwn.PermissionsAliases[rwn.PermissionsStrings[i]]; ok {
 			rwn.Permissions[i].Alias = alias
 		}
+		if tb, ok := rwn.PermissionsTimeBounds[rwn.PermissionsStrings[i]]; ok {
+			rwn.Permissions[i].TimeBound = tb
+		}
+	}
+	uc := sasUC.WithContext(r.Context())
+	has, err := uc.HasAllOwnerPermissions(saID, rwn.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errors.NewUserDoesntHaveAllPermissionsError()
 	}
-	has, err := sasUC.WithContext(r.Context()).
-		HasAllOwnerPermissions(saID, rwn.Permissions)
+	has, err = uc.HasAllOwnerRolesPermissions(saID, rwn.IncludedRolesIDs)
 	if err != nil {
 		return nil, err
 	}
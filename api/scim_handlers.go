@@ -0,0 +1,520 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/topfreegames/Will.IAM/constants"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+	"github.com/topfreegames/Will.IAM/scim"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+const scimPath = "/scim/v2"
+
+func writeSCIM(w http.ResponseWriter, status int, i interface{}) {
+	bts, err := json.Marshal(i)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(status)
+	w.Write(bts)
+}
+
+func writeSCIMError(
+	w http.ResponseWriter, status int, scimType, detail string,
+) {
+	writeSCIM(w, status, scim.NewError(status, scimType, detail))
+}
+
+// handleSCIMError answers not found for EntityNotFoundError and internal
+// server error, logged as coming from name, for everything else
+func handleSCIMError(
+	w http.ResponseWriter, r *http.Request, name string, err error,
+) {
+	switch e := err.(type) {
+	case *errors.EntityNotFoundError:
+		writeSCIMError(w, http.StatusNotFound, "", e.Error())
+		return
+	case *errors.ServiceAccountBacksServiceError:
+		writeSCIMError(w, e.StatusCode(), "", e.Error())
+		return
+	}
+	middleware.GetLogger(r.Context()).WithError(err).Error(name)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// scimBaseURL is the URL resources locations are under
+func scimBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, scimPath)
+}
+
+// buildSCIMListOptions reads startIndex, counted from 1, and count; pages
+// start at multiples of count, so startIndex is rounded down to one
+func buildSCIMListOptions(
+	r *http.Request,
+) (*repositories.ListOptions, int, error) {
+	qs := r.URL.Query()
+	count := constants.DefaultListOptionsPageSize
+	if str := qs.Get("count"); str != "" {
+		c, err := strconv.Atoi(str)
+		if err != nil || c < 1 {
+			return nil, 0, fmt.Errorf("invalid count %s", str)
+		}
+		count = c
+	}
+	startIndex := 1
+	if str := qs.Get("startIndex"); str != "" {
+		s, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid startIndex %s", str)
+		}
+		if s > 1 {
+			startIndex = s
+		}
+	}
+	lo := &repositories.ListOptions{
+		PageSize: count,
+		Page:     (startIndex - 1) / count,
+	}
+	return lo, lo.Offset() + 1, nil
+}
+
+// parseSCIMFilter parses the filter querystring, if any, failing for
+// attributes other than the ones in supported
+func parseSCIMFilter(
+	r *http.Request, supported ...string,
+) (*scim.Filter, error) {
+	str := r.URL.Query().Get("filter")
+	if str == "" {
+		return nil, nil
+	}
+	f, err := scim.ParseFilter(str)
+	if err != nil {
+		return nil, err
+	}
+	for _, attribute := range supported {
+		if f.Attribute == attribute {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("filtering by %s is not supported", f.Attribute)
+}
+
+// scimEmailTaken tells if a user other than id has email
+func scimEmailTaken(scimUC usecases.SCIM, email, id string) (bool, error) {
+	sa, err := scimUC.UserForEmail(email)
+	if _, ok := err.(*errors.EntityNotFoundError); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return sa.ID != id, nil
+}
+
+func scimUsersListHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lo, startIndex, err := buildSCIMListOptions(r)
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		f, err := parseSCIMFilter(r, "username", "emails", "emails.value")
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		base := scimBaseURL(r)
+		users := []scim.User{}
+		if f != nil {
+			sa, err := scimUC.WithContext(r.Context()).UserForEmail(f.Value)
+			if err == nil {
+				users = append(users, scim.UserFromServiceAccount(sa, nil, base))
+			} else if _, ok := err.(*errors.EntityNotFoundError); !ok {
+				handleSCIMError(w, r, "scimUsersListHandler scimUC.UserForEmail", err)
+				return
+			}
+			writeSCIM(w, http.StatusOK, scim.NewListResponse(
+				users, len(users), int64(len(users)), 1,
+			))
+			return
+		}
+		saSl, total, err := scimUC.WithContext(r.Context()).ListUsers(lo)
+		if err != nil {
+			handleSCIMError(w, r, "scimUsersListHandler scimUC.ListUsers", err)
+			return
+		}
+		for i := range saSl {
+			users = append(users, scim.UserFromServiceAccount(&saSl[i], nil, base))
+		}
+		writeSCIM(w, http.StatusOK, scim.NewListResponse(
+			users, len(users), total, startIndex,
+		))
+	}
+}
+
+func writeSCIMUser(
+	w http.ResponseWriter, r *http.Request, scimUC usecases.SCIM,
+	status int, id string,
+) {
+	sa, rs, err := scimUC.WithContext(r.Context()).GetUser(id)
+	if err != nil {
+		handleSCIMError(w, r, "writeSCIMUser scimUC.GetUser", err)
+		return
+	}
+	u := scim.UserFromServiceAccount(sa, rs, scimBaseURL(r))
+	w.Header().Set("Location", u.Meta.Location)
+	writeSCIM(w, status, u)
+}
+
+func scimUsersGetHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeSCIMUser(w, r, scimUC, http.StatusOK, mux.Vars(r)["id"])
+	}
+}
+
+func scimUsersCreateHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u := &scim.User{}
+		if err := unmarshalBodyTo(r, u); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		if v := u.Validate(); !v.Valid() {
+			writeSCIMError(
+				w, http.StatusBadRequest, "invalidValue", string(v.Errors()),
+			)
+			return
+		}
+		sa := &models.ServiceAccount{Active: true}
+		u.ApplyTo(sa)
+		taken, err := scimEmailTaken(scimUC.WithContext(r.Context()), sa.Email, "")
+		if err != nil {
+			handleSCIMError(w, r, "scimUsersCreateHandler scimEmailTaken", err)
+			return
+		}
+		if taken {
+			writeSCIMError(
+				w, http.StatusConflict, "uniqueness",
+				fmt.Sprintf("userName %s is taken", sa.Email),
+			)
+			return
+		}
+		if err := scimUC.WithContext(r.Context()).CreateUser(sa); err != nil {
+			handleSCIMError(w, r, "scimUsersCreateHandler scimUC.CreateUser", err)
+			return
+		}
+		writeSCIMUser(w, r, scimUC, http.StatusCreated, sa.ID)
+	}
+}
+
+// updateSCIMUser updates service account sa as u has it, answering with
+// the updated user
+func updateSCIMUser(
+	w http.ResponseWriter, r *http.Request, scimUC usecases.SCIM,
+	sa *models.ServiceAccount, u *scim.User,
+) {
+	if v := u.Validate(); !v.Valid() {
+		writeSCIMError(
+			w, http.StatusBadRequest, "invalidValue", string(v.Errors()),
+		)
+		return
+	}
+	u.ApplyTo(sa)
+	taken, err := scimEmailTaken(scimUC.WithContext(r.Context()), sa.Email, sa.ID)
+	if err != nil {
+		handleSCIMError(w, r, "updateSCIMUser scimEmailTaken", err)
+		return
+	}
+	if taken {
+		writeSCIMError(
+			w, http.StatusConflict, "uniqueness",
+			fmt.Sprintf("userName %s is taken", sa.Email),
+		)
+		return
+	}
+	if err := scimUC.WithContext(r.Context()).UpdateUser(sa); err != nil {
+		handleSCIMError(w, r, "updateSCIMUser scimUC.UpdateUser", err)
+		return
+	}
+	writeSCIMUser(w, r, scimUC, http.StatusOK, sa.ID)
+}
+
+func scimUsersReplaceHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sa, _, err := scimUC.WithContext(r.Context()).GetUser(mux.Vars(r)["id"])
+		if err != nil {
+			handleSCIMError(w, r, "scimUsersReplaceHandler scimUC.GetUser", err)
+			return
+		}
+		u := &scim.User{}
+		if err := unmarshalBodyTo(r, u); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		updateSCIMUser(w, r, scimUC, sa, u)
+	}
+}
+
+func scimUsersPatchHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sa, rs, err := scimUC.WithContext(r.Context()).GetUser(mux.Vars(r)["id"])
+		if err != nil {
+			handleSCIMError(w, r, "scimUsersPatchHandler scimUC.GetUser", err)
+			return
+		}
+		p := &scim.PatchOp{}
+		if err := unmarshalBodyTo(r, p); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		u := scim.UserFromServiceAccount(sa, rs, scimBaseURL(r))
+		if err := p.ApplyToUser(&u); err != nil {
+			e := err.(*scim.PatchError)
+			writeSCIMError(w, http.StatusBadRequest, e.ScimType, e.Detail)
+			return
+		}
+		updateSCIMUser(w, r, scimUC, sa, &u)
+	}
+}
+
+func scimUsersDeleteHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := scimUC.WithContext(r.Context()).DeleteUser(mux.Vars(r)["id"])
+		if err != nil {
+			handleSCIMError(w, r, "scimUsersDeleteHandler scimUC.DeleteUser", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func scimGroupsListHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lo, startIndex, err := buildSCIMListOptions(r)
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		f, err := parseSCIMFilter(r, "displayname")
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		base := scimBaseURL(r)
+		groups := []scim.Group{}
+		if f != nil {
+			role, err := scimUC.WithContext(r.Context()).GroupForName(f.Value)
+			if err == nil {
+				groups = append(groups, scim.GroupFromRole(role, nil, base))
+			} else if _, ok := err.(*errors.EntityNotFoundError); !ok {
+				handleSCIMError(w, r, "scimGroupsListHandler scimUC.GroupForName", err)
+				return
+			}
+			writeSCIM(w, http.StatusOK, scim.NewListResponse(
+				groups, len(groups), int64(len(groups)), 1,
+			))
+			return
+		}
+		rsSl, total, err := scimUC.WithContext(r.Context()).ListGroups(lo)
+		if err != nil {
+			handleSCIMError(w, r, "scimGroupsListHandler scimUC.ListGroups", err)
+			return
+		}
+		for i := range rsSl {
+			groups = append(groups, scim.GroupFromRole(&rsSl[i], nil, base))
+		}
+		writeSCIM(w, http.StatusOK, scim.NewListResponse(
+			groups, len(groups), total, startIndex,
+		))
+	}
+}
+
+func writeSCIMGroup(
+	w http.ResponseWriter, r *http.Request, scimUC usecases.SCIM,
+	status int, id string,
+) {
+	role, sas, err := scimUC.WithContext(r.Context()).GetGroup(id)
+	if err != nil {
+		handleSCIMError(w, r, "writeSCIMGroup scimUC.GetGroup", err)
+		return
+	}
+	g := scim.GroupFromRole(role, sas, scimBaseURL(r))
+	w.Header().Set("Location", g.Meta.Location)
+	writeSCIM(w, status, g)
+}
+
+func scimGroupsGetHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeSCIMGroup(w, r, scimUC, http.StatusOK, mux.Vars(r)["id"])
+	}
+}
+
+func scimGroupsCreateHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g := &scim.Group{}
+		if err := unmarshalBodyTo(r, g); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		if v := g.Validate(); !v.Valid() {
+			writeSCIMError(
+				w, http.StatusBadRequest, "invalidValue", string(v.Errors()),
+			)
+			return
+		}
+		taken, err := scimUC.WithContext(r.Context()).
+			GroupNameTaken(g.DisplayName, "")
+		if err != nil {
+			handleSCIMError(w, r, "scimGroupsCreateHandler scimUC.GroupNameTaken", err)
+			return
+		}
+		if taken {
+			writeSCIMError(
+				w, http.StatusConflict, "uniqueness",
+				fmt.Sprintf("displayName %s is taken", g.DisplayName),
+			)
+			return
+		}
+		role := &models.Role{Name: g.DisplayName}
+		err = scimUC.WithContext(r.Context()).CreateGroup(role, g.MembersIDs())
+		if e, ok := err.(*errors.EntityNotFoundError); ok {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", e.Error())
+			return
+		}
+		if err != nil {
+			handleSCIMError(w, r, "scimGroupsCreateHandler scimUC.CreateGroup", err)
+			return
+		}
+		writeSCIMGroup(w, r, scimUC, http.StatusCreated, role.ID)
+	}
+}
+
+// updateSCIMGroup updates role id as g has it, answering with the updated
+// group; id is known to exist, so entities not found are members
+func updateSCIMGroup(
+	w http.ResponseWriter, r *http.Request, scimUC usecases.SCIM,
+	id string, g *scim.Group,
+) {
+	if v := g.Validate(); !v.Valid() {
+		writeSCIMError(
+			w, http.StatusBadRequest, "invalidValue", string(v.Errors()),
+		)
+		return
+	}
+	taken, err := scimUC.WithContext(r.Context()).
+		GroupNameTaken(g.DisplayName, id)
+	if err != nil {
+		handleSCIMError(w, r, "updateSCIMGroup scimUC.GroupNameTaken", err)
+		return
+	}
+	if taken {
+		writeSCIMError(
+			w, http.StatusConflict, "uniqueness",
+			fmt.Sprintf("displayName %s is taken", g.DisplayName),
+		)
+		return
+	}
+	err = scimUC.WithContext(r.Context()).UpdateGroup(
+		&models.Role{ID: id, Name: g.DisplayName}, g.MembersIDs(),
+	)
+	if e, ok := err.(*errors.EntityNotFoundError); ok {
+		writeSCIMError(w, http.StatusBadRequest, "invalidValue", e.Error())
+		return
+	}
+	if err != nil {
+		handleSCIMError(w, r, "updateSCIMGroup scimUC.UpdateGroup", err)
+		return
+	}
+	writeSCIMGroup(w, r, scimUC, http.StatusOK, id)
+}
+
+func scimGroupsReplaceHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		if _, _, err := scimUC.WithContext(r.Context()).GetGroup(id); err != nil {
+			handleSCIMError(w, r, "scimGroupsReplaceHandler scimUC.GetGroup", err)
+			return
+		}
+		g := &scim.Group{}
+		if err := unmarshalBodyTo(r, g); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		updateSCIMGroup(w, r, scimUC, id, g)
+	}
+}
+
+func scimGroupsPatchHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		role, sas, err := scimUC.WithContext(r.Context()).GetGroup(id)
+		if err != nil {
+			handleSCIMError(w, r, "scimGroupsPatchHandler scimUC.GetGroup", err)
+			return
+		}
+		p := &scim.PatchOp{}
+		if err := unmarshalBodyTo(r, p); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		g := scim.GroupFromRole(role, sas, scimBaseURL(r))
+		if err := p.ApplyToGroup(&g); err != nil {
+			e := err.(*scim.PatchError)
+			writeSCIMError(w, http.StatusBadRequest, e.ScimType, e.Detail)
+			return
+		}
+		updateSCIMGroup(w, r, scimUC, id, &g)
+	}
+}
+
+func scimGroupsDeleteHandler(
+	scimUC usecases.SCIM,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := scimUC.WithContext(r.Context()).DeleteGroup(mux.Vars(r)["id"])
+		if err != nil {
+			handleSCIMError(w, r, "scimGroupsDeleteHandler scimUC.DeleteGroup", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,260 @@
+// +build integration
+
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/models"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+	"github.com/topfreegames/Will.IAM/usecases"
+)
+
+func doSCIMRequest(
+	t *testing.T, method, path, auth string, body interface{},
+) (int, map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/scim+json")
+	rec := helpers.DoRequest(t, req, helpers.GetApp(t).GetRouter())
+	res := map[string]interface{}{}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec.Code, res
+}
+
+func TestSCIMUsers(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	auth := fmt.Sprintf("KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret)
+	user := map[string]interface{}{
+		"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:User"},
+		"userName": "some.user@example.com",
+		"name":     map[string]string{"givenName": "Some", "familyName": "User"},
+		"active":   true,
+	}
+
+	code, created := doSCIMRequest(t, "POST", "/scim/v2/Users", auth, user)
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201. Got %d", code)
+	}
+	if created["userName"] != "some.user@example.com" || created["displayName"] != "Some User" {
+		t.Errorf("Unexpected user %v", created)
+	}
+	code, _ = doSCIMRequest(t, "POST", "/scim/v2/Users", auth, user)
+	if code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken userName. Got %d", code)
+	}
+
+	filter := url.QueryEscape(`userName eq "some.user@example.com"`)
+	code, list := doSCIMRequest(t, "GET", "/scim/v2/Users?filter="+filter, auth, nil)
+	if code != http.StatusOK || list["totalResults"] != float64(1) {
+		t.Errorf("Expected 1 user filtered. Got %d %v", code, list)
+	}
+	code, list = doSCIMRequest(t, "GET", "/scim/v2/Users", auth, nil)
+	if code != http.StatusOK || list["totalResults"] != float64(1) {
+		t.Errorf("Expected only the user, not the key pair one. Got %d %v", code, list)
+	}
+	code, _ = doSCIMRequest(t, "GET", "/scim/v2/Users/"+rootSA.ID, auth, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a key pair service account. Got %d", code)
+	}
+
+	userPath := fmt.Sprintf("/scim/v2/Users/%s", created["id"])
+	code, patched := doSCIMRequest(t, "PATCH", userPath, auth, map[string]interface{}{
+		"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+		"Operations": []map[string]interface{}{
+			{"op": "replace", "path": "active", "value": false},
+		},
+	})
+	if code != http.StatusOK || patched["active"] != false {
+		t.Errorf("Expected user deactivated. Got %d %v", code, patched)
+	}
+
+	code, _ = doSCIMRequest(t, "DELETE", userPath, auth, nil)
+	if code != http.StatusNoContent {
+		t.Errorf("Expected status 204. Got %d", code)
+	}
+	code, _ = doSCIMRequest(t, "GET", userPath, auth, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete. Got %d", code)
+	}
+}
+
+func TestSCIMGroups(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	auth := fmt.Sprintf("KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret)
+	ids := []string{}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		_, u := doSCIMRequest(t, "POST", "/scim/v2/Users", auth, map[string]interface{}{
+			"userName": email,
+		})
+		ids = append(ids, u["id"].(string))
+	}
+
+	code, created := doSCIMRequest(t, "POST", "/scim/v2/Groups", auth, map[string]interface{}{
+		"displayName": "developers",
+		"members":     []map[string]string{{"value": ids[0]}, {"value": ids[1]}},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("Expected status 201. Got %d", code)
+	}
+	if members := created["members"].([]interface{}); len(members) != 2 {
+		t.Errorf("Expected 2 members. Got %v", members)
+	}
+	code, _ = doSCIMRequest(t, "POST", "/scim/v2/Groups", auth, map[string]interface{}{
+		"displayName": "developers",
+	})
+	if code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken displayName. Got %d", code)
+	}
+	code, _ = doSCIMRequest(t, "POST", "/scim/v2/Groups", auth, map[string]interface{}{
+		"displayName": "others",
+		"members":     []map[string]string{{"value": rootSA.ID}},
+	})
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a key pair member. Got %d", code)
+	}
+
+	groupPath := fmt.Sprintf("/scim/v2/Groups/%s", created["id"])
+	code, patched := doSCIMRequest(t, "PATCH", groupPath, auth, map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{"op": "remove", "path": fmt.Sprintf(`members[value eq "%s"]`, ids[0])},
+			{"op": "replace", "path": "displayName", "value": "engineers"},
+		},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", code)
+	}
+	members := patched["members"].([]interface{})
+	if patched["displayName"] != "engineers" || len(members) != 1 ||
+		members[0].(map[string]interface{})["value"] != ids[1] {
+		t.Errorf("Unexpected group after patch %v", patched)
+	}
+
+	filter := url.QueryEscape(`displayName eq "engineers"`)
+	code, list := doSCIMRequest(t, "GET", "/scim/v2/Groups?filter="+filter, auth, nil)
+	if code != http.StatusOK || list["totalResults"] != float64(1) {
+		t.Errorf("Expected 1 group filtered. Got %d %v", code, list)
+	}
+
+	code, _ = doSCIMRequest(t, "DELETE", groupPath, auth, nil)
+	if code != http.StatusNoContent {
+		t.Errorf("Expected status 204. Got %d", code)
+	}
+	code, _ = doSCIMRequest(t, "GET", groupPath, auth, nil)
+	if code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete. Got %d", code)
+	}
+}
+
+func TestSCIMGroupsHideRolesNotCreatedThroughSCIM(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	auth := fmt.Sprintf("KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret)
+	rwn := &usecases.RoleWithNested{
+		Name:               "admins",
+		PermissionsStrings: []string{"*::RO::*::*"},
+	}
+	if err := helpers.GetRolesUseCase(t).Create(rwn); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	_, u := doSCIMRequest(t, "POST", "/scim/v2/Users", auth, map[string]interface{}{
+		"userName": "a@example.com",
+	})
+
+	groupPath := fmt.Sprintf("/scim/v2/Groups/%s", rwn.ID)
+	members := []map[string]string{{"value": u["id"].(string)}}
+	for _, tt := range []struct {
+		method string
+		body   interface{}
+	}{
+		{"GET", nil},
+		{"PUT", map[string]interface{}{"displayName": "admins", "members": members}},
+		{"PATCH", map[string]interface{}{
+			"Operations": []map[string]interface{}{
+				{"op": "add", "path": "members", "value": members},
+			},
+		}},
+		{"DELETE", nil},
+	} {
+		if code, _ := doSCIMRequest(t, tt.method, groupPath, auth, tt.body); code != http.StatusNotFound {
+			t.Errorf("Expected status 404 for %s. Got %d", tt.method, code)
+		}
+	}
+	code, list := doSCIMRequest(t, "GET", "/scim/v2/Groups", auth, nil)
+	if code != http.StatusOK || list["totalResults"] != float64(0) {
+		t.Errorf("Expected no groups. Got %d %v", code, list)
+	}
+	filter := url.QueryEscape(`displayName eq "admins"`)
+	code, list = doSCIMRequest(t, "GET", "/scim/v2/Groups?filter="+filter, auth, nil)
+	if code != http.StatusOK || list["totalResults"] != float64(0) {
+		t.Errorf("Expected no groups filtered. Got %d %v", code, list)
+	}
+	code, _ = doSCIMRequest(t, "POST", "/scim/v2/Groups", auth, map[string]interface{}{
+		"displayName": "admins",
+	})
+	if code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken displayName. Got %d", code)
+	}
+	sas, err := helpers.GetRepo(t).Roles.GetServiceAccounts(rwn.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(sas) != 0 {
+		t.Errorf("Expected admins to have no members. Got %v", sas)
+	}
+}
+
+func TestSCIMGroupsKeepKeyPairMembers(t *testing.T) {
+	helpers.CleanupPG(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	auth := fmt.Sprintf("KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret)
+	_, u := doSCIMRequest(t, "POST", "/scim/v2/Users", auth, map[string]interface{}{
+		"userName": "a@example.com",
+	})
+	_, created := doSCIMRequest(t, "POST", "/scim/v2/Groups", auth, map[string]interface{}{
+		"displayName": "developers",
+		"members":     []map[string]string{{"value": u["id"].(string)}},
+	})
+	roleID := created["id"].(string)
+	machine := helpers.CreateServiceAccountWithPermissions(
+		t, "deployer", "deployer@test.com", models.AuthenticationTypes.KeyPair,
+	)
+	repo := helpers.GetRepo(t)
+	if err := repo.Roles.Bind(&models.RoleBinding{
+		RoleID: roleID, ServiceAccountID: machine.ID,
+	}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	groupPath := fmt.Sprintf("/scim/v2/Groups/%s", roleID)
+	code, got := doSCIMRequest(t, "GET", groupPath, auth, nil)
+	if code != http.StatusOK || len(got["members"].([]interface{})) != 1 {
+		t.Errorf("Expected only the OAuth2 member. Got %d %v", code, got)
+	}
+	code, _ = doSCIMRequest(t, "PUT", groupPath, auth, map[string]interface{}{
+		"displayName": "developers",
+		"members":     []map[string]string{},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", code)
+	}
+	sas, err := repo.Roles.GetServiceAccounts(roleID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(sas) != 1 || sas[0].ID != machine.ID {
+		t.Errorf("Expected only %s to remain bound. Got %v", machine.ID, sas)
+	}
+}
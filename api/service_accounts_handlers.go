@@ -64,7 +64,8 @@ func serviceAccountsCreateHandler(
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		w.WriteHeader(http.StatusCreated)
+		// keySecret is only ever shown here
+		WriteJSON(w, http.StatusCreated, sawn)
 	}
 }
 
@@ -101,6 +102,48 @@ func serviceAccountsUpdateHandler(
 	}
 }
 
+func serviceAccountsSetActiveHandler(
+	sasUC usecases.ServiceAccounts, active bool,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		err := sasUC.WithContext(r.Context()).SetActive(mux.Vars(r)["id"], active)
+		if err != nil {
+			if e, ok := err.(*errors.EntityNotFoundError); ok {
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			}
+			l.WithError(err).Error("sasUC.SetActive failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func serviceAccountsDeleteHandler(
+	sasUC usecases.ServiceAccounts,
+) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := middleware.GetLogger(r.Context())
+		err := sasUC.WithContext(r.Context()).Delete(mux.Vars(r)["id"])
+		if err != nil {
+			switch e := err.(type) {
+			case *errors.EntityNotFoundError:
+				WriteBytes(w, http.StatusNotFound, e.Serialize())
+				return
+			case *errors.ServiceAccountBacksServiceError:
+				WriteBytes(w, e.StatusCode(), e.Serialize())
+				return
+			}
+			l.WithError(err).Error("sasUC.Delete failed")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
 func processServiceAccountWithNestedFromReq(
 	r *http.Request, sasUC usecases.ServiceAccounts,
 ) (*usecases.ServiceAccountWithNested, error) {
@@ -132,6 +175,9 @@ func processServiceAccountWithNestedFromReq(
 		if alias, ok := sawn.PermissionsAliases[sawn.PermissionsStrings[i]]; ok {
 			sawn.Permissions[i].Alias = alias
 		}
+		if tb, ok := sawn.PermissionsTimeBounds[sawn.PermissionsStrings[i]]; ok {
+			sawn.Permissions[i].TimeBound = tb
+		}
 	}
 	has, err := uc.HasAllOwnerPermissions(saID, sawn.Permissions)
 	if err != nil {
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"testing"
 
+	"github.com/go-pg/pg"
 	"github.com/topfreegames/Will.IAM/models"
 
 	helpers "github.com/topfreegames/Will.IAM/testing"
@@ -88,6 +89,74 @@ func TestServiceAccountCreateHandler(t *testing.T) {
 	}
 }
 
+func TestServiceAccountCreateHandlerShowsKeySecretOnce(t *testing.T) {
+	beforeEachServiceAccountsHandlers(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	app := helpers.GetApp(t)
+	bts, _ := json.Marshal(map[string]interface{}{
+		"name":               "some key pair sa",
+		"authenticationType": "keypair",
+	})
+	req, _ := http.NewRequest("POST", "/service_accounts", bytes.NewBuffer(bts))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201. Got %d", rec.Code)
+	}
+	created := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	keyID, _ := created["keyId"].(string)
+	keySecret, _ := created["keySecret"].(string)
+	if keyID == "" || keySecret == "" {
+		t.Fatalf("Expected keyId and keySecret. Got %v", created)
+	}
+
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("KeyPair %s:%s", keyID, keySecret))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 with the created key pair. Got %d", rec.Code)
+	}
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/service_accounts/%s", created["id"]), nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200. Got %d", rec.Code)
+	}
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if _, ok := got["keySecret"]; ok {
+		t.Error("Expected keySecret not to be shown again")
+	}
+
+	var stored string
+	storage := helpers.GetStorage(t)
+	if _, err := storage.PG.DB.Query(
+		pg.Scan(&stored), "SELECT key_secret_hash FROM access_keys WHERE key_id = ?", keyID,
+	); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if stored == "" || stored == keySecret {
+		t.Errorf("Expected a hash of the key secret to be stored. Got %s", stored)
+	}
+
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("KeyPair %s:%s", keyID, stored))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with the stored hash. Got %d", rec.Code)
+	}
+}
+
 func TestServiceAccountListHandler(t *testing.T) {
 	beforeEachServiceAccountsHandlers(t)
 
@@ -242,3 +311,170 @@ func TestServiceAccountListWithPermissionHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceAccountDeactivateHandler(t *testing.T) {
+	beforeEachServiceAccountsHandlers(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	sa := helpers.CreateRootServiceAccountWithKeyPair(t, "leaving", "leaving@test.com")
+	app := helpers.GetApp(t)
+	rootAuth := fmt.Sprintf("KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret)
+	saAuth := fmt.Sprintf("KeyPair %s:%s", sa.KeyID, sa.KeySecret)
+
+	for _, tt := range []struct {
+		action   string
+		expected int
+	}{
+		{"deactivate", http.StatusUnauthorized},
+		{"activate", http.StatusOK},
+	} {
+		req, _ := http.NewRequest(
+			"PUT", fmt.Sprintf("/service_accounts/%s/%s", sa.ID, tt.action), nil,
+		)
+		req.Header.Set("Authorization", rootAuth)
+		rec := helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 to %s. Got %d", tt.action, rec.Code)
+		}
+		req, _ = http.NewRequest("GET", "/service_accounts", nil)
+		req.Header.Set("Authorization", saAuth)
+		rec = helpers.DoRequest(t, req, app.GetRouter())
+		if rec.Code != tt.expected {
+			t.Errorf("Expected status %d after %s. Got %d", tt.expected, tt.action, rec.Code)
+		}
+	}
+}
+
+func TestServiceAccountDeleteHandler(t *testing.T) {
+	beforeEachServiceAccountsHandlers(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	lenderSA := helpers.CreateServiceAccountWithPermissions(
+		t, "lender", "lender@test.com", models.AuthenticationTypes.KeyPair,
+		"Will.IAM::RL::EditServiceAccount::*",
+	)
+	sa := helpers.CreateServiceAccountWithPermissions(
+		t, "leaving", "leaving@test.com", models.AuthenticationTypes.KeyPair,
+		"SomeService::RO::SomeAction::*",
+	)
+	prsUC := helpers.GetPermissionsRequestsUseCase(t)
+	if err := prsUC.Create(&models.PermissionRequest{
+		Service:           "OtherService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            models.BuildAction("OtherAction"),
+		ResourceHierarchy: models.BuildResourceHierarchy("*"),
+		Message:           "please",
+		ServiceAccountID:  sa.ID,
+	}); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	closed := &models.PermissionRequest{
+		Service:           "ClosedService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            models.BuildAction("ClosedAction"),
+		ResourceHierarchy: models.BuildResourceHierarchy("*"),
+		Message:           "please",
+		ServiceAccountID:  sa.ID,
+	}
+	if err := prsUC.Create(closed); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if err := prsUC.Deny(rootSA.ID, closed.ID); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	app := helpers.GetApp(t)
+	path := fmt.Sprintf("/service_accounts/%s", sa.ID)
+
+	req, _ := http.NewRequest("DELETE", path, nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", lenderSA.KeyID, lenderSA.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("Expected status 403 without owning EditServiceAccount. Got %d", rec.Code)
+	}
+
+	req, _ = http.NewRequest("DELETE", path, nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204. Got %d", rec.Code)
+	}
+
+	storage := helpers.GetStorage(t)
+	for _, tt := range []struct {
+		query string
+		param string
+	}{
+		{"SELECT count(*) FROM service_accounts WHERE id = ?", sa.ID},
+		{"SELECT count(*) FROM roles WHERE id = ?", sa.BaseRoleID},
+		{"SELECT count(*) FROM role_bindings WHERE service_account_id = ?", sa.ID},
+		{"SELECT count(*) FROM access_keys WHERE service_account_id = ?", sa.ID},
+		{"SELECT count(*) FROM permissions_requests WHERE service_account_id = ?", sa.ID},
+	} {
+		var count int
+		if _, err := storage.PG.DB.Query(pg.Scan(&count), tt.query, tt.param); err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+		if count != 0 {
+			t.Errorf("Expected nothing left for %s. Got %d", tt.query, count)
+		}
+	}
+	var closedCount int
+	if _, err := storage.PG.DB.Query(
+		pg.Scan(&closedCount), `SELECT count(*) FROM permissions_requests
+		WHERE id = ? AND service_account_id IS NULL`, closed.ID,
+	); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if closedCount != 1 {
+		t.Errorf("Expected the closed request to be kept without requester. Got %d", closedCount)
+	}
+
+	req, _ = http.NewRequest("GET", "/service_accounts", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("KeyPair %s:%s", sa.KeyID, sa.KeySecret))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a deleted service account. Got %d", rec.Code)
+	}
+
+	req, _ = http.NewRequest("DELETE", path, nil)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec = helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404. Got %d", rec.Code)
+	}
+}
+
+func TestServiceAccountDeleteHandlerBackingAService(t *testing.T) {
+	beforeEachServiceAccountsHandlers(t)
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	service := &models.Service{
+		Name:                    "Some Service",
+		PermissionName:          "SomeService",
+		CreatorServiceAccountID: rootSA.ID,
+	}
+	if err := helpers.GetServicesUseCase(t).Create(service); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	app := helpers.GetApp(t)
+	req, _ := http.NewRequest(
+		"DELETE", fmt.Sprintf("/service_accounts/%s", service.ServiceAccountID), nil,
+	)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"KeyPair %s:%s", rootSA.KeyID, rootSA.KeySecret,
+	))
+	rec := helpers.DoRequest(t, req, app.GetRouter())
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409. Got %d", rec.Code)
+	}
+	s, err := helpers.GetRepo(t).Services.Get(service.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if s.ID != service.ID {
+		t.Error("Expected the service to be kept")
+	}
+}
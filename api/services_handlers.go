@@ -85,7 +85,10 @@ func servicesGetHandler(
 			return
 		}
 		// TODO: get service account and creator service account
-		json, err := keepJSONFieldsBytes(svc, "id", "name", "permissionName", "amUrl")
+		json, err := keepJSONFieldsBytes(
+			svc, "id", "name", "permissionName", "amUrl", "redirectOrigins",
+			"redirectUris",
+		)
 		if err != nil {
 			l.Error(err)
 			w.WriteHeader(http.StatusInternalServerError)
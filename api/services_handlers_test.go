@@ -75,6 +75,12 @@ func TestServicesCreateHandler(t *testing.T) {
 			json:       invalidServiceJSON,
 			wantStatus: http.StatusUnprocessableEntity,
 		},
+		{
+			name: "InvalidRedirectOrigins",
+			json: []byte(`{"name": "Other Service", "permissionName": "OtherService",
+				"redirectOrigins": ["http://localhost:3333/with/path"]}`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
 		{
 			name:       "ValidJSON",
 			json:       validServiceJSON,
@@ -115,6 +121,7 @@ func TestServicesCreateHandler_servicePersisted(t *testing.T) {
 		PermissionName:          "SomeService",
 		CreatorServiceAccountID: sa.ID,
 		AMURL:                   "http://localhost:3333/am",
+		RedirectOrigins:         []string{"http://localhost:3333"},
 	}
 
 	reqJSON, err := json.Marshal(service)
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"crypto/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/oauth2"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+// ssoNonceCookie keeps the nonce the SSO state is bound to in the browser
+// that started SSO
+const ssoNonceCookie = "william_sso_nonce"
+
+// ssoConfig configures how SSO sends people back to where they came from
+// StateSecret: signs the state carried through providers; a random one is
+// generated when it isn't set, which only works with a single instance
+// StateTTL: how long people have to finish SSO once started
+// AllowedRedirectOrigins: origins, besides the ones services register,
+// SSO can redirect to
+type ssoConfig struct {
+	StateSecret            []byte
+	StateTTL               time.Duration
+	AllowedRedirectOrigins []string
+}
+
+func loadDefaultConfigSSO(config *viper.Viper) {
+	config.SetDefault("sso.stateTTL", "10m")
+}
+
+// getSSOConfig reads ssoConfig from config
+func getSSOConfig(config *viper.Viper) (*ssoConfig, error) {
+	loadDefaultConfigSSO(config)
+	secret := []byte(config.GetString("sso.stateSecret"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	return &ssoConfig{
+		StateSecret:            secret,
+		StateTTL:               config.GetDuration("sso.stateTTL"),
+		AllowedRedirectOrigins: config.GetStringSlice("sso.allowedRedirectOrigins"),
+	}, nil
+}
+
+// stateSigner returns the signer of SSO states
+func (c *ssoConfig) stateSigner() *oauth2.StateSigner {
+	return oauth2.NewStateSigner(c.StateSecret, c.StateTTL)
+}
+
+// originOf returns scheme://host of rawURL, or "" if it isn't absolute
+func originOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// isLocalPath tells if referer is a path of Will.IAM itself, which no
+// browser could read as another host: one starting with a single slash,
+// with neither control characters, which browsers strip, nor backslashes,
+// which they read as slashes, even percent-encoded
+func isLocalPath(referer string) bool {
+	if hasUnsafeURLChars(referer) {
+		return false
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return false
+	}
+	return !hasUnsafeURLChars(u.Path) &&
+		strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(u.Path, "//")
+}
+
+// hasUnsafeURLChars tells if s has control characters or backslashes
+func hasUnsafeURLChars(s string) bool {
+	for _, r := range s {
+		if r == '\\' || unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirectAllowed tells if SSO can send people, and their access tokens,
+// to referer: paths of Will.IAM itself and URLs of allowed origins, either
+// configured or registered by a service
+func redirectAllowed(
+	ssUC usecases.Services, c *ssoConfig, referer string,
+) (bool, error) {
+	if isLocalPath(referer) {
+		return true, nil
+	}
+	origin := originOf(referer)
+	if origin == "" {
+		return false, nil
+	}
+	for _, allowed := range c.AllowedRedirectOrigins {
+		if strings.ToLower(allowed) == origin {
+			return true, nil
+		}
+	}
+	ss, err := ssUC.List()
+	if err != nil {
+		return false, err
+	}
+	for _, s := range ss {
+		for _, allowed := range s.RedirectOrigins {
+			if strings.ToLower(allowed) == origin {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// setSSONonce binds the browser to the SSO state it's about to be sent
+// through the provider with
+func setSSONonce(w http.ResponseWriter, c *ssoConfig, nonce string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoNonceCookie,
+		Value:    nonce,
+		Path:     "/sso/auth",
+		MaxAge:   int(c.StateTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// popSSONonce returns the nonce the browser was bound to, clearing it so
+// the state can't be used again
+func popSSONonce(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie(ssoNonceCookie)
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     ssoNonceCookie,
+		Path:     "/sso/auth",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return cookie.Value
+}
+
+// redirectToProvider starts SSO with provider, carrying st through it signed
+// and bound to the browser
+func redirectToProvider(
+	w http.ResponseWriter, r *http.Request, provider oauth2.Provider,
+	sso *ssoConfig, st oauth2.State,
+) {
+	l := middleware.GetLogger(r.Context())
+	nonce, err := oauth2.NewNonce()
+	if err != nil {
+		l.WithError(err).Error("redirectToProvider oauth2.NewNonce failed")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	st.Nonce = nonce
+	authURL, err := provider.WithContext(r.Context()).BuildAuthURL(
+		sso.stateSigner().Sign(st, time.Now()),
+	)
+	if err != nil {
+		l.WithError(err).Error("oauth2.BuildAuthURL failed")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	setSSONonce(w, sso, nonce)
+	http.Redirect(w, r, authURL, http.StatusSeeOther)
+}
+
+// withFragment returns rawURL with v as its fragment, so values don't end
+// up in server logs nor Referer headers as query strings do
+func withFragment(rawURL string, v url.Values) string {
+	if i := strings.Index(rawURL, "#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	return rawURL + "#" + v.Encode()
+}
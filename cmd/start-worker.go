@@ -1,18 +1,62 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/topfreegames/Will.IAM/constants"
+	"github.com/topfreegames/Will.IAM/repositories"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/Will.IAM/utils"
+	"github.com/topfreegames/Will.IAM/worker"
+	"github.com/topfreegames/extensions/middleware"
 )
 
+// workerLeaderLock names the advisory lock electing the worker that runs jobs
+const workerLeaderLock = "Will.IAM/worker"
+
 // startWorkerCmd represents the start-worker command
 var startWorkerCmd = &cobra.Command{
 	Use:   "start-worker",
 	Short: "starts the worker",
-	Long:  `starts the worker.`,
+	Long: `starts the worker, which runs periodic jobs while elected leader
+among all running workers.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("start-worker called")
+		constants.Set(config)
+		log := utils.GetLogger("", 0, verbose, json)
+		log.Info("starting Will.IAM worker")
+		mr, err := middleware.NewDogStatsD(config)
+		if err != nil {
+			log.Panic(err.Error())
+		}
+		storage := repositories.NewStorage()
+		if err := storage.ConfigurePG(config); err != nil {
+			log.Panic(err.Error())
+		}
+		repo := repositories.New(storage)
+		w := worker.New(
+			repositories.NewLeaderLock(storage, workerLeaderLock),
+			worker.GetTick(config), log, mr,
+		)
+		for _, j := range worker.Jobs(config, usecases.NewHousekeeping(repo), mr) {
+			log.WithField("job", j.Name).Infof("scheduled every %s", j.Interval)
+			w.Register(j)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			<-sigs
+			log.Info("stopping Will.IAM worker")
+			cancel()
+		}()
+		if err := w.Run(ctx); err != nil {
+			log.WithError(err).Error("worker failed to resign")
+		}
 	},
 }
 
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	encodingJSON "encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/topfreegames/Will.IAM/repositories"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/Will.IAM/utils"
+)
+
+// verifyAuditCmd represents the verify-audit command
+var verifyAuditCmd = &cobra.Command{
+	Use:   "verify-audit",
+	Short: "verifies the audit log hash chain",
+	Long: `walks the audit log hash chain, reporting the first audit event that
+was changed, removed or inserted out of it. Exits with status 1 if the chain
+is broken.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log := utils.GetLogger("", 0, verbose, json)
+		s := repositories.NewStorage()
+		if err := s.ConfigurePG(config); err != nil {
+			log.Panic(err.Error())
+		}
+		aUC := usecases.NewAudit(repositories.New(s)).
+			WithContext(context.Background())
+		v, err := aUC.Verify()
+		if err != nil {
+			log.Panic(err.Error())
+		}
+		bts, err := encodingJSON.MarshalIndent(v, "", "  ")
+		if err != nil {
+			log.Panic(err.Error())
+		}
+		fmt.Println(string(bts))
+		if !v.Valid {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(verifyAuditCmd)
+}
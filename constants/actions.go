@@ -17,3 +17,8 @@ var ServicesActions = []string{
 	"CreateServices",
 	"EditService",
 }
+
+// AuditActions are all possible actions over the audit log
+var AuditActions = []string{
+	"ReadAudit",
+}
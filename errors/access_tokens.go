@@ -0,0 +1,38 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// InvalidAccessTokenError happens when an access token Will.IAM issued
+// fails verification
+type InvalidAccessTokenError struct {
+	reason error
+}
+
+// NewInvalidAccessTokenError ctor
+func NewInvalidAccessTokenError(reason error) *InvalidAccessTokenError {
+	return &InvalidAccessTokenError{reason: reason}
+}
+
+func (e *InvalidAccessTokenError) Error() string {
+	return fmt.Sprintf("invalid access token: %s", e.reason.Error())
+}
+
+// Serialize returns the error serialized
+func (e *InvalidAccessTokenError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-011",
+		"error":       "InvalidAccessTokenError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *InvalidAccessTokenError) StatusCode() int {
+	return 401
+}
@@ -0,0 +1,43 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DuplicateIdPGroupRoleMappingError happens when a group of a provider is
+// mapped to a role it's already mapped to
+type DuplicateIdPGroupRoleMappingError struct {
+	provider string
+	group    string
+}
+
+// NewDuplicateIdPGroupRoleMappingError ctor
+func NewDuplicateIdPGroupRoleMappingError(
+	provider, group string,
+) *DuplicateIdPGroupRoleMappingError {
+	return &DuplicateIdPGroupRoleMappingError{provider: provider, group: group}
+}
+
+func (e *DuplicateIdPGroupRoleMappingError) Error() string {
+	return fmt.Sprintf(
+		"group %s of %s is already mapped to this role", e.group, e.provider,
+	)
+}
+
+// Serialize returns the error serialized
+func (e *DuplicateIdPGroupRoleMappingError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-013",
+		"error":       "DuplicateIdPGroupRoleMappingError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *DuplicateIdPGroupRoleMappingError) StatusCode() int {
+	return 409
+}
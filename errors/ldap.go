@@ -0,0 +1,38 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// InvalidCredentialsError happens when a username and password don't bind
+// against the directory
+type InvalidCredentialsError struct {
+	username string
+}
+
+// NewInvalidCredentialsError ctor
+func NewInvalidCredentialsError(username string) *InvalidCredentialsError {
+	return &InvalidCredentialsError{username: username}
+}
+
+func (e *InvalidCredentialsError) Error() string {
+	return fmt.Sprintf("invalid credentials for %s", e.username)
+}
+
+// Serialize returns the error serialized
+func (e *InvalidCredentialsError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-012",
+		"error":       "InvalidCredentialsError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *InvalidCredentialsError) StatusCode() int {
+	return 401
+}
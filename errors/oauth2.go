@@ -30,3 +30,65 @@ func (e *NonAllowedEmailDomainError) Serialize() []byte {
 
 	return g
 }
+
+// UnverifiedEmailError happens when an identity provider doesn't vouch for
+// the email of who logged in
+type UnverifiedEmailError struct {
+	email string
+}
+
+// NewUnverifiedEmailError ctor
+func NewUnverifiedEmailError(email string) *UnverifiedEmailError {
+	return &UnverifiedEmailError{email: email}
+}
+
+func (e *UnverifiedEmailError) Error() string {
+	return fmt.Sprintf("email not verified by the identity provider: %s", e.email)
+}
+
+// Serialize returns the error serialized
+func (e *UnverifiedEmailError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-017",
+		"error":       "UnverifiedEmailError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// OAuth2Error is an error of the OAuth2 authorization server endpoints;
+// it's serialized as RFC 6749 section 5.2 defines, which is what clients
+// expect, rather than as other errors are
+type OAuth2Error struct {
+	Code        string
+	Description string
+}
+
+// NewOAuth2Error ctor; code is one of the RFC 6749 error codes
+func NewOAuth2Error(code, description string) *OAuth2Error {
+	return &OAuth2Error{Code: code, Description: description}
+}
+
+func (e *OAuth2Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// Serialize returns the error serialized
+func (e *OAuth2Error) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"error":             e.Code,
+		"error_description": e.Description,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *OAuth2Error) StatusCode() int {
+	if e.Code == "invalid_client" {
+		return 401
+	}
+	return 400
+}
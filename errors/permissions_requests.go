@@ -0,0 +1,102 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// PermissionRequestClosedError happens when a permission request that's no
+// longer open is moderated or cancelled
+type PermissionRequestClosedError struct {
+	id string
+}
+
+// NewPermissionRequestClosedError ctor
+func NewPermissionRequestClosedError(id string) *PermissionRequestClosedError {
+	return &PermissionRequestClosedError{id: id}
+}
+
+func (e *PermissionRequestClosedError) Error() string {
+	return "permission request is closed"
+}
+
+// Serialize returns the error serialized
+func (e *PermissionRequestClosedError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-014",
+		"error":       "PermissionRequestClosedError",
+		"description": fmt.Sprintf("permission request %s is closed", e.id),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *PermissionRequestClosedError) StatusCode() int {
+	return 409
+}
+
+// NotPermissionRequesterError happens when someone other than the requester
+// tries to act on a permission request as if they made it
+type NotPermissionRequesterError struct {
+	id string
+}
+
+// NewNotPermissionRequesterError ctor
+func NewNotPermissionRequesterError(id string) *NotPermissionRequesterError {
+	return &NotPermissionRequesterError{id: id}
+}
+
+func (e *NotPermissionRequesterError) Error() string {
+	return fmt.Sprintf("permission request %s was made by someone else", e.id)
+}
+
+// Serialize returns the error serialized
+func (e *NotPermissionRequesterError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-015",
+		"error":       "NotPermissionRequesterError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *NotPermissionRequesterError) StatusCode() int {
+	return 403
+}
+
+// SelfApprovalError happens when requesters try to grant their own
+// permission requests
+type SelfApprovalError struct {
+	id string
+}
+
+// NewSelfApprovalError ctor
+func NewSelfApprovalError(id string) *SelfApprovalError {
+	return &SelfApprovalError{id: id}
+}
+
+func (e *SelfApprovalError) Error() string {
+	return fmt.Sprintf("permission request %s can't be granted by its requester", e.id)
+}
+
+// Serialize returns the error serialized
+func (e *SelfApprovalError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-016",
+		"error":       "SelfApprovalError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *SelfApprovalError) StatusCode() int {
+	return 403
+}
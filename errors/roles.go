@@ -0,0 +1,38 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// RoleInclusionCycleError happens when a role would end up including itself,
+// directly or through other roles
+type RoleInclusionCycleError struct {
+	roleID string
+}
+
+// NewRoleInclusionCycleError ctor
+func NewRoleInclusionCycleError(roleID string) *RoleInclusionCycleError {
+	return &RoleInclusionCycleError{roleID: roleID}
+}
+
+func (e *RoleInclusionCycleError) Error() string {
+	return fmt.Sprintf("role %s can't include itself", e.roleID)
+}
+
+// Serialize returns the error serialized
+func (e *RoleInclusionCycleError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-009",
+		"error":       "RoleInclusionCycleError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *RoleInclusionCycleError) StatusCode() int {
+	return 422
+}
@@ -0,0 +1,118 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ServiceAccountInactiveError happens when a deactivated service account
+// tries to authenticate
+type ServiceAccountInactiveError struct {
+	serviceAccountID string
+}
+
+// NewServiceAccountInactiveError ctor
+func NewServiceAccountInactiveError(
+	serviceAccountID string,
+) *ServiceAccountInactiveError {
+	return &ServiceAccountInactiveError{serviceAccountID: serviceAccountID}
+}
+
+func (e *ServiceAccountInactiveError) Error() string {
+	return fmt.Sprintf("service account %s is deactivated", e.serviceAccountID)
+}
+
+// Serialize returns the error serialized
+func (e *ServiceAccountInactiveError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-010",
+		"error":       "ServiceAccountInactiveError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *ServiceAccountInactiveError) StatusCode() int {
+	return 401
+}
+
+// OAuth2ServiceAccountKeyError happens when access keys are requested for a
+// service account people log in as with OAuth2
+type OAuth2ServiceAccountKeyError struct {
+	serviceAccountID string
+}
+
+// NewOAuth2ServiceAccountKeyError ctor
+func NewOAuth2ServiceAccountKeyError(
+	serviceAccountID string,
+) *OAuth2ServiceAccountKeyError {
+	return &OAuth2ServiceAccountKeyError{serviceAccountID: serviceAccountID}
+}
+
+func (e *OAuth2ServiceAccountKeyError) Error() string {
+	return fmt.Sprintf(
+		"service account %s authenticates with OAuth2 and can't have access keys",
+		e.serviceAccountID,
+	)
+}
+
+// Serialize returns the error serialized
+func (e *OAuth2ServiceAccountKeyError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-018",
+		"error":       "OAuth2ServiceAccountKeyError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *OAuth2ServiceAccountKeyError) StatusCode() int {
+	return 422
+}
+
+// ServiceAccountBacksServiceError happens when deleting a service account
+// a service is backed by
+type ServiceAccountBacksServiceError struct {
+	serviceAccountID string
+	serviceID        string
+}
+
+// NewServiceAccountBacksServiceError ctor
+func NewServiceAccountBacksServiceError(
+	serviceAccountID, serviceID string,
+) *ServiceAccountBacksServiceError {
+	return &ServiceAccountBacksServiceError{
+		serviceAccountID: serviceAccountID,
+		serviceID:        serviceID,
+	}
+}
+
+func (e *ServiceAccountBacksServiceError) Error() string {
+	return fmt.Sprintf(
+		"service account %s backs service %s and can't be deleted",
+		e.serviceAccountID, e.serviceID,
+	)
+}
+
+// Serialize returns the error serialized
+func (e *ServiceAccountBacksServiceError) Serialize() []byte {
+	g, _ := json.Marshal(map[string]interface{}{
+		"code":        "ERR-019",
+		"error":       "ServiceAccountBacksServiceError",
+		"description": e.Error(),
+		"success":     false,
+	})
+
+	return g
+}
+
+// StatusCode implements ErrorWithStatusCode
+func (e *ServiceAccountBacksServiceError) StatusCode() int {
+	return 409
+}
@@ -0,0 +1,204 @@
+// Package ldap authenticates users against an LDAP or Active Directory
+// server with their username and password, for where no OAuth2 provider
+// can be reached
+package ldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	goLDAP "github.com/go-ldap/ldap/v3"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// ProviderName is the provider idp group role mappings of directory groups
+// are registered under
+const ProviderName = "ldap"
+
+// Config are the basic required informations to authenticate against a
+// directory, when Enabled: users are searched for under BaseDN with UserFilter, where
+// %s is the username, binding as BindDN, and then bound as to check their
+// password
+// Passwords are sent in the clear over ldap:// URLs, so these need StartTLS
+// unless Insecure is explicitly set
+// People are linked by email to accounts other providers created, so only
+// emails of HostedDomains, which the directory must be authoritative for,
+// are accepted
+type Config struct {
+	Enabled         bool
+	URL             string
+	StartTLS        bool
+	Insecure        bool
+	BindDN          string
+	BindPassword    string
+	BaseDN          string
+	UserFilter      string
+	EmailAttribute  string
+	NameAttribute   string
+	GroupsAttribute string
+	HostedDomains   []string
+	Timeout         time.Duration
+}
+
+func loadDefaultConfig(config *viper.Viper) {
+	config.SetDefault("ldap.userFilter", "(uid=%s)")
+	config.SetDefault("ldap.emailAttribute", "mail")
+	config.SetDefault("ldap.nameAttribute", "displayName")
+	config.SetDefault("ldap.groupsAttribute", "memberOf")
+	config.SetDefault("ldap.timeout", "10s")
+}
+
+// GetConfig reads Config from config, under ldap
+func GetConfig(config *viper.Viper) (Config, error) {
+	loadDefaultConfig(config)
+	c := Config{
+		Enabled:         config.GetBool("ldap.enabled"),
+		URL:             config.GetString("ldap.url"),
+		StartTLS:        config.GetBool("ldap.startTLS"),
+		Insecure:        config.GetBool("ldap.insecure"),
+		BindDN:          config.GetString("ldap.bindDn"),
+		BindPassword:    config.GetString("ldap.bindPassword"),
+		BaseDN:          config.GetString("ldap.baseDn"),
+		UserFilter:      config.GetString("ldap.userFilter"),
+		EmailAttribute:  config.GetString("ldap.emailAttribute"),
+		NameAttribute:   config.GetString("ldap.nameAttribute"),
+		GroupsAttribute: config.GetString("ldap.groupsAttribute"),
+		HostedDomains:   config.GetStringSlice("ldap.hostedDomains"),
+		Timeout:         config.GetDuration("ldap.timeout"),
+	}
+	if c.Enabled {
+		if err := c.checkTransport(); err != nil {
+			return Config{}, err
+		}
+		if len(c.HostedDomains) == 0 {
+			return Config{}, fmt.Errorf("ldap.hostedDomains is required")
+		}
+	}
+	return c, nil
+}
+
+// allowsEmail checks if email is of one of HostedDomains
+func (c Config) allowsEmail(email string) bool {
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, allowed := range c.HostedDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransport refuses URLs passwords would be sent in the clear to
+func (c Config) checkTransport() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "ldaps":
+		return nil
+	case "ldap":
+		if c.StartTLS || c.Insecure {
+			return nil
+		}
+		return fmt.Errorf(
+			"ldap.url %s is unencrypted: set ldap.startTLS, or ldap.insecure to allow it",
+			c.URL,
+		)
+	default:
+		return fmt.Errorf("ldap.url %s must be ldap:// or ldaps://", c.URL)
+	}
+}
+
+// Authenticator is the contract directory authentication must follow
+type Authenticator interface {
+	Authenticate(string, string) (*models.AuthResult, error)
+}
+
+// LDAP implements Authenticator
+type LDAP struct {
+	config Config
+}
+
+// New ctor
+func New(config Config) *LDAP {
+	return &LDAP{config: config}
+}
+
+// Authenticate checks password is the one of the user named username,
+// returning its email, name and groups, the latter as lower case DNs
+func (l *LDAP) Authenticate(
+	username, password string,
+) (*models.AuthResult, error) {
+	// binding with an empty password is an anonymous bind, which succeeds
+	if username == "" || password == "" {
+		return nil, errors.NewInvalidCredentialsError(username)
+	}
+	if err := l.config.checkTransport(); err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: l.config.Timeout}
+	conn, err := goLDAP.DialURL(l.config.URL, goLDAP.DialWithDialer(dialer))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetTimeout(l.config.Timeout)
+	if l.config.StartTLS {
+		u, _ := url.Parse(l.config.URL)
+		if err := conn.StartTLS(&tls.Config{ServerName: u.Hostname()}); err != nil {
+			return nil, err
+		}
+	}
+	if err := conn.Bind(l.config.BindDN, l.config.BindPassword); err != nil {
+		return nil, err
+	}
+	res, err := conn.Search(goLDAP.NewSearchRequest(
+		l.config.BaseDN, goLDAP.ScopeWholeSubtree, goLDAP.NeverDerefAliases,
+		0, int(l.config.Timeout.Seconds()), false,
+		fmt.Sprintf(l.config.UserFilter, goLDAP.EscapeFilter(username)),
+		[]string{
+			l.config.EmailAttribute, l.config.NameAttribute,
+			l.config.GroupsAttribute,
+		}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Entries) != 1 {
+		return nil, errors.NewInvalidCredentialsError(username)
+	}
+	entry := res.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if goLDAP.IsErrorWithCode(err, goLDAP.LDAPResultInvalidCredentials) {
+			return nil, errors.NewInvalidCredentialsError(username)
+		}
+		return nil, err
+	}
+	email := entry.GetAttributeValue(l.config.EmailAttribute)
+	if email == "" {
+		return nil, fmt.Errorf(
+			"%s has no %s attribute", entry.DN, l.config.EmailAttribute,
+		)
+	}
+	if !l.config.allowsEmail(email) {
+		return nil, errors.NewNonAllowedEmailDomainError(
+			email[strings.LastIndex(email, "@")+1:],
+		)
+	}
+	groups := entry.GetAttributeValues(l.config.GroupsAttribute)
+	for i := range groups {
+		groups[i] = strings.ToLower(groups[i])
+	}
+	return &models.AuthResult{
+		Email:  email,
+		Name:   entry.GetAttributeValue(l.config.NameAttribute),
+		Groups: groups,
+	}, nil
+}
@@ -0,0 +1,318 @@
+// +build unit
+
+package ldap_test
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	goLDAP "github.com/go-ldap/ldap/v3"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/ldap"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+type directoryEntry struct {
+	password   string
+	attributes map[string][]string
+}
+
+// directory is an in-process LDAP server that only knows simple binds and
+// searches by equality filters, enough for ldap.LDAP
+type directory struct {
+	listener net.Listener
+	entries  map[string]directoryEntry
+}
+
+var equalityFilter = regexp.MustCompile(`^\(([^=]+)=([^)]*)\)$`)
+
+func newDirectory(t *testing.T, entries map[string]directoryEntry) *directory {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	d := &directory{listener: listener, entries: entries}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+func (d *directory) URL() string {
+	return fmt.Sprintf("ldap://%s", d.listener.Addr().String())
+}
+
+func (d *directory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		req, err := ber.ReadPacket(conn)
+		if err != nil || len(req.Children) < 2 {
+			return
+		}
+		id := req.Children[0].Value.(int64)
+		op := req.Children[1]
+		switch op.Tag {
+		case goLDAP.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+			code := goLDAP.LDAPResultInvalidCredentials
+			if e, ok := d.entries[dn]; ok && e.password == password {
+				code = goLDAP.LDAPResultSuccess
+			}
+			d.respond(conn, id, goLDAP.ApplicationBindResponse, code)
+		case goLDAP.ApplicationSearchRequest:
+			filter, _ := goLDAP.DecompileFilter(op.Children[6])
+			m := equalityFilter.FindStringSubmatch(filter)
+			for dn, e := range d.entries {
+				if m == nil || len(e.attributes[m[1]]) == 0 ||
+					!matchesFilterValue(e.attributes[m[1]][0], m[2]) {
+					continue
+				}
+				entry := ber.Encode(
+					ber.ClassApplication, ber.TypeConstructed,
+					goLDAP.ApplicationSearchResultEntry, nil, "",
+				)
+				entry.AppendChild(ber.NewString(
+					ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "",
+				))
+				attributes := ber.NewSequence("")
+				for name, values := range e.attributes {
+					attribute := ber.NewSequence("")
+					attribute.AppendChild(ber.NewString(
+						ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "",
+					))
+					set := ber.Encode(
+						ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "",
+					)
+					for _, v := range values {
+						set.AppendChild(ber.NewString(
+							ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "",
+						))
+					}
+					attribute.AppendChild(set)
+					attributes.AppendChild(attribute)
+				}
+				entry.AppendChild(attributes)
+				d.write(conn, id, entry)
+			}
+			d.respond(
+				conn, id, goLDAP.ApplicationSearchResultDone, goLDAP.LDAPResultSuccess,
+			)
+		default:
+			return
+		}
+	}
+}
+
+// matchesFilterValue matches equality and prefix substring filters
+func matchesFilterValue(value, filterValue string) bool {
+	if strings.HasSuffix(filterValue, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(filterValue, "*"))
+	}
+	return value == filterValue
+}
+
+func (d *directory) respond(conn net.Conn, id int64, tag ber.Tag, code int) {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	res.AppendChild(ber.NewInteger(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "",
+	))
+	res.AppendChild(ber.NewString(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "",
+	))
+	res.AppendChild(ber.NewString(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "",
+	))
+	d.write(conn, id, res)
+}
+
+func (d *directory) write(conn net.Conn, id int64, op *ber.Packet) {
+	msg := ber.NewSequence("")
+	msg.AppendChild(ber.NewInteger(
+		ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "",
+	))
+	msg.AppendChild(op)
+	conn.Write(msg.Bytes())
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	d := newDirectory(t, map[string]directoryEntry{
+		"cn=admin,dc=example,dc=org": {password: "admin password"},
+		"uid=alice,ou=people,dc=example,dc=org": {
+			password: "alice password",
+			attributes: map[string][]string{
+				"uid":         {"alice"},
+				"mail":        {"alice@example.org"},
+				"displayName": {"Alice"},
+				"memberOf": {
+					"CN=Developers,OU=Groups,DC=example,DC=org",
+					"cn=admins,ou=groups,dc=example,dc=org",
+				},
+			},
+		},
+		"uid=bob,ou=people,dc=example,dc=org": {
+			password:   "bob password",
+			attributes: map[string][]string{"uid": {"bob"}},
+		},
+		"uid=mallory,ou=people,dc=example,dc=org": {
+			password: "mallory password",
+			attributes: map[string][]string{
+				"uid":  {"mallory"},
+				"mail": {"admin@other.org"},
+			},
+		},
+	})
+	defer d.listener.Close()
+	config := ldap.Config{
+		URL:             d.URL(),
+		Insecure:        true,
+		BindDN:          "cn=admin,dc=example,dc=org",
+		BindPassword:    "admin password",
+		BaseDN:          "dc=example,dc=org",
+		UserFilter:      "(uid=%s)",
+		EmailAttribute:  "mail",
+		NameAttribute:   "displayName",
+		GroupsAttribute: "memberOf",
+		HostedDomains:   []string{"Example.org"},
+		Timeout:         time.Second,
+	}
+
+	t.Run("valid credentials", func(t *testing.T) {
+		authResult, err := ldap.New(config).Authenticate("alice", "alice password")
+		if err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+		want := &models.AuthResult{
+			Email: "alice@example.org",
+			Name:  "Alice",
+			Groups: []string{
+				"cn=developers,ou=groups,dc=example,dc=org",
+				"cn=admins,ou=groups,dc=example,dc=org",
+			},
+		}
+		if !reflect.DeepEqual(authResult, want) {
+			t.Errorf("Expected %#v. Got %#v", want, authResult)
+		}
+	})
+
+	tt := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{"wrong password", "alice", "bob password"},
+		{"unknown user", "carol", "carol password"},
+		{"empty password", "alice", ""},
+		{"filter injection", "alic*", "alice password"},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ldap.New(config).Authenticate(tt.username, tt.password)
+			if _, ok := err.(*errors.InvalidCredentialsError); !ok {
+				t.Errorf("Expected InvalidCredentialsError. Got %v", err)
+			}
+		})
+	}
+
+	t.Run("no email", func(t *testing.T) {
+		_, err := ldap.New(config).Authenticate("bob", "bob password")
+		if err == nil {
+			t.Error("Expected error. Got nil")
+		}
+		if _, ok := err.(*errors.InvalidCredentialsError); ok {
+			t.Errorf("Expected a configuration error. Got %s", err.Error())
+		}
+	})
+
+	t.Run("non allowed email domain", func(t *testing.T) {
+		_, err := ldap.New(config).Authenticate("mallory", "mallory password")
+		if _, ok := err.(*errors.NonAllowedEmailDomainError); !ok {
+			t.Errorf("Expected NonAllowedEmailDomainError. Got %v", err)
+		}
+	})
+
+	t.Run("wrong bind password", func(t *testing.T) {
+		c := config
+		c.BindPassword = "wrong"
+		_, err := ldap.New(c).Authenticate("alice", "alice password")
+		if err == nil {
+			t.Error("Expected error. Got nil")
+		}
+		if _, ok := err.(*errors.InvalidCredentialsError); ok {
+			t.Errorf("Expected a configuration error. Got %s", err.Error())
+		}
+	})
+	t.Run("unencrypted url", func(t *testing.T) {
+		c := config
+		c.Insecure = false
+		_, err := ldap.New(c).Authenticate("alice", "alice password")
+		if err == nil {
+			t.Error("Expected error. Got nil")
+		}
+		if _, ok := err.(*errors.InvalidCredentialsError); ok {
+			t.Errorf("Expected a configuration error. Got %s", err.Error())
+		}
+	})
+
+	t.Run("start tls not supported by server", func(t *testing.T) {
+		c := config
+		c.Insecure = false
+		c.StartTLS = true
+		if _, err := ldap.New(c).Authenticate("alice", "alice password"); err == nil {
+			t.Error("Expected error. Got nil")
+		}
+	})
+}
+
+func TestLDAPGetConfig(t *testing.T) {
+	tt := []struct {
+		name     string
+		url      string
+		startTLS bool
+		insecure bool
+		valid    bool
+	}{
+		{"ldaps", "ldaps://ldap.example.com:636", false, false, true},
+		{"ldap", "ldap://ldap.example.com:389", false, false, false},
+		{"ldap with start tls", "ldap://ldap.example.com:389", true, false, true},
+		{"ldap insecure", "ldap://ldap.example.com:389", false, true, true},
+		{"other scheme", "http://ldap.example.com", false, true, false},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			config := viper.New()
+			config.Set("ldap.enabled", true)
+			config.Set("ldap.url", tt.url)
+			config.Set("ldap.startTLS", tt.startTLS)
+			config.Set("ldap.insecure", tt.insecure)
+			config.Set("ldap.hostedDomains", []string{"example.com"})
+			_, err := ldap.GetConfig(config)
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid to be %t. Got %v", tt.valid, err)
+			}
+		})
+	}
+
+	t.Run("no hosted domains", func(t *testing.T) {
+		config := viper.New()
+		config.Set("ldap.enabled", true)
+		config.Set("ldap.url", "ldaps://ldap.example.com:636")
+		if _, err := ldap.GetConfig(config); err == nil {
+			t.Error("Expected error. Got nil")
+		}
+	})
+}
@@ -0,0 +1,5 @@
+DROP FUNCTION IF EXISTS service_account_permissions;
+ALTER TABLE role_bindings DROP COLUMN expires_at;
+ALTER TABLE role_bindings DROP COLUMN not_before;
+ALTER TABLE permissions DROP COLUMN expires_at;
+ALTER TABLE permissions DROP COLUMN not_before;
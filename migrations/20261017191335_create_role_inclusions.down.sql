@@ -0,0 +1,25 @@
+CREATE OR REPLACE FUNCTION service_account_permissions(uuid) RETURNS TABLE (
+  id UUID,
+  role_id UUID,
+  service VARCHAR,
+  ownership_level VARCHAR,
+  action VARCHAR,
+  resource_hierarchy VARCHAR,
+  alias VARCHAR,
+  not_before TIMESTAMP WITH TIME ZONE,
+  expires_at TIMESTAMP WITH TIME ZONE
+) AS $$
+  SELECT p.id, p.role_id, p.service, p.ownership_level, p.action, p.resource_hierarchy, p.alias,
+    GREATEST(p.not_before, rb.not_before), LEAST(p.expires_at, rb.expires_at)
+  FROM permissions p
+  JOIN role_bindings rb ON rb.role_id = p.role_id
+  WHERE rb.service_account_id = $1
+    AND (p.not_before IS NULL OR p.not_before <= now())
+    AND (p.expires_at IS NULL OR p.expires_at > now())
+    AND (rb.not_before IS NULL OR rb.not_before <= now())
+    AND (rb.expires_at IS NULL OR rb.expires_at > now())
+$$ LANGUAGE sql STABLE;
+
+DROP INDEX IF EXISTS role_inclusions_included_role;
+DROP INDEX IF EXISTS role_inclusions_unique;
+DROP TABLE IF EXISTS role_inclusions;
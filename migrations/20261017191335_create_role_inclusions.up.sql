@@ -0,0 +1,44 @@
+CREATE TABLE IF NOT EXISTS role_inclusions (
+	id UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	role_id UUID NOT NULL,
+	included_role_id UUID NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  FOREIGN KEY(role_id) REFERENCES roles (id) ON DELETE CASCADE,
+  FOREIGN KEY(included_role_id) REFERENCES roles (id) ON DELETE CASCADE,
+  CHECK (role_id != included_role_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS role_inclusions_unique ON role_inclusions (role_id, included_role_id);
+
+CREATE INDEX role_inclusions_included_role ON role_inclusions (included_role_id);
+
+-- permissions a service account has right now, through role bindings that are also active now
+-- and every role they include, transitively
+CREATE OR REPLACE FUNCTION service_account_permissions(uuid) RETURNS TABLE (
+  id UUID,
+  role_id UUID,
+  service VARCHAR,
+  ownership_level VARCHAR,
+  action VARCHAR,
+  resource_hierarchy VARCHAR,
+  alias VARCHAR,
+  not_before TIMESTAMP WITH TIME ZONE,
+  expires_at TIMESTAMP WITH TIME ZONE
+) AS $$
+  WITH RECURSIVE sa_roles (role_id, not_before, expires_at) AS (
+    SELECT rb.role_id, rb.not_before, rb.expires_at FROM role_bindings rb
+    WHERE rb.service_account_id = $1
+      AND (rb.not_before IS NULL OR rb.not_before <= now())
+      AND (rb.expires_at IS NULL OR rb.expires_at > now())
+    UNION
+    SELECT ri.included_role_id, sr.not_before, sr.expires_at FROM role_inclusions ri
+    JOIN sa_roles sr ON sr.role_id = ri.role_id
+  )
+  SELECT p.id, p.role_id, p.service, p.ownership_level, p.action, p.resource_hierarchy, p.alias,
+    GREATEST(p.not_before, sr.not_before), LEAST(p.expires_at, sr.expires_at)
+  FROM permissions p
+  JOIN sa_roles sr ON sr.role_id = p.role_id
+  WHERE (p.not_before IS NULL OR p.not_before <= now())
+    AND (p.expires_at IS NULL OR p.expires_at > now())
+$$ LANGUAGE sql STABLE;
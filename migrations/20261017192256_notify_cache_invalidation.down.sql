@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS tokens_cache_invalidation ON tokens;
+DROP TRIGGER IF EXISTS role_inclusions_cache_invalidation ON role_inclusions;
+DROP TRIGGER IF EXISTS roles_cache_invalidation ON roles;
+DROP TRIGGER IF EXISTS role_bindings_cache_invalidation ON role_bindings;
+DROP TRIGGER IF EXISTS permissions_cache_invalidation ON permissions;
+
+DROP FUNCTION IF EXISTS notify_cache_invalidation();
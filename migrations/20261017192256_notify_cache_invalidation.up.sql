@@ -0,0 +1,23 @@
+-- tells every Will.IAM instance listening on will_iam_cache_invalidation which table changed,
+-- so cached permissions and tokens are dropped
+CREATE OR REPLACE FUNCTION notify_cache_invalidation() RETURNS TRIGGER AS $$
+BEGIN
+  PERFORM pg_notify('will_iam_cache_invalidation', TG_TABLE_NAME);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER permissions_cache_invalidation AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+  ON permissions FOR EACH STATEMENT EXECUTE PROCEDURE notify_cache_invalidation();
+
+CREATE TRIGGER role_bindings_cache_invalidation AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+  ON role_bindings FOR EACH STATEMENT EXECUTE PROCEDURE notify_cache_invalidation();
+
+CREATE TRIGGER roles_cache_invalidation AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+  ON roles FOR EACH STATEMENT EXECUTE PROCEDURE notify_cache_invalidation();
+
+CREATE TRIGGER role_inclusions_cache_invalidation AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+  ON role_inclusions FOR EACH STATEMENT EXECUTE PROCEDURE notify_cache_invalidation();
+
+CREATE TRIGGER tokens_cache_invalidation AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE
+  ON tokens FOR EACH STATEMENT EXECUTE PROCEDURE notify_cache_invalidation();
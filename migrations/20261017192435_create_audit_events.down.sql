@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+DROP FUNCTION IF EXISTS reject_audit_events_change();
+DROP INDEX IF EXISTS audit_events_action;
+DROP INDEX IF EXISTS audit_events_target;
+DROP INDEX IF EXISTS audit_events_actor;
+DROP INDEX IF EXISTS audit_events_created_at;
+DROP TABLE IF EXISTS audit_events;
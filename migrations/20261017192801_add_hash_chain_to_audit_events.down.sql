@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS audit_events_seq;
+
+ALTER TABLE audit_events DROP COLUMN IF EXISTS hash;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS previous_hash;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS seq;
+
+ALTER TABLE audit_events ALTER COLUMN after TYPE JSONB USING after::jsonb;
+ALTER TABLE audit_events ALTER COLUMN before TYPE JSONB USING before::jsonb;
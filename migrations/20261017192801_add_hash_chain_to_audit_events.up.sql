@@ -0,0 +1,11 @@
+-- before and after are kept as the exact JSON text hashed by Will.IAM, which JSONB would normalize
+ALTER TABLE audit_events ALTER COLUMN before TYPE JSON USING before::json;
+ALTER TABLE audit_events ALTER COLUMN after TYPE JSON USING after::json;
+
+-- seq orders the hash chain; events recorded before this migration keep a NULL hash and
+-- are left out of it
+ALTER TABLE audit_events ADD COLUMN seq BIGSERIAL NOT NULL;
+ALTER TABLE audit_events ADD COLUMN previous_hash VARCHAR(64);
+ALTER TABLE audit_events ADD COLUMN hash VARCHAR(64);
+
+CREATE UNIQUE INDEX audit_events_seq ON audit_events (seq);
@@ -0,0 +1,5 @@
+-- plaintext secrets can't be recovered from their hashes: key pairs of existing service
+-- accounts stop working and must be recreated
+ALTER TABLE service_accounts ADD COLUMN key_secret VARCHAR(200);
+
+ALTER TABLE service_accounts DROP COLUMN IF EXISTS key_secret_hash;
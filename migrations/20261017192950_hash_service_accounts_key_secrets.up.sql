@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+ALTER TABLE service_accounts ADD COLUMN key_secret_hash VARCHAR(200);
+
+-- pgcrypto bf hashes are bcrypt hashes, as checked by Will.IAM
+UPDATE service_accounts SET key_secret_hash = crypt(key_secret, gen_salt('bf', 10))
+WHERE key_secret IS NOT NULL AND key_secret != '';
+
+ALTER TABLE service_accounts DROP COLUMN key_secret;
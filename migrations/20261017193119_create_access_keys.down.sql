@@ -0,0 +1,8 @@
+ALTER TABLE service_accounts ADD COLUMN key_secret_hash VARCHAR(200);
+
+UPDATE service_accounts sa SET key_secret_hash = ak.key_secret_hash
+FROM access_keys ak WHERE ak.key_id = sa.key_id;
+
+DROP INDEX IF EXISTS access_keys_service_account;
+DROP INDEX IF EXISTS access_keys_key_id;
+DROP TABLE IF EXISTS access_keys;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS access_keys (
+	id UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	service_account_id UUID NOT NULL,
+	label VARCHAR(200) NOT NULL,
+	key_id VARCHAR(200) NOT NULL,
+	key_secret_hash VARCHAR(200) NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT true,
+	expires_at TIMESTAMP WITH TIME ZONE,
+	last_used_at TIMESTAMP WITH TIME ZONE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  FOREIGN KEY(service_account_id) REFERENCES service_accounts (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS access_keys_key_id ON access_keys (key_id);
+
+CREATE INDEX access_keys_service_account ON access_keys (service_account_id);
+
+-- the key created along with each key pair service account becomes its first access key;
+-- service_accounts.key_id is kept to tell key pair service accounts apart
+INSERT INTO access_keys (service_account_id, label, key_id, key_secret_hash)
+SELECT id, 'default', key_id, key_secret_hash FROM service_accounts
+WHERE key_id IS NOT NULL AND key_id != '' AND key_secret_hash IS NOT NULL;
+
+ALTER TABLE service_accounts DROP COLUMN key_secret_hash;
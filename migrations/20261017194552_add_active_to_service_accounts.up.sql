@@ -0,0 +1 @@
+ALTER TABLE service_accounts ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,5 @@
+ALTER TABLE tokens ALTER COLUMN refresh_token TYPE VARCHAR(300);
+ALTER TABLE tokens ALTER COLUMN access_token TYPE VARCHAR(300);
+
+DROP INDEX IF EXISTS signing_keys_created_at;
+DROP TABLE IF EXISTS signing_keys;
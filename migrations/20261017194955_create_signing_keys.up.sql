@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+	id UUID PRIMARY KEY NOT NULL,
+	private_key TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS signing_keys_created_at ON signing_keys (created_at);
+
+-- provider tokens may be JWTs, longer than 300 characters
+ALTER TABLE tokens ALTER COLUMN access_token TYPE TEXT;
+ALTER TABLE tokens ALTER COLUMN refresh_token TYPE TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS revoked_access_tokens_expires_at;
+DROP TABLE IF EXISTS revoked_access_tokens;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+	jti UUID PRIMARY KEY NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS revoked_access_tokens_expires_at ON revoked_access_tokens (expires_at);
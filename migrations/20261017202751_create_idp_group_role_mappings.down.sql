@@ -0,0 +1,4 @@
+ALTER TABLE role_bindings DROP COLUMN IF EXISTS from_group;
+DROP INDEX IF EXISTS idp_group_role_mappings_role;
+DROP INDEX IF EXISTS idp_group_role_mappings_unique;
+DROP TABLE IF EXISTS idp_group_role_mappings;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS idp_group_role_mappings (
+	id UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	provider VARCHAR(200) NOT NULL,
+	group_name VARCHAR(1000) NOT NULL,
+	role_id UUID NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  FOREIGN KEY(role_id) REFERENCES roles (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idp_group_role_mappings_unique
+ON idp_group_role_mappings (provider, lower(group_name), role_id);
+
+CREATE INDEX IF NOT EXISTS idp_group_role_mappings_role ON idp_group_role_mappings (role_id);
+
+-- bindings made by syncing IdP groups, which the next sync may remove;
+-- manually made ones are never touched by it
+ALTER TABLE role_bindings ADD COLUMN IF NOT EXISTS from_group BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE services ADD COLUMN IF NOT EXISTS redirect_origins TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS oauth2_authorization_codes_code_hash;
+DROP TABLE IF EXISTS oauth2_authorization_codes;
+ALTER TABLE services DROP COLUMN IF EXISTS redirect_uris;
@@ -0,0 +1,20 @@
+-- services are OAuth2 clients of the authorization server once they have
+-- redirect URIs
+ALTER TABLE services ADD COLUMN IF NOT EXISTS redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS oauth2_authorization_codes (
+	id UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	code_hash VARCHAR(64) NOT NULL,
+	client_id UUID NOT NULL,
+	service_account_id UUID NOT NULL,
+	redirect_uri VARCHAR(2000) NOT NULL,
+	code_challenge VARCHAR(128) NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  FOREIGN KEY(client_id) REFERENCES services (id) ON DELETE CASCADE,
+  FOREIGN KEY(service_account_id) REFERENCES service_accounts (id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS oauth2_authorization_codes_code_hash
+ON oauth2_authorization_codes (code_hash);
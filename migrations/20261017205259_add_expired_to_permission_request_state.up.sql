@@ -0,0 +1 @@
+ALTER TYPE permission_request_state ADD VALUE IF NOT EXISTS 'expired';
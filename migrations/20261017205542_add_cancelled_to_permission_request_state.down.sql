@@ -0,0 +1,2 @@
+-- Postgres can't drop enum values, so 'cancelled' is kept unused
+UPDATE permissions_requests SET state = 'denied' WHERE state = 'cancelled';
@@ -0,0 +1 @@
+ALTER TABLE permissions_requests DROP COLUMN IF EXISTS duration;
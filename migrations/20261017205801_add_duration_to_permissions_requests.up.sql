@@ -0,0 +1,2 @@
+-- in seconds; 0 grants the permission with no expiration
+ALTER TABLE permissions_requests ADD COLUMN IF NOT EXISTS duration BIGINT NOT NULL DEFAULT 0;
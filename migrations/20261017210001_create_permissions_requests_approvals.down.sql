@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS permissions_requests_approvals_request_service_account;
+DROP TABLE IF EXISTS permissions_requests_approvals;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS permissions_requests_approvals (
+  id UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+  permission_request_id UUID NOT NULL,
+  service_account_id UUID NOT NULL,
+  duration BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  FOREIGN KEY (permission_request_id) REFERENCES permissions_requests (id) ON DELETE CASCADE,
+  FOREIGN KEY (service_account_id) REFERENCES service_accounts (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS permissions_requests_approvals_request_service_account
+  ON permissions_requests_approvals (permission_request_id, service_account_id);
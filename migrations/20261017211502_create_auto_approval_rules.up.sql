@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS auto_approval_rules (
+  id UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+  permission VARCHAR(2000) NOT NULL,
+  hosted_domain VARCHAR(255),
+  role_id UUID,
+  duration BIGINT NOT NULL DEFAULT 0,
+  creator_service_account_id UUID NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+  FOREIGN KEY (role_id) REFERENCES roles (id) ON DELETE CASCADE,
+  FOREIGN KEY (creator_service_account_id) REFERENCES service_accounts (id) ON DELETE CASCADE
+);
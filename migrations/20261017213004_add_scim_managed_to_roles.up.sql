@@ -0,0 +1 @@
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS scim_managed BOOLEAN NOT NULL DEFAULT false;
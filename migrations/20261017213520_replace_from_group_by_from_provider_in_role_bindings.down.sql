@@ -0,0 +1,3 @@
+ALTER TABLE role_bindings ADD COLUMN IF NOT EXISTS from_group BOOLEAN NOT NULL DEFAULT false;
+UPDATE role_bindings SET from_group = true WHERE from_provider IS NOT NULL;
+ALTER TABLE role_bindings DROP COLUMN IF EXISTS from_provider;
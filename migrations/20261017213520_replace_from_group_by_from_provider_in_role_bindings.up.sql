@@ -0,0 +1,12 @@
+-- provider whose groups the binding was made from; NULL for manual bindings
+ALTER TABLE role_bindings ADD COLUMN IF NOT EXISTS from_provider VARCHAR(200);
+
+UPDATE role_bindings rb SET from_provider = m.provider
+FROM idp_group_role_mappings m
+WHERE rb.from_group AND m.role_id = rb.role_id;
+
+-- bindings from groups whose mappings are all gone would never be synced
+-- away, as no provider knows of them anymore
+DELETE FROM role_bindings WHERE from_group AND from_provider IS NULL;
+
+ALTER TABLE role_bindings DROP COLUMN IF EXISTS from_group;
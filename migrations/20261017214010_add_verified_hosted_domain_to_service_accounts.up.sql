@@ -0,0 +1 @@
+ALTER TABLE service_accounts ADD COLUMN IF NOT EXISTS verified_hosted_domain TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+ALTER TABLE services DROP CONSTRAINT IF EXISTS services_service_account_id_fkey;
+ALTER TABLE services ADD CONSTRAINT services_service_account_id_fkey
+  FOREIGN KEY (service_account_id) REFERENCES service_accounts (id) ON DELETE CASCADE;
+DELETE FROM permissions_requests WHERE service_account_id IS NULL;
+ALTER TABLE permissions_requests DROP CONSTRAINT IF EXISTS permissions_requests_service_account_id_fkey;
+ALTER TABLE permissions_requests ADD CONSTRAINT permissions_requests_service_account_id_fkey
+  FOREIGN KEY (service_account_id) REFERENCES service_accounts (id) ON DELETE CASCADE;
+ALTER TABLE permissions_requests ALTER COLUMN service_account_id SET NOT NULL;
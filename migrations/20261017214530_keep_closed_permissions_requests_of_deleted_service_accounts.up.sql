@@ -0,0 +1,9 @@
+-- closed requests are history and outlive their requesters; services
+-- can't lose the service account they're backed by
+ALTER TABLE permissions_requests ALTER COLUMN service_account_id DROP NOT NULL;
+ALTER TABLE permissions_requests DROP CONSTRAINT IF EXISTS permissions_requests_service_account_id_fkey;
+ALTER TABLE permissions_requests ADD CONSTRAINT permissions_requests_service_account_id_fkey
+  FOREIGN KEY (service_account_id) REFERENCES service_accounts (id) ON DELETE SET NULL;
+ALTER TABLE services DROP CONSTRAINT IF EXISTS services_service_account_id_fkey;
+ALTER TABLE services ADD CONSTRAINT services_service_account_id_fkey
+  FOREIGN KEY (service_account_id) REFERENCES service_accounts (id) ON DELETE RESTRICT;
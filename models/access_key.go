@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/gofrs/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// keySecretHashCost is the bcrypt cost of key secret hashes
+const keySecretHashCost = bcrypt.DefaultCost
+
+// AccessKey is one of the key pairs a service account authenticates with
+// KeySecret is only kept in memory, so it can be shown once, right after
+// being generated; storage only has KeySecretHash
+type AccessKey struct {
+	ID               string      `json:"id" pg:"id"`
+	ServiceAccountID string      `json:"serviceAccountId" pg:"service_account_id"`
+	Label            string      `json:"label" pg:"label"`
+	KeyID            string      `json:"keyId" pg:"key_id"`
+	KeySecret        string      `json:"keySecret,omitempty" pg:"-"`
+	KeySecretHash    string      `json:"-" pg:"key_secret_hash"`
+	Active           bool        `json:"active" pg:"active" sql:",notnull"`
+	ExpiresAt        pg.NullTime `json:"expiresAt" pg:"expires_at"`
+	LastUsedAt       pg.NullTime `json:"lastUsedAt" pg:"last_used_at"`
+	CreatedUpdatedAt
+}
+
+// BuildAccessKey generates an active access key with random KeyID and
+// KeySecret, and hashes KeySecret
+func BuildAccessKey(saID, label string) (*AccessKey, error) {
+	ak := &AccessKey{
+		ServiceAccountID: saID,
+		Label:            label,
+		KeyID:            uuid.Must(uuid.NewV4()).String(),
+		KeySecret:        uuid.Must(uuid.NewV4()).String(),
+		Active:           true,
+	}
+	if err := ak.HashKeySecret(); err != nil {
+		return nil, err
+	}
+	return ak, nil
+}
+
+// HashKeySecret sets ak KeySecretHash from its KeySecret
+func (ak *AccessKey) HashKeySecret() error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(ak.KeySecret), keySecretHashCost)
+	if err != nil {
+		return err
+	}
+	ak.KeySecretHash = string(hash)
+	return nil
+}
+
+// KeySecretMatches checks keySecret against ak KeySecretHash
+func (ak AccessKey) KeySecretMatches(keySecret string) bool {
+	return bcrypt.CompareHashAndPassword(
+		[]byte(ak.KeySecretHash), []byte(keySecret),
+	) == nil
+}
+
+// Usable checks if ak is active and not expired at t
+func (ak AccessKey) Usable(t time.Time) bool {
+	return ak.Active && (ak.ExpiresAt.IsZero() || t.Before(ak.ExpiresAt.Time))
+}
+
+// Validate AccessKey fields set by clients
+func (ak AccessKey) Validate() Validation {
+	v := &Validation{}
+	if ak.Label == "" {
+		v.AddError("label", "required")
+	}
+	if !ak.ExpiresAt.IsZero() && !ak.ExpiresAt.After(time.Now()) {
+		v.AddError("expiresAt", "must be in the future")
+	}
+	return *v
+}
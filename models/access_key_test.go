@@ -0,0 +1,73 @@
+// +build unit
+
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func TestBuildAccessKeyHashesKeySecret(t *testing.T) {
+	ak, err := models.BuildAccessKey("some sa", "some label")
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if ak.KeySecretHash == "" || ak.KeySecretHash == ak.KeySecret {
+		t.Fatalf("Expected a hash of the key secret. Got %s", ak.KeySecretHash)
+	}
+	if !ak.KeySecretMatches(ak.KeySecret) {
+		t.Error("Expected key secret to match its hash")
+	}
+	if ak.KeySecretMatches(ak.KeySecretHash) {
+		t.Error("Expected the hash itself not to match")
+	}
+	if ak.KeySecretMatches("") {
+		t.Error("Expected an empty key secret not to match")
+	}
+}
+
+func TestAccessKeyUsable(t *testing.T) {
+	now := time.Now()
+	tt := []struct {
+		ak       models.AccessKey
+		expected bool
+	}{
+		{models.AccessKey{Active: true}, true},
+		{models.AccessKey{Active: false}, false},
+		{models.AccessKey{
+			Active: true, ExpiresAt: pg.NullTime{Time: now.Add(time.Hour)},
+		}, true},
+		{models.AccessKey{
+			Active: true, ExpiresAt: pg.NullTime{Time: now.Add(-time.Hour)},
+		}, false},
+	}
+	for i, tt := range tt {
+		if usable := tt.ak.Usable(now); usable != tt.expected {
+			t.Errorf("%d: Expected usable to be %v. Got %v", i, tt.expected, usable)
+		}
+	}
+}
+
+func TestAccessKeyValidate(t *testing.T) {
+	tt := []struct {
+		ak    models.AccessKey
+		valid bool
+	}{
+		{models.AccessKey{Label: "ci"}, true},
+		{models.AccessKey{}, false},
+		{models.AccessKey{
+			Label: "ci", ExpiresAt: pg.NullTime{Time: time.Now().Add(time.Hour)},
+		}, true},
+		{models.AccessKey{
+			Label: "ci", ExpiresAt: pg.NullTime{Time: time.Now().Add(-time.Hour)},
+		}, false},
+	}
+	for i, tt := range tt {
+		if v := tt.ak.Validate(); v.Valid() != tt.valid {
+			t.Errorf("%d: Expected valid to be %v. Got %v", i, tt.valid, v.Valid())
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+// ApprovalPolicy requires Approvals distinct owners to approve requests for
+// permissions matching Permission before they're granted
+type ApprovalPolicy struct {
+	Permission Permission
+	Approvals  int
+}
+
+// BuildApprovalPolicy builds an ApprovalPolicy matching permission, in
+// string format
+func BuildApprovalPolicy(permission string, approvals int) (ApprovalPolicy, error) {
+	p, err := BuildPermission(permission)
+	if err != nil {
+		return ApprovalPolicy{}, err
+	}
+	return ApprovalPolicy{Permission: p, Approvals: approvals}, nil
+}
+
+// Matches checks if p falls under ap: p could apply to a service, action
+// and resource ap Permission does, with p ownership level at least as high.
+// Permissions wider than ap Permission match it, so wildcards can't be used
+// to get around ap
+// Eg: *::RL::*::* matches every RL and RO permission, *::RO::*::* only RO
+// ones, and Maestro::RL::DeleteScheduler::* is matched by Maestro::RL::*::*
+func (ap ApprovalPolicy) Matches(p Permission) bool {
+	if p.OwnershipLevel.Less(ap.Permission.OwnershipLevel) {
+		return false
+	}
+	return ap.Permission.Overlaps(p)
+}
+
+// RequiredApprovals returns how many distinct owners must approve requests
+// for p: the most any matching policy requires, and at least one
+func RequiredApprovals(policies []ApprovalPolicy, p Permission) int {
+	required := 1
+	for _, ap := range policies {
+		if ap.Approvals > required && ap.Matches(p) {
+			required = ap.Approvals
+		}
+	}
+	return required
+}
@@ -0,0 +1,73 @@
+// +build unit
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func TestApprovalPolicyMatches(t *testing.T) {
+	tt := []struct {
+		policy     string
+		permission string
+		matches    bool
+	}{
+		{"*::RO::*::*", "Maestro::RO::DeleteScheduler::NA::x", true},
+		{"*::RO::*::*", "Maestro::RL::DeleteScheduler::NA::x", false},
+		{"Maestro::RL::DeleteScheduler::*", "Maestro::RL::DeleteScheduler::NA::x", true},
+		{"Maestro::RL::DeleteScheduler::*", "Maestro::RO::DeleteScheduler::NA::x", true},
+		{"Maestro::RL::DeleteScheduler::*", "Maestro::RL::ListSchedulers::NA::x", false},
+		{"Maestro::RL::DeleteScheduler::*", "Other::RL::DeleteScheduler::NA::x", false},
+		{"Maestro::RL::*::NA::*", "Maestro::RL::DeleteScheduler::NA::x", true},
+		{"Maestro::RL::*::NA::*", "Maestro::RL::DeleteScheduler::EU::x", false},
+		{"Maestro::RL::DeleteScheduler::*", "Maestro::RL::*::*", true},
+		{"Maestro::RL::DeleteScheduler::*", "*::RL::*::*", true},
+		{"Maestro::RL::DeleteScheduler::*", "*::RL::DeleteScheduler::NA::x", true},
+		{"Maestro::RL::DeleteScheduler::*", "*::RL::ListSchedulers::*", false},
+		{"Maestro::RL::DeleteScheduler::NA::prod", "Maestro::RL::DeleteScheduler::NA::*", true},
+		{"Maestro::RL::DeleteScheduler::NA::prod", "Maestro::RL::DeleteScheduler::*", true},
+		{"Maestro::RL::DeleteScheduler::NA::prod", "Maestro::RL::DeleteScheduler::NA::dev", false},
+	}
+	for _, tt := range tt {
+		t.Run(tt.policy+" "+tt.permission, func(t *testing.T) {
+			ap, err := models.BuildApprovalPolicy(tt.policy, 2)
+			if err != nil {
+				t.Fatalf("Unexpected error %s", err.Error())
+			}
+			p, err := models.BuildPermission(tt.permission)
+			if err != nil {
+				t.Fatalf("Unexpected error %s", err.Error())
+			}
+			if ap.Matches(p) != tt.matches {
+				t.Errorf("Expected Matches to be %t", tt.matches)
+			}
+		})
+	}
+}
+
+func TestRequiredApprovals(t *testing.T) {
+	ro, _ := models.BuildApprovalPolicy("*::RO::*::*", 2)
+	deletes, _ := models.BuildApprovalPolicy("Maestro::RL::DeleteScheduler::*", 3)
+	policies := []models.ApprovalPolicy{ro, deletes}
+	tt := []struct {
+		permission string
+		required   int
+	}{
+		{"Maestro::RL::ListSchedulers::*", 1},
+		{"Maestro::RO::ListSchedulers::*", 2},
+		{"Maestro::RO::DeleteScheduler::*", 3},
+		{"Maestro::RL::*::*", 3},
+		{"*::RL::*::*", 3},
+	}
+	for _, tt := range tt {
+		p, _ := models.BuildPermission(tt.permission)
+		if required := models.RequiredApprovals(policies, p); required != tt.required {
+			t.Errorf("Expected %s to require %d approvals. Got %d", tt.permission, tt.required, required)
+		}
+	}
+	if _, err := models.BuildApprovalPolicy("Maestro::RL", 2); err == nil {
+		t.Error("Expected error")
+	}
+}
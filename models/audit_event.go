@@ -0,0 +1,172 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AuditEvent records a change to IAM state
+// ActorServiceAccountID: who made the change, empty when unauthenticated
+// Before and After: target state around the change, JSON encoded
+// Seq, PreviousHash and Hash: position in the audit hash chain, see Seal
+type AuditEvent struct {
+	ID                    string          `json:"id" pg:"id"`
+	Seq                   int64           `json:"seq" pg:"seq"`
+	ActorServiceAccountID string          `json:"actorServiceAccountId" pg:"actor_service_account_id"`
+	Action                string          `json:"action" pg:"action"`
+	TargetType            string          `json:"targetType" pg:"target_type"`
+	TargetID              string          `json:"targetId" pg:"target_id"`
+	Before                json.RawMessage `json:"before" pg:"before"`
+	After                 json.RawMessage `json:"after" pg:"after"`
+	RequestID             string          `json:"requestId" pg:"request_id"`
+	CreatedAt             time.Time       `json:"createdAt" pg:"created_at"`
+	PreviousHash          string          `json:"previousHash" pg:"previous_hash"`
+	Hash                  string          `json:"hash" pg:"hash"`
+}
+
+// Seal chains ae after previousHash, setting ae PreviousHash and Hash
+func (ae *AuditEvent) Seal(previousHash string) {
+	ae.PreviousHash = previousHash
+	ae.Hash = ae.ComputeHash()
+}
+
+// ComputeHash hashes ae content along with its PreviousHash; Seq, which
+// is only known after storing ae, is not part of it
+func (ae AuditEvent) ComputeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		ae.PreviousHash,
+		ae.ID,
+		ae.ActorServiceAccountID,
+		ae.Action,
+		ae.TargetType,
+		ae.TargetID,
+		string(ae.Before),
+		string(ae.After),
+		ae.RequestID,
+		ae.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditChainVerification is the result of walking the audit hash chain
+// Checked: how many chained events were checked
+// BrokenAt: the first event whose hash or previous hash doesn't match
+// Reason: why BrokenAt is broken
+type AuditChainVerification struct {
+	Valid    bool        `json:"valid"`
+	Checked  int64       `json:"checked"`
+	BrokenAt *AuditEvent `json:"brokenAt,omitempty"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+// VerifyAuditChain checks events, ordered by Seq, are chained after
+// previousHash; it returns the hash the next event must chain after, or
+// the reason the first broken event is broken
+func VerifyAuditChain(
+	previousHash string, events []AuditEvent,
+) (string, int, string) {
+	for i := range events {
+		if events[i].PreviousHash != previousHash {
+			return previousHash, i, "previous hash doesn't match the hash of the event before it"
+		}
+		if events[i].ComputeHash() != events[i].Hash {
+			return previousHash, i, "hash doesn't match the event content"
+		}
+		previousHash = events[i].Hash
+	}
+	return previousHash, -1, ""
+}
+
+// AuditTargetTypes are the kinds of entities audit events are about
+var AuditTargetTypes = struct {
+	AccessKey           string
+	AutoApprovalRule    string
+	IdPGroupRoleMapping string
+	Permission          string
+	PermissionRequest   string
+	Role                string
+	Service             string
+	ServiceAccount      string
+	Token               string
+}{
+	AccessKey:           "access_key",
+	AutoApprovalRule:    "auto_approval_rule",
+	IdPGroupRoleMapping: "idp_group_role_mapping",
+	Permission:          "permission",
+	PermissionRequest:   "permission_request",
+	Role:                "role",
+	Service:             "service",
+	ServiceAccount:      "service_account",
+	Token:               "token",
+}
+
+// AuditActions are the changes recorded as audit events
+var AuditActions = struct {
+	ActivateServiceAccount       string
+	ApprovePermissionRequest     string
+	AttributePermissions         string
+	AttributePermissionsToEmails string
+	CancelPermissionRequest      string
+	CreateAccessKey              string
+	CreateAutoApprovalRule       string
+	CreateIdPGroupRoleMapping    string
+	CreatePermission             string
+	CreatePermissionRequest      string
+	CreateRole                   string
+	CreateService                string
+	CreateServiceAccount         string
+	DeactivateAccessKey          string
+	DeactivateServiceAccount     string
+	DeleteAutoApprovalRule       string
+	DeleteIdPGroupRoleMapping    string
+	DeletePermission             string
+	DeleteRole                   string
+	DeleteServiceAccount         string
+	DenyPermissionRequest        string
+	ExpirePermission             string
+	ExpirePermissionRequest      string
+	ExpireRoleBinding            string
+	GrantPermissionRequest       string
+	RevokeToken                  string
+	SyncGroupRoles               string
+	UpdateRole                   string
+	UpdateService                string
+	UpdateServiceAccount         string
+}{
+	ActivateServiceAccount:       "ActivateServiceAccount",
+	ApprovePermissionRequest:     "ApprovePermissionRequest",
+	AttributePermissions:         "AttributePermissions",
+	AttributePermissionsToEmails: "AttributePermissionsToEmails",
+	CancelPermissionRequest:      "CancelPermissionRequest",
+	CreateAccessKey:              "CreateAccessKey",
+	CreateAutoApprovalRule:       "CreateAutoApprovalRule",
+	CreateIdPGroupRoleMapping:    "CreateIdPGroupRoleMapping",
+	CreatePermission:             "CreatePermission",
+	CreatePermissionRequest:      "CreatePermissionRequest",
+	CreateRole:                   "CreateRole",
+	CreateService:                "CreateService",
+	CreateServiceAccount:         "CreateServiceAccount",
+	DeactivateAccessKey:          "DeactivateAccessKey",
+	DeactivateServiceAccount:     "DeactivateServiceAccount",
+	DeleteAutoApprovalRule:       "DeleteAutoApprovalRule",
+	DeleteIdPGroupRoleMapping:    "DeleteIdPGroupRoleMapping",
+	DeletePermission:             "DeletePermission",
+	DeleteRole:                   "DeleteRole",
+	DeleteServiceAccount:         "DeleteServiceAccount",
+	DenyPermissionRequest:        "DenyPermissionRequest",
+	ExpirePermission:             "ExpirePermission",
+	ExpirePermissionRequest:      "ExpirePermissionRequest",
+	ExpireRoleBinding:            "ExpireRoleBinding",
+	GrantPermissionRequest:       "GrantPermissionRequest",
+	RevokeToken:                  "RevokeToken",
+	SyncGroupRoles:               "SyncGroupRoles",
+	UpdateRole:                   "UpdateRole",
+	UpdateService:                "UpdateService",
+	UpdateServiceAccount:         "UpdateServiceAccount",
+}
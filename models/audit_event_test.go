@@ -0,0 +1,84 @@
+// +build unit
+
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func buildAuditChain(n int) []models.AuditEvent {
+	events := make([]models.AuditEvent, n)
+	previousHash := ""
+	for i := range events {
+		events[i] = models.AuditEvent{
+			ID:         string(rune('a' + i)),
+			Seq:        int64(i + 1),
+			Action:     models.AuditActions.UpdateRole,
+			TargetType: models.AuditTargetTypes.Role,
+			TargetID:   "some-role",
+			Before:     json.RawMessage(`{"name":"before"}`),
+			After:      json.RawMessage(`{"name":"after"}`),
+			CreatedAt:  time.Date(2019, 1, 1, 0, 0, i, 0, time.UTC),
+		}
+		events[i].Seal(previousHash)
+		previousHash = events[i].Hash
+	}
+	return events
+}
+
+func TestAuditEventComputeHash(t *testing.T) {
+	ae := buildAuditChain(1)[0]
+	if ae.ComputeHash() != ae.Hash {
+		t.Fatal("Expected ComputeHash to be stable")
+	}
+	local := ae
+	local.CreatedAt = ae.CreatedAt.In(time.FixedZone("BRT", -3*60*60))
+	if local.ComputeHash() != ae.Hash {
+		t.Error("Expected ComputeHash to ignore time zones")
+	}
+	changed := ae
+	changed.After = json.RawMessage(`{"name":"other"}`)
+	if changed.ComputeHash() == ae.Hash {
+		t.Error("Expected ComputeHash to change with After")
+	}
+	shifted := ae
+	shifted.Action = ae.Action + ae.TargetType[:1]
+	shifted.TargetType = ae.TargetType[1:]
+	if shifted.ComputeHash() == ae.Hash {
+		t.Error("Expected ComputeHash to tell fields apart")
+	}
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	events := buildAuditChain(3)
+	last, broken, _ := models.VerifyAuditChain("", events)
+	if broken != -1 {
+		t.Fatalf("Expected chain to be valid. Broken at %d", broken)
+	}
+	if last != events[2].Hash {
+		t.Errorf("Expected last hash %s. Got %s", events[2].Hash, last)
+	}
+
+	tampered := buildAuditChain(3)
+	tampered[1].TargetID = "other-role"
+	if _, broken, _ := models.VerifyAuditChain("", tampered); broken != 1 {
+		t.Errorf("Expected chain to break at 1. Got %d", broken)
+	}
+
+	removed := buildAuditChain(3)
+	removed = append(removed[:1], removed[2:]...)
+	if _, broken, _ := models.VerifyAuditChain("", removed); broken != 1 {
+		t.Errorf("Expected chain to break at 1. Got %d", broken)
+	}
+
+	resealed := buildAuditChain(3)
+	resealed[1].TargetID = "other-role"
+	resealed[1].Seal(resealed[0].Hash)
+	if _, broken, _ := models.VerifyAuditChain("", resealed); broken != 2 {
+		t.Errorf("Expected chain to break at 2. Got %d", broken)
+	}
+}
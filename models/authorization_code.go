@@ -0,0 +1,126 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"regexp"
+	"time"
+)
+
+// codeChallengeRegexp matches RFC 7636 code challenges and verifiers: 43 to
+// 128 unreserved characters
+var codeChallengeRegexp = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// AuthorizationRequest is an RFC 6749 authorization request with the RFC
+// 7636 PKCE parameters, which are required, as the params of /oauth2/authorize
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// BuildAuthorizationRequest reads an AuthorizationRequest from the query
+// params of /oauth2/authorize
+func BuildAuthorizationRequest(qs map[string][]string) *AuthorizationRequest {
+	get := func(key string) string {
+		if len(qs[key]) == 0 {
+			return ""
+		}
+		return qs[key][0]
+	}
+	return &AuthorizationRequest{
+		ResponseType:        get("response_type"),
+		ClientID:            get("client_id"),
+		RedirectURI:         get("redirect_uri"),
+		State:               get("state"),
+		CodeChallenge:       get("code_challenge"),
+		CodeChallengeMethod: get("code_challenge_method"),
+	}
+}
+
+// Query returns ar as the query params of /oauth2/authorize
+func (ar AuthorizationRequest) Query() map[string][]string {
+	return map[string][]string{
+		"response_type":         {ar.ResponseType},
+		"client_id":             {ar.ClientID},
+		"redirect_uri":          {ar.RedirectURI},
+		"state":                 {ar.State},
+		"code_challenge":        {ar.CodeChallenge},
+		"code_challenge_method": {ar.CodeChallengeMethod},
+	}
+}
+
+// Validate AuthorizationRequest PKCE params; only S256 challenges are
+// accepted
+func (ar AuthorizationRequest) Validate() Validation {
+	v := &Validation{}
+	if !codeChallengeRegexp.MatchString(ar.CodeChallenge) {
+		v.AddError("code_challenge", "required, as 43 to 128 unreserved characters")
+	}
+	if ar.CodeChallengeMethod != "S256" {
+		v.AddError("code_challenge_method", "must be S256")
+	}
+	return *v
+}
+
+// AuthorizationCode is an OAuth2 authorization code issued to ClientID, the
+// id of a service, on behalf of ServiceAccountID; Code is only kept in
+// memory, to be sent to RedirectURI, storage only has CodeHash
+type AuthorizationCode struct {
+	ID               string    `json:"id" pg:"id"`
+	Code             string    `json:"-" sql:"-"`
+	CodeHash         string    `json:"-" pg:"code_hash"`
+	ClientID         string    `json:"clientId" pg:"client_id"`
+	ServiceAccountID string    `json:"serviceAccountId" pg:"service_account_id"`
+	RedirectURI      string    `json:"redirectUri" pg:"redirect_uri"`
+	CodeChallenge    string    `json:"-" pg:"code_challenge"`
+	ExpiresAt        time.Time `json:"expiresAt" pg:"expires_at"`
+	CreatedUpdatedAt
+}
+
+// BuildAuthorizationCode generates a random code answering ar on behalf of
+// saID, valid until expiresAt, and hashes it
+func BuildAuthorizationCode(
+	ar *AuthorizationRequest, saID string, expiresAt time.Time,
+) (*AuthorizationCode, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+	return &AuthorizationCode{
+		Code:             code,
+		CodeHash:         HashAuthorizationCode(code),
+		ClientID:         ar.ClientID,
+		ServiceAccountID: saID,
+		RedirectURI:      ar.RedirectURI,
+		CodeChallenge:    ar.CodeChallenge,
+		ExpiresAt:        expiresAt,
+	}, nil
+}
+
+// HashAuthorizationCode returns the hash authorization codes are stored as;
+// they're random enough for a plain SHA-256 to do
+func HashAuthorizationCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifierMatches checks the RFC 7636 S256 transformation of verifier
+// against ac CodeChallenge
+func (ac AuthorizationCode) VerifierMatches(verifier string) bool {
+	if !codeChallengeRegexp.MatchString(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare(
+		[]byte(challenge), []byte(ac.CodeChallenge),
+	) == 1
+}
@@ -0,0 +1,61 @@
+// +build unit
+
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func TestAuthorizationCodeVerifierMatches(t *testing.T) {
+	// RFC 7636 appendix B
+	ac := models.AuthorizationCode{
+		CodeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+	}
+	tt := []struct {
+		verifier string
+		expected bool
+	}{
+		{"dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", true},
+		{"dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXx", false},
+		{"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", false},
+		{"", false},
+	}
+	for _, tt := range tt {
+		if got := ac.VerifierMatches(tt.verifier); got != tt.expected {
+			t.Errorf("Expected %t for %q. Got %t", tt.expected, tt.verifier, got)
+		}
+	}
+}
+
+func TestBuildAuthorizationCodeHashesCode(t *testing.T) {
+	ar := &models.AuthorizationRequest{ClientID: "client", RedirectURI: "http://127.0.0.1/cb"}
+	ac, err := models.BuildAuthorizationCode(ar, "some sa", time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if ac.Code == "" || ac.CodeHash != models.HashAuthorizationCode(ac.Code) || ac.CodeHash == ac.Code {
+		t.Errorf("Expected a hash of the code. Got %#v", ac)
+	}
+}
+
+func TestAuthorizationRequestValidate(t *testing.T) {
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	tt := []struct {
+		ar    models.AuthorizationRequest
+		valid bool
+	}{
+		{models.AuthorizationRequest{CodeChallenge: challenge, CodeChallengeMethod: "S256"}, true},
+		{models.AuthorizationRequest{CodeChallenge: challenge, CodeChallengeMethod: "plain"}, false},
+		{models.AuthorizationRequest{CodeChallenge: challenge}, false},
+		{models.AuthorizationRequest{CodeChallenge: "short", CodeChallengeMethod: "S256"}, false},
+		{models.AuthorizationRequest{CodeChallengeMethod: "S256"}, false},
+	}
+	for _, tt := range tt {
+		if got := tt.ar.Validate().Valid(); got != tt.valid {
+			t.Errorf("Expected valid %t for %#v. Got %t", tt.valid, tt.ar, got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import "strings"
+
+// AutoApprovalRule grants requests for permissions Permission covers as soon
+// as they're made, to requesters meeting every condition the rule sets:
+// HostedDomain: an OAuth2 provider vouched the requester belongs to this
+// domain at their last login
+// RoleID: requester is bound to this role, or to one including it
+// Granted permissions expire after Duration or, when zero, after the
+// duration requested
+type AutoApprovalRule struct {
+	ID                      string   `json:"id" pg:"id"`
+	Permission              string   `json:"permission" pg:"permission"`
+	HostedDomain            string   `json:"hostedDomain" pg:"hosted_domain"`
+	RoleID                  string   `json:"roleId" pg:"role_id"`
+	Duration                Duration `json:"duration,omitempty" sql:"duration,notnull"`
+	CreatorServiceAccountID string   `json:"creatorServiceAccountId" pg:"creator_service_account_id"`
+	CreatedUpdatedAt
+}
+
+// Validate AutoApprovalRule
+func (r AutoApprovalRule) Validate() Validation {
+	v := &Validation{}
+	if p, err := BuildPermission(r.Permission); err != nil {
+		v.AddError("permission", err.Error())
+	} else if p.IsDeny() {
+		v.AddError("permission", "must not be DENY")
+	}
+	if r.HostedDomain == "" && r.RoleID == "" {
+		v.AddError("hostedDomain", "hostedDomain or roleId required")
+	}
+	if strings.Contains(r.HostedDomain, "@") {
+		v.AddError("hostedDomain", "must be a domain, not an email")
+	}
+	r.Duration.validate(v, "duration")
+	return *v
+}
+
+// Covers checks if r applies to requests for p: r Permission satisfies it
+// Eg: Maestro::RL::*::* covers Maestro::RL::ListSchedulers::NA, but not
+// Maestro::RO::ListSchedulers::NA
+func (r AutoApprovalRule) Covers(p Permission) bool {
+	rp, err := BuildPermission(r.Permission)
+	if err != nil {
+		return false
+	}
+	return p.IsPresent([]Permission{rp})
+}
+
+// Accepts checks if a requester of verified hostedDomain, bound to
+// rolesIDs, meets every condition of r
+func (r AutoApprovalRule) Accepts(hostedDomain string, rolesIDs []string) bool {
+	if r.HostedDomain != "" && !strings.EqualFold(hostedDomain, r.HostedDomain) {
+		return false
+	}
+	if r.RoleID == "" {
+		return true
+	}
+	for _, id := range rolesIDs {
+		if id == r.RoleID {
+			return true
+		}
+	}
+	return false
+}
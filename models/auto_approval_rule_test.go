@@ -0,0 +1,103 @@
+// +build unit
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func TestAutoApprovalRuleCovers(t *testing.T) {
+	tt := []struct {
+		rule       string
+		permission string
+		covers     bool
+	}{
+		{"Maestro::RL::ListSchedulers::*", "Maestro::RL::ListSchedulers::NA::x", true},
+		{"Maestro::RL::ListSchedulers::*", "Maestro::RO::ListSchedulers::NA::x", false},
+		{"Maestro::RL::ListSchedulers::*", "Maestro::RL::DeleteScheduler::NA::x", false},
+		{"Maestro::RL::*::NA::*", "Maestro::RL::DeleteScheduler::NA::x", true},
+		{"Maestro::RL::*::NA::*", "Maestro::RL::DeleteScheduler::EU::x", false},
+		{"Maestro::RO::*::*", "Maestro::RL::DeleteScheduler::EU::x", true},
+	}
+	for _, tt := range tt {
+		t.Run(tt.rule+" "+tt.permission, func(t *testing.T) {
+			p, err := models.BuildPermission(tt.permission)
+			if err != nil {
+				t.Fatalf("Unexpected error %s", err.Error())
+			}
+			r := models.AutoApprovalRule{Permission: tt.rule}
+			if r.Covers(p) != tt.covers {
+				t.Errorf("Expected Covers to be %t", tt.covers)
+			}
+		})
+	}
+}
+
+func TestAutoApprovalRuleAccepts(t *testing.T) {
+	tt := []struct {
+		name     string
+		rule     models.AutoApprovalRule
+		domain   string
+		rolesIDs []string
+		accepts  bool
+	}{
+		{"domain", models.AutoApprovalRule{HostedDomain: "domain.com"},
+			"Domain.com", nil, true},
+		{"other domain", models.AutoApprovalRule{HostedDomain: "domain.com"},
+			"other.com", nil, false},
+		{"subdomain", models.AutoApprovalRule{HostedDomain: "domain.com"},
+			"sub.domain.com", nil, false},
+		{"no verified domain", models.AutoApprovalRule{HostedDomain: "domain.com"},
+			"", nil, false},
+		{"role", models.AutoApprovalRule{RoleID: "r1"},
+			"", []string{"r0", "r1"}, true},
+		{"other role", models.AutoApprovalRule{RoleID: "r1"},
+			"", []string{"r0"}, false},
+		{"domain and role", models.AutoApprovalRule{
+			HostedDomain: "domain.com", RoleID: "r1",
+		}, "domain.com", []string{"r0"}, false},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.rule.Accepts(tt.domain, tt.rolesIDs) != tt.accepts {
+				t.Errorf("Expected Accepts to be %t", tt.accepts)
+			}
+		})
+	}
+}
+
+func TestAutoApprovalRuleValidate(t *testing.T) {
+	tt := []struct {
+		name  string
+		rule  models.AutoApprovalRule
+		valid bool
+	}{
+		{"domain", models.AutoApprovalRule{
+			Permission: "Maestro::RL::ListSchedulers::*", HostedDomain: "domain.com",
+		}, true},
+		{"role", models.AutoApprovalRule{
+			Permission: "Maestro::RL::ListSchedulers::*", RoleID: "r1",
+		}, true},
+		{"no condition", models.AutoApprovalRule{
+			Permission: "Maestro::RL::ListSchedulers::*",
+		}, false},
+		{"deny", models.AutoApprovalRule{
+			Permission: "Maestro::DENY::ListSchedulers::*", RoleID: "r1",
+		}, false},
+		{"invalid permission", models.AutoApprovalRule{
+			Permission: "Maestro::RL", RoleID: "r1",
+		}, false},
+		{"email", models.AutoApprovalRule{
+			Permission: "Maestro::RL::ListSchedulers::*", HostedDomain: "a@domain.com",
+		}, false},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			if v := tt.rule.Validate(); v.Valid() != tt.valid {
+				t.Errorf("Expected Valid to be %t", tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration read from and written to JSON as strings like
+// "8h", and stored as whole seconds
+type Duration time.Duration
+
+// validate adds to v an error for field if d is negative, or positive but
+// shorter than the second it's stored with, as zero reads back as no expiry
+func (d Duration) validate(v *Validation, field string) {
+	if d < 0 {
+		v.AddError(field, "must not be negative")
+	} else if d > 0 && time.Duration(d) < time.Second {
+		v.AddError(field, "must be at least 1s")
+	}
+}
+
+// MarshalJSON writes d as a time.Duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON reads d from a time.Duration string; "" is zero
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Value implements driver.Valuer; fractions of a second are rounded up, so
+// that no positive Duration is stored as zero
+func (d Duration) Value() (driver.Value, error) {
+	seconds := int64(time.Duration(d) / time.Second)
+	if time.Duration(d)%time.Second > 0 {
+		seconds++
+	}
+	return seconds, nil
+}
+
+// Scan implements sql.Scanner
+func (d *Duration) Scan(src interface{}) error {
+	var seconds int64
+	switch v := src.(type) {
+	case nil:
+	case int64:
+		seconds = v
+	case []byte:
+		var err error
+		if seconds, err = strconv.ParseInt(string(v), 10, 64); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("can't scan %T into Duration", src)
+	}
+	*d = Duration(time.Duration(seconds) * time.Second)
+	return nil
+}
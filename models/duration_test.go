@@ -0,0 +1,77 @@
+// +build unit
+
+package models_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func TestDurationJSON(t *testing.T) {
+	var pr models.PermissionRequest
+	if err := json.Unmarshal([]byte(`{"duration": "8h"}`), &pr); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if time.Duration(pr.Duration) != 8*time.Hour {
+		t.Errorf("Expected duration to be 8h. Got %s", time.Duration(pr.Duration))
+	}
+	b, err := json.Marshal(pr)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	var m map[string]interface{}
+	json.Unmarshal(b, &m)
+	if m["duration"] != "8h0m0s" {
+		t.Errorf("Expected duration to be 8h0m0s. Got %v", m["duration"])
+	}
+	if err := json.Unmarshal([]byte(`{"duration": "soon"}`), &pr); err == nil {
+		t.Error("Expected error")
+	}
+	pr.Duration = -models.Duration(time.Hour)
+	if pr.Validate().Valid() {
+		t.Error("Expected negative duration to be invalid")
+	}
+	pr.Duration = models.Duration(500 * time.Millisecond)
+	if pr.Validate().Valid() {
+		t.Error("Expected duration under 1s to be invalid")
+	}
+	rule := models.AutoApprovalRule{
+		Permission:   "Maestro::RL::*::*",
+		HostedDomain: "example.com",
+		Duration:     models.Duration(500 * time.Millisecond),
+	}
+	if rule.Validate().Valid() {
+		t.Error("Expected rule duration under 1s to be invalid")
+	}
+	pr.Duration = models.Duration(time.Second)
+	if !pr.Validate().Valid() {
+		t.Error("Expected duration of 1s to be valid")
+	}
+}
+
+func TestDurationScan(t *testing.T) {
+	d := models.Duration(8 * time.Hour)
+	v, err := d.Value()
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if v != int64(28800) {
+		t.Errorf("Expected value to be 28800. Got %v", v)
+	}
+	var scanned models.Duration
+	if err := scanned.Scan([]byte("28800")); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if scanned != d {
+		t.Errorf("Expected %s. Got %s", time.Duration(d), time.Duration(scanned))
+	}
+	for _, d := range []time.Duration{time.Millisecond, 1500 * time.Millisecond} {
+		v, _ := models.Duration(d).Value()
+		if want := int64((d + time.Second - 1) / time.Second); v != want {
+			t.Errorf("Expected %s to be stored as %d. Got %v", d, want, v)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+// IdPGroupRoleMapping binds members of GroupName, as told by the identity
+// provider named Provider when they log in, to the role RoleID
+type IdPGroupRoleMapping struct {
+	ID        string `json:"id" pg:"id"`
+	Provider  string `json:"provider" pg:"provider"`
+	GroupName string `json:"group" pg:"group_name"`
+	RoleID    string `json:"roleId" pg:"role_id"`
+	CreatedUpdatedAt
+}
+
+// Validate IdPGroupRoleMapping
+func (m IdPGroupRoleMapping) Validate() Validation {
+	v := &Validation{}
+	if m.Provider == "" {
+		v.AddError("provider", "required")
+	}
+	if m.GroupName == "" {
+		v.AddError("group", "required")
+	}
+	return *v
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/topfreegames/Will.IAM/constants"
 )
@@ -14,12 +15,16 @@ type OwnershipLevel string
 // Owner: can exercise the action over the resource and provide the exact same
 // ownership:action:resource rights to other parties
 // Lender: can only exercise the action over the resource
+// Deny: can't exercise the action over the resource, even if an Owner or
+// Lender permission from any role says otherwise
 var OwnershipLevels = struct {
 	Owner  OwnershipLevel
 	Lender OwnershipLevel
+	Deny   OwnershipLevel
 }{
 	Owner:  "RO",
 	Lender: "RL",
+	Deny:   "DENY",
 }
 
 // Less returns true if o < oo; Lender < Owner
@@ -128,6 +133,7 @@ func (rh ResourceHierarchy) String() string {
 
 // Permission is bound to a role and
 // defines the onwership level of an action over a resource
+// A TimeBound limits the period in which the permission is in effect
 type Permission struct {
 	ID                string            `json:"id" pg:"id"`
 	RoleID            string            `json:"roleId" pg:"role_id"`
@@ -136,6 +142,7 @@ type Permission struct {
 	Action            Action            `json:"action" pg:"action"`
 	ResourceHierarchy ResourceHierarchy `json:"resourceHierarchy" pg:"resource_hierarchy"`
 	Alias             string            `json:"alias" pg:"alias"`
+	TimeBound
 }
 
 // ValidatePermission validates a permission in string format
@@ -149,8 +156,9 @@ func ValidatePermission(str string) (bool, error) {
 		)
 	}
 	ol := OwnershipLevel(parts[1])
-	if ol != OwnershipLevels.Owner && ol != OwnershipLevels.Lender {
-		return false, fmt.Errorf("OwnershipLevel needs to be RO or RL")
+	if ol != OwnershipLevels.Owner && ol != OwnershipLevels.Lender &&
+		ol != OwnershipLevels.Deny {
+		return false, fmt.Errorf("OwnershipLevel needs to be RO, RL or DENY")
 	}
 	for _, part := range parts {
 		if part == "" {
@@ -191,19 +199,57 @@ func BuildPermissions(ps []string) ([]Permission, error) {
 	return pSl, nil
 }
 
+// IsDeny checks if p denies its action over its resource hierarchy
+func (p Permission) IsDeny() bool {
+	return p.OwnershipLevel == OwnershipLevels.Deny
+}
+
+// Overlaps checks if p and op could both apply to the same service, action
+// and resource, regardless of ownership levels
+// Eg: Maestro::RL::*::NA::* overlaps Maestro::DENY::Delete::NA::prod and
+// Maestro::RO::Delete::* overlaps Maestro::DENY::Delete::NA::*
+func (p Permission) Overlaps(op Permission) bool {
+	if p.Service != "*" && op.Service != "*" && p.Service != op.Service {
+		return false
+	}
+	if !p.Action.All() && !op.Action.All() && p.Action != op.Action {
+		return false
+	}
+	return p.ResourceHierarchy.Contains(op.ResourceHierarchy) ||
+		op.ResourceHierarchy.Contains(p.ResourceHierarchy)
+}
+
 // IsPresent checks if a permission is satisfied in a slice
+// A DENY permission overlapping p wins over any RO or RL permission that
+// satisfies it. A DENY p itself is never present. Permissions out of their
+// TimeBound are ignored
 func (p Permission) IsPresent(permissions []Permission) bool {
+	if p.IsDeny() {
+		return false
+	}
+	now := time.Now()
+	present := false
 	for _, pp := range permissions {
-		if (pp.Service != "*" && pp.Service != p.Service) ||
+		if !pp.Active(now) {
+			continue
+		}
+		if pp.IsDeny() {
+			if pp.Overlaps(p) {
+				return false
+			}
+			continue
+		}
+		if present ||
+			(pp.Service != "*" && pp.Service != p.Service) ||
 			(pp.Action != "*" && pp.Action != p.Action) ||
 			pp.OwnershipLevel.Less(p.OwnershipLevel) {
 			continue
 		}
 		if pp.ResourceHierarchy.Contains(p.ResourceHierarchy) {
-			return true
+			present = true
 		}
 	}
-	return false
+	return present
 }
 
 // String converts a permission to it's equivalent string format
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxExplanationCandidates is how many near misses an explanation lists
+const maxExplanationCandidates = 5
+
+// PermissionGrant is a permission a service account holds, along with the
+// name of the role it comes from
+type PermissionGrant struct {
+	Permission
+	RoleName string `json:"roleName" pg:"role_name"`
+}
+
+// PermissionExplanation tells why a service account has, or lacks, a
+// permission
+// Matched: grants satisfying the permission
+// DeniedBy: DENY grants overlapping the permission
+// Candidates: when nothing matched, the grants closest to satisfying it
+type PermissionExplanation struct {
+	Permission       string            `json:"permission"`
+	ServiceAccountID string            `json:"serviceAccountId"`
+	Allowed          bool              `json:"allowed"`
+	Matched          []PermissionGrant `json:"matched"`
+	DeniedBy         []PermissionGrant `json:"deniedBy"`
+	Candidates       []PermissionGrant `json:"candidates"`
+}
+
+// ExplainPermission checks p against all grants a service account holds
+// for p.Service, following the same rules as HasPermission
+func ExplainPermission(
+	p Permission, grants []PermissionGrant,
+) PermissionExplanation {
+	pe := PermissionExplanation{
+		Permission: p.String(),
+		Matched:    []PermissionGrant{},
+		DeniedBy:   []PermissionGrant{},
+		Candidates: []PermissionGrant{},
+	}
+	matches := p.ResourceHierarchy.PermissionMatches()
+	rest := []PermissionGrant{}
+	for _, g := range grants {
+		if g.IsDeny() {
+			if g.Overlaps(p) {
+				pe.DeniedBy = append(pe.DeniedBy, g)
+			}
+			continue
+		}
+		if !p.IsDeny() && (g.Service == "*" || g.Service == p.Service) &&
+			p.closeness(g.Permission, matches) == 3 {
+			pe.Matched = append(pe.Matched, g)
+			continue
+		}
+		rest = append(rest, g)
+	}
+	pe.Allowed = len(pe.Matched) > 0 && len(pe.DeniedBy) == 0
+	if len(pe.Matched) > 0 {
+		return pe
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		ci := p.closeness(rest[i].Permission, matches)
+		cj := p.closeness(rest[j].Permission, matches)
+		if ci != cj {
+			return ci > cj
+		}
+		return p.ResourceHierarchy.sharedDepth(rest[i].ResourceHierarchy) >
+			p.ResourceHierarchy.sharedDepth(rest[j].ResourceHierarchy)
+	})
+	if len(rest) > maxExplanationCandidates {
+		rest = rest[:maxExplanationCandidates]
+	}
+	pe.Candidates = rest
+	return pe
+}
+
+// closeness counts how many of action, ownership level and resource
+// hierarchy op satisfies for p; matches are p PermissionMatches
+func (p Permission) closeness(op Permission, matches []string) int {
+	c := 0
+	if op.Action.All() || op.Action == p.Action {
+		c++
+	}
+	if !op.OwnershipLevel.Less(p.OwnershipLevel) {
+		c++
+	}
+	for _, m := range matches {
+		if op.ResourceHierarchy.String() == m {
+			c++
+			break
+		}
+	}
+	return c
+}
+
+// sharedDepth counts how many leading parts rh and orh have in common
+func (rh ResourceHierarchy) sharedDepth(orh ResourceHierarchy) int {
+	rhParts := strings.Split(rh.String(), "::")
+	orhParts := strings.Split(orh.String(), "::")
+	d := 0
+	for d < len(rhParts) && d < len(orhParts) && rhParts[d] == orhParts[d] {
+		d++
+	}
+	return d
+}
@@ -0,0 +1,101 @@
+// +build unit
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func buildPermissionGrants(strSl []string) []models.PermissionGrant {
+	ps := buildPermissions(strSl)
+	grants := make([]models.PermissionGrant, len(ps))
+	for i := range ps {
+		grants[i] = models.PermissionGrant{Permission: ps[i], RoleName: "role"}
+	}
+	return grants
+}
+
+func TestExplainPermission(t *testing.T) {
+	type testCase struct {
+		permission     string
+		grants         []string
+		allowed        bool
+		matched        []string
+		deniedBy       []string
+		firstCandidate string
+	}
+	tt := []testCase{
+		testCase{
+			permission: "Maestro::RL::DeleteScheduler::NA::sniper",
+			grants: []string{
+				"Maestro::RO::*::*", "Maestro::RL::DeleteScheduler::NA::*",
+				"Maestro::RL::ListSchedulers::*",
+			},
+			allowed: true,
+			matched: []string{
+				"Maestro::RO::*::*", "Maestro::RL::DeleteScheduler::NA::*",
+			},
+		},
+		testCase{
+			permission: "Maestro::RL::DeleteScheduler::NA::sniper",
+			grants: []string{
+				"Maestro::RO::*::*", "Maestro::DENY::DeleteScheduler::NA::*",
+			},
+			allowed:  false,
+			matched:  []string{"Maestro::RO::*::*"},
+			deniedBy: []string{"Maestro::DENY::DeleteScheduler::NA::*"},
+		},
+		testCase{
+			permission: "Maestro::RO::DeleteScheduler::NA::sniper",
+			grants: []string{
+				"Maestro::RL::ListSchedulers::*",
+				"Maestro::RL::DeleteScheduler::EU::*",
+				"Maestro::RL::DeleteScheduler::NA::*",
+			},
+			allowed:        false,
+			firstCandidate: "Maestro::RL::DeleteScheduler::NA::*",
+		},
+		testCase{
+			permission: "Maestro::RL::DeleteScheduler::NA::sniper",
+			grants: []string{
+				"*::RL::DeleteScheduler::*",
+			},
+			allowed: true,
+			matched: []string{"*::RL::DeleteScheduler::*"},
+		},
+	}
+
+	for i, tt := range tt {
+		p, err := models.BuildPermission(tt.permission)
+		if err != nil {
+			t.Fatalf("Unexpected error %s. Case #%d", err.Error(), i)
+		}
+		pe := models.ExplainPermission(p, buildPermissionGrants(tt.grants))
+		if pe.Allowed != tt.allowed {
+			t.Errorf("Expected Allowed to be %t. Got: %t. Case #%d", tt.allowed, pe.Allowed, i)
+		}
+		if len(pe.Matched) != len(tt.matched) {
+			t.Errorf("Expected %d matched. Got: %d. Case #%d", len(tt.matched), len(pe.Matched), i)
+			continue
+		}
+		for j := range tt.matched {
+			if pe.Matched[j].String() != tt.matched[j] {
+				t.Errorf("Expected matched %s. Got: %s. Case #%d", tt.matched[j], pe.Matched[j].String(), i)
+			}
+		}
+		if len(pe.DeniedBy) != len(tt.deniedBy) {
+			t.Errorf("Expected %d deniedBy. Got: %d. Case #%d", len(tt.deniedBy), len(pe.DeniedBy), i)
+		}
+		if tt.firstCandidate == "" {
+			if len(pe.Candidates) != 0 {
+				t.Errorf("Expected no candidates. Got: %d. Case #%d", len(pe.Candidates), i)
+			}
+			continue
+		}
+		if len(pe.Candidates) == 0 || pe.Candidates[0].String() != tt.firstCandidate {
+			t.Errorf("Expected first candidate %s. Got: %v. Case #%d", tt.firstCandidate, pe.Candidates, i)
+		}
+	}
+}
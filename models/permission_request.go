@@ -14,9 +14,30 @@ type PermissionRequest struct {
 	RequesterPicture          string                 `json:"requesterPicture" pg:"requester_picture"`
 	RequesterName             string                 `json:"requesterName" pg:"requester_name"`
 	ModeratorServiceAccountID string                 `json:"moderatorServiceAccountId" pg:"moderator_service_account_id"`
+	Duration                  Duration               `json:"duration,omitempty" sql:"duration,notnull"`
+	Approvals                 int                    `json:"approvals" sql:"approvals"`
+	RequiredApprovals         int                    `json:"requiredApprovals" sql:"-"`
 	CreatedUpdatedAt
 }
 
+// PermissionRequestApproval is an owner approving a permission request for
+// Duration, zero if they didn't choose one
+type PermissionRequestApproval struct {
+	ID                  string   `json:"id" pg:"id"`
+	PermissionRequestID string   `json:"permissionRequestId" pg:"permission_request_id"`
+	ServiceAccountID    string   `json:"serviceAccountId" pg:"service_account_id"`
+	Duration            Duration `json:"duration,omitempty" sql:"duration,notnull"`
+	CreatedUpdatedAt
+}
+
+// Validate PermissionRequest model; a zero Duration asks for a permission
+// that doesn't expire
+func (pr PermissionRequest) Validate() Validation {
+	v := &Validation{}
+	pr.Duration.validate(v, "duration")
+	return *v
+}
+
 // Permission returns requested Permission from pr
 func (pr PermissionRequest) Permission() Permission {
 	return Permission{
@@ -33,13 +54,17 @@ type PermissionRequestState string
 
 // PermissionRequestStates possible
 var PermissionRequestStates = struct {
-	Open    PermissionRequestState
-	Granted PermissionRequestState
-	Denied  PermissionRequestState
+	Open      PermissionRequestState
+	Granted   PermissionRequestState
+	Denied    PermissionRequestState
+	Cancelled PermissionRequestState
+	Expired   PermissionRequestState
 }{
-	Open:    "open",
-	Granted: "granted",
-	Denied:  "denied",
+	Open:      "open",
+	Granted:   "granted",
+	Denied:    "denied",
+	Cancelled: "cancelled",
+	Expired:   "expired",
 }
 
 // String returns permission request state as string
@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/go-pg/pg"
 
 	"github.com/topfreegames/Will.IAM/models"
 )
@@ -20,7 +23,7 @@ func TestValidatePermission(t *testing.T) {
 		testCase{
 			str:   "Maestro::RX::ListSchedulers::*",
 			valid: false,
-			err:   fmt.Errorf("OwnershipLevel needs to be RO or RL"),
+			err:   fmt.Errorf("OwnershipLevel needs to be RO, RL or DENY"),
 		},
 		testCase{
 			str:   "Maestro::RO::ListSchedulers::*",
@@ -32,6 +35,11 @@ func TestValidatePermission(t *testing.T) {
 			valid: true,
 			err:   nil,
 		},
+		testCase{
+			str:   "Maestro::DENY::ListSchedulers::*",
+			valid: true,
+			err:   nil,
+		},
 		testCase{
 			str:   "Maestro::RO::ListSchedulers::",
 			valid: false,
@@ -142,6 +150,16 @@ func buildPermissions(strSl []string) []models.Permission {
 	return permissionsSl
 }
 
+func withTimeBound(
+	permissions []models.Permission, notBefore, expiresAt time.Time,
+) []models.Permission {
+	for i := range permissions {
+		permissions[i].NotBefore = pg.NullTime{Time: notBefore}
+		permissions[i].ExpiresAt = pg.NullTime{Time: expiresAt}
+	}
+	return permissions
+}
+
 func TestHasPermission(t *testing.T) {
 	type testCase struct {
 		permission  string
@@ -154,6 +172,10 @@ func TestHasPermission(t *testing.T) {
 		"Maestro::RO::ListSchedulers::Sniper3D::sniper3d-game",
 	}
 
+	now := time.Now()
+	hourAgo := now.Add(-time.Hour)
+	inAnHour := now.Add(time.Hour)
+
 	tt := []testCase{
 		testCase{
 			permission:  "Maestro::RO::ListSchedulers::Sniper3D::sniper3d-game",
@@ -165,6 +187,79 @@ func TestHasPermission(t *testing.T) {
 			permissions: buildPermissions(sniperPermissions),
 			isPresent:   false,
 		},
+		testCase{
+			permission: "Maestro::RL::DeleteScheduler::NA::sniper3d-game",
+			permissions: buildPermissions([]string{
+				"Maestro::RO::*::*", "Maestro::DENY::DeleteScheduler::NA::*",
+			}),
+			isPresent: false,
+		},
+		testCase{
+			permission: "Maestro::RL::DeleteScheduler::EU::sniper3d-game",
+			permissions: buildPermissions([]string{
+				"Maestro::RO::*::*", "Maestro::DENY::DeleteScheduler::NA::*",
+			}),
+			isPresent: true,
+		},
+		testCase{
+			permission: "Maestro::RL::ListSchedulers::NA::sniper3d-game",
+			permissions: buildPermissions([]string{
+				"Maestro::RO::*::*", "Maestro::DENY::DeleteScheduler::NA::*",
+			}),
+			isPresent: true,
+		},
+		testCase{
+			permission: "Maestro::RO::*::*",
+			permissions: buildPermissions([]string{
+				"Maestro::RO::*::*", "Maestro::DENY::DeleteScheduler::NA::*",
+			}),
+			isPresent: false,
+		},
+		testCase{
+			permission: "Maestro::RL::DeleteScheduler::NA::sniper3d-game",
+			permissions: buildPermissions([]string{
+				"Maestro::DENY::*::*", "Maestro::RO::DeleteScheduler::NA::*",
+			}),
+			isPresent: false,
+		},
+		testCase{
+			permission: "Maestro::DENY::DeleteScheduler::NA::*",
+			permissions: buildPermissions([]string{
+				"Maestro::DENY::DeleteScheduler::NA::*",
+			}),
+			isPresent: false,
+		},
+		testCase{
+			permission: "Maestro::RO::ListSchedulers::Sniper3D::sniper3d-game",
+			permissions: withTimeBound(
+				buildPermissions(sniperPermissions), hourAgo, inAnHour,
+			),
+			isPresent: true,
+		},
+		testCase{
+			permission: "Maestro::RO::ListSchedulers::Sniper3D::sniper3d-game",
+			permissions: withTimeBound(
+				buildPermissions(sniperPermissions), time.Time{}, hourAgo,
+			),
+			isPresent: false,
+		},
+		testCase{
+			permission: "Maestro::RO::ListSchedulers::Sniper3D::sniper3d-game",
+			permissions: withTimeBound(
+				buildPermissions(sniperPermissions), inAnHour, time.Time{},
+			),
+			isPresent: false,
+		},
+		testCase{
+			permission: "Maestro::RL::DeleteScheduler::NA::sniper3d-game",
+			permissions: append(
+				buildPermissions([]string{"Maestro::RO::*::*"}),
+				withTimeBound(buildPermissions([]string{
+					"Maestro::DENY::DeleteScheduler::NA::*",
+				}), time.Time{}, hourAgo)...,
+			),
+			isPresent: true,
+		},
 	}
 
 	for i, tt := range tt {
@@ -1,18 +1,34 @@
 package models
 
 // Role type
+// SCIMManaged roles were created by an identity provider through SCIM, the
+// only ones it can see and change as groups
 type Role struct {
-	ID         string `json:"id" pg:"id"`
-	Name       string `json:"name" pg:"name"`
-	IsBaseRole bool   `json:"isBaseRole" pg:"is_base_role" sql:",notnull"`
+	ID          string `json:"id" pg:"id"`
+	Name        string `json:"name" pg:"name"`
+	IsBaseRole  bool   `json:"isBaseRole" pg:"is_base_role" sql:",notnull"`
+	SCIMManaged bool   `json:"scimManaged" pg:"scim_managed" sql:",notnull"`
 	// Should change updatedAt when a permission is created for role
 	CreatedUpdatedAt
 }
 
 // RoleBinding type
+// A TimeBound limits the period in which the binding is in effect
+// FromProvider bindings were made by syncing groups of that IdP, and are
+// undone by it; manual bindings have none
 type RoleBinding struct {
 	ID               string `json:"id" pg:"id"`
 	ServiceAccountID string `json:"serviceAccountId" pg:"service_account_id"`
 	RoleID           string `json:"roleId" pg:"role_id"`
+	FromProvider     string `json:"fromProvider,omitempty" pg:"from_provider"`
+	TimeBound
+	CreatedUpdatedAt
+}
+
+// RoleInclusion makes a role include every permission of another role
+type RoleInclusion struct {
+	ID             string `json:"id" pg:"id"`
+	RoleID         string `json:"roleId" pg:"role_id"`
+	IncludedRoleID string `json:"includedRoleId" pg:"included_role_id"`
 	CreatedUpdatedAt
 }
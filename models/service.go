@@ -1,13 +1,20 @@
 package models
 
+import (
+	"net"
+	"net/url"
+)
+
 // Service type
 type Service struct {
-	ID                      string `json:"id" pg:"id"`
-	Name                    string `json:"name" pg:"name"`
-	PermissionName          string `json:"permissionName" pg:"permission_name"`
-	ServiceAccountID        string `json:"serviceAccountID" pg:"service_account_id"`
-	CreatorServiceAccountID string `json:"creatorServiceAccountID" pg:"creator_service_account_id"`
-	AMURL                   string `json:"amUrl" sql:"am_url"`
+	ID                      string   `json:"id" pg:"id"`
+	Name                    string   `json:"name" pg:"name"`
+	PermissionName          string   `json:"permissionName" pg:"permission_name"`
+	ServiceAccountID        string   `json:"serviceAccountID" pg:"service_account_id"`
+	CreatorServiceAccountID string   `json:"creatorServiceAccountID" pg:"creator_service_account_id"`
+	AMURL                   string   `json:"amUrl" sql:"am_url"`
+	RedirectOrigins         []string `json:"redirectOrigins" sql:"redirect_origins,array"`
+	RedirectURIs            []string `json:"redirectUris" sql:"redirect_uris,array"`
 	CreatedUpdatedAt
 }
 
@@ -20,5 +27,57 @@ func (s Service) Validate(fields ...string) Validation {
 	if s.PermissionName == "" {
 		v.AddError("permissionName", "required")
 	}
+	for _, origin := range s.RedirectOrigins {
+		if !isOrigin(origin) {
+			v.AddError("redirectOrigins", "must be origins as scheme://host[:port]")
+			break
+		}
+	}
+	for _, uri := range s.RedirectURIs {
+		if u, err := url.Parse(uri); err != nil || !u.IsAbs() || u.Fragment != "" ||
+			(u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+			v.AddError("redirectUris", "must be absolute URIs without fragment")
+			break
+		}
+	}
 	return *v
 }
+
+// AllowsRedirectURI tells if s, as an OAuth2 client, registered uri as one
+// of its redirect URIs; as RFC 8252 asks, http loopback IP ones match on any
+// port, since native apps get whatever port is free
+func (s Service) AllowsRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return false
+	}
+	for _, registered := range s.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+		r, err := url.Parse(registered)
+		if err != nil || r.Scheme != "http" || !isLoopbackIP(r.Hostname()) {
+			continue
+		}
+		if u.Scheme == r.Scheme && u.Hostname() == r.Hostname() &&
+			u.Path == r.Path && u.RawQuery == r.RawQuery && u.Fragment == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopbackIP(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isOrigin tells if s is an origin alone, without path, query or fragment
+func isOrigin(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.User == nil && u.Path == "" && u.RawQuery == "" && u.Fragment == ""
+}
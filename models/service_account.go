@@ -7,11 +7,15 @@ type ServiceAccount struct {
 	ID                 string             `json:"id" pg:"id"`
 	Name               string             `json:"name" pg:"name"`
 	KeyID              string             `json:"keyId" pg:"key_id"`
-	KeySecret          string             `json:"keySecret" pg:"key_secret"`
+	KeySecret          string             `json:"keySecret,omitempty" pg:"-"`
 	Email              string             `json:"email" pg:"email"`
 	Picture            string             `json:"picture" pg:"picture"`
 	BaseRoleID         string             `json:"baseRoleId" pg:"base_role_id"`
 	AuthenticationType AuthenticationType `json:"authenticationType" pg:"-"`
+	Active             bool               `json:"active" pg:"active" sql:",notnull"`
+	// VerifiedHostedDomain is the domain an OAuth2 provider vouched for at the
+	// last login, unlike Email, which whoever creates an account sets
+	VerifiedHostedDomain string `json:"verifiedHostedDomain,omitempty" pg:"verified_hosted_domain" sql:",notnull"`
 	CreatedUpdatedAt
 }
 
@@ -0,0 +1,56 @@
+// +build unit
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func TestServiceValidateRedirects(t *testing.T) {
+	tt := []struct {
+		name  string
+		s     models.Service
+		valid bool
+	}{
+		{"origin", models.Service{RedirectOrigins: []string{"https://app.example.com"}}, true},
+		{"origin with path", models.Service{RedirectOrigins: []string{"https://app.example.com/path"}}, false},
+		{"origin without scheme", models.Service{RedirectOrigins: []string{"app.example.com"}}, false},
+		{"redirect URI", models.Service{RedirectURIs: []string{"https://app.example.com/callback"}}, true},
+		{"native app redirect URI", models.Service{RedirectURIs: []string{"com.example.app:/callback"}}, true},
+		{"relative redirect URI", models.Service{RedirectURIs: []string{"/callback"}}, false},
+		{"redirect URI with fragment", models.Service{RedirectURIs: []string{"https://app.example.com/#cb"}}, false},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.s.Name = "Some Service"
+			tt.s.PermissionName = "SomeService"
+			if got := tt.s.Validate().Valid(); got != tt.valid {
+				t.Errorf("Expected valid %t. Got %t", tt.valid, got)
+			}
+		})
+	}
+}
+
+func TestServiceAllowsRedirectURI(t *testing.T) {
+	s := models.Service{RedirectURIs: []string{
+		"https://app.example.com/callback", "http://127.0.0.1/callback",
+	}}
+	tt := []struct {
+		uri      string
+		expected bool
+	}{
+		{"https://app.example.com/callback", true},
+		{"https://app.example.com/callback/other", false},
+		{"https://app.example.com:8443/callback", false},
+		{"http://127.0.0.1:8765/callback", true},
+		{"http://127.0.0.1:8765/other", false},
+		{"http://localhost:8765/callback", false},
+	}
+	for _, tt := range tt {
+		if got := s.AllowsRedirectURI(tt.uri); got != tt.expected {
+			t.Errorf("Expected %t for %s. Got %t", tt.expected, tt.uri, got)
+		}
+	}
+}
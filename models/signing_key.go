@@ -0,0 +1,57 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/topfreegames/Will.IAM/pkg/jwt"
+)
+
+// signingKeyBits is the size of generated RSA signing keys
+const signingKeyBits = 2048
+
+// SigningKey is an RSA key access tokens are signed with; its ID is the
+// kid of tokens and of its published JWK
+type SigningKey struct {
+	ID         string    `json:"id" pg:"id"`
+	PrivateKey string    `json:"-" pg:"private_key"`
+	CreatedAt  time.Time `json:"createdAt" pg:"created_at"`
+}
+
+// BuildSigningKey generates a new RSA signing key
+func BuildSigningKey() (*SigningKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{
+		ID: uuid.Must(uuid.NewV4()).String(),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})),
+	}, nil
+}
+
+// RSAPrivateKey decodes sk PrivateKey
+func (sk SigningKey) RSAPrivateKey() (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(sk.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s isn't PEM encoded", sk.ID)
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// JWK returns sk public key as JWK
+func (sk SigningKey) JWK() (jwt.JWK, error) {
+	key, err := sk.RSAPrivateKey()
+	if err != nil {
+		return jwt.JWK{}, err
+	}
+	return jwt.NewJWK(sk.ID, &key.PublicKey), nil
+}
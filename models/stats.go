@@ -0,0 +1,8 @@
+package models
+
+// Stats are counts of what Will.IAM holds, reported as metrics
+type Stats struct {
+	ServiceAccounts         int64 `json:"serviceAccounts"`
+	Roles                   int64 `json:"roles"`
+	OpenPermissionsRequests int64 `json:"openPermissionsRequests"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// TimeBound restricts when a permission or a role binding is in effect
+// A zero NotBefore or ExpiresAt leaves that end of the period open
+type TimeBound struct {
+	NotBefore pg.NullTime `json:"notBefore" pg:"not_before"`
+	ExpiresAt pg.NullTime `json:"expiresAt" pg:"expires_at"`
+}
+
+// Active checks if tb is in effect at t
+func (tb TimeBound) Active(t time.Time) bool {
+	if !tb.NotBefore.IsZero() && t.Before(tb.NotBefore.Time) {
+		return false
+	}
+	if !tb.ExpiresAt.IsZero() && !t.Before(tb.ExpiresAt.Time) {
+		return false
+	}
+	return true
+}
+
+// Bounded checks if either end of tb is set
+func (tb TimeBound) Bounded() bool {
+	return !tb.NotBefore.IsZero() || !tb.ExpiresAt.IsZero()
+}
+
+// Valid checks if tb ExpiresAt, when both ends are set, comes after NotBefore
+func (tb TimeBound) Valid() bool {
+	if tb.NotBefore.IsZero() || tb.ExpiresAt.IsZero() {
+		return true
+	}
+	return tb.NotBefore.Before(tb.ExpiresAt.Time)
+}
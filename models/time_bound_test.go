@@ -0,0 +1,87 @@
+// +build unit
+
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+func TestTimeBoundActive(t *testing.T) {
+	type testCase struct {
+		timeBound models.TimeBound
+		active    bool
+	}
+	now := time.Now()
+	hourAgo := pg.NullTime{Time: now.Add(-time.Hour)}
+	inAnHour := pg.NullTime{Time: now.Add(time.Hour)}
+	tt := []testCase{
+		testCase{
+			timeBound: models.TimeBound{},
+			active:    true,
+		},
+		testCase{
+			timeBound: models.TimeBound{NotBefore: hourAgo, ExpiresAt: inAnHour},
+			active:    true,
+		},
+		testCase{
+			timeBound: models.TimeBound{NotBefore: inAnHour},
+			active:    false,
+		},
+		testCase{
+			timeBound: models.TimeBound{ExpiresAt: hourAgo},
+			active:    false,
+		},
+		testCase{
+			timeBound: models.TimeBound{ExpiresAt: pg.NullTime{Time: now}},
+			active:    false,
+		},
+	}
+
+	for i, tt := range tt {
+		if active := tt.timeBound.Active(now); active != tt.active {
+			t.Errorf("Expected Active to be %t. Got: %t. Case #%d", tt.active, active, i)
+		}
+	}
+}
+
+func TestTimeBoundValid(t *testing.T) {
+	type testCase struct {
+		timeBound models.TimeBound
+		valid     bool
+	}
+	now := time.Now()
+	hourAgo := pg.NullTime{Time: now.Add(-time.Hour)}
+	inAnHour := pg.NullTime{Time: now.Add(time.Hour)}
+	tt := []testCase{
+		testCase{
+			timeBound: models.TimeBound{},
+			valid:     true,
+		},
+		testCase{
+			timeBound: models.TimeBound{NotBefore: inAnHour},
+			valid:     true,
+		},
+		testCase{
+			timeBound: models.TimeBound{NotBefore: hourAgo, ExpiresAt: inAnHour},
+			valid:     true,
+		},
+		testCase{
+			timeBound: models.TimeBound{NotBefore: inAnHour, ExpiresAt: hourAgo},
+			valid:     false,
+		},
+		testCase{
+			timeBound: models.TimeBound{NotBefore: inAnHour, ExpiresAt: inAnHour},
+			valid:     false,
+		},
+	}
+
+	for i, tt := range tt {
+		if valid := tt.timeBound.Valid(); valid != tt.valid {
+			t.Errorf("Expected Valid to be %t. Got: %t. Case #%d", tt.valid, valid, i)
+		}
+	}
+}
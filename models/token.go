@@ -15,6 +15,7 @@ type Token struct {
 	Expiry       time.Time   `json:"expiry" pg:"expiry"`
 	ExpiredAt    pg.NullTime `json:"expiredAt" pg:"expired_at"`
 	Email        string      `json:"email" pg:"email"`
+	Provider     string      `json:"provider" pg:"provider"`
 	CreatedUpdatedAt
 }
 
@@ -41,7 +42,43 @@ type AccessKeyPairAuth struct {
 
 // AuthResult is the result of a successful authentication
 type AuthResult struct {
+	AccessToken string   `json:"accessToken"`
+	Email       string   `json:"email"`
+	Name        string   `json:"name,omitempty"`
+	Picture     string   `json:"picture"`
+	Groups      []string `json:"groups,omitempty"`
+	// HostedDomain is the domain the provider vouches the user belongs to,
+	// empty when it doesn't
+	HostedDomain string `json:"-"`
+}
+
+// TokenIntrospection is an RFC 7662 introspection response; everything but
+// Active is left out for inactive tokens
+type TokenIntrospection struct {
+	Active           bool   `json:"active"`
+	Subject          string `json:"sub,omitempty"`
+	ServiceAccountID string `json:"service_account_id,omitempty"`
+	Email            string `json:"email,omitempty"`
+	Audience         string `json:"aud,omitempty"`
+	ClientID         string `json:"client_id,omitempty"`
+	ExpiresAt        int64  `json:"exp,omitempty"`
+	IssuedAt         int64  `json:"iat,omitempty"`
+	Issuer           string `json:"iss,omitempty"`
+	TokenType        string `json:"token_type,omitempty"`
+}
+
+// IssuedAccessToken is an access token Will.IAM signed, as returned to
+// clients; ExpiresIn is in seconds
+type IssuedAccessToken struct {
 	AccessToken string `json:"accessToken"`
-	Email       string `json:"email"`
-	Picture     string `json:"picture"`
+	TokenType   string `json:"tokenType"`
+	ExpiresIn   int64  `json:"expiresIn"`
+}
+
+// OAuth2AccessToken is an IssuedAccessToken as RFC 6749 token responses
+// name its fields
+type OAuth2AccessToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
 }
@@ -22,8 +22,10 @@ type DevOAuth2Provider struct {
 }
 
 // DevOAuth2ProviderConfig are the basic required informations to use
-// our OAuth2 dev server as oauth2 provider
+// our OAuth2 dev server as oauth2 provider; Name identifies the provider
+// in the tokens it saves
 type DevOAuth2ProviderConfig struct {
+	Name             string
 	RedirectURL      string
 	AuthorizationURL string
 	TokenURL         string
@@ -38,8 +40,8 @@ func NewDevOAuth2Provider(config DevOAuth2ProviderConfig, repo *repositories.All
 }
 
 // BuildAuthURL creates the url used to authorize an user against OAuth2 dev server
-func (p *DevOAuth2Provider) BuildAuthURL(state string) string {
-	return fmt.Sprintf("%s?response_type=code&redirect_uri=%s&state=%s", p.config.AuthorizationURL, p.config.RedirectURL, state)
+func (p *DevOAuth2Provider) BuildAuthURL(state string) (string, error) {
+	return fmt.Sprintf("%s?response_type=code&redirect_uri=%s&state=%s", p.config.AuthorizationURL, p.config.RedirectURL, url.QueryEscape(state)), nil
 }
 
 // ExchangeCode validates an auth code against a OAuth2 server
@@ -54,6 +56,7 @@ func (p *DevOAuth2Provider) ExchangeCode(code string) (*models.AuthResult, error
 	// and retrieve some data, like user email and photo
 
 	token.Email = "any@example.org"
+	token.Provider = p.config.Name
 	token.Expiry = time.Now().UTC().Add(14 * 24 * 3600 * time.Second)
 
 	if err := p.repo.Tokens.Save(token); err != nil {
@@ -117,16 +120,8 @@ func (p *DevOAuth2Provider) getToken(code string) (*models.Token, error) {
 		return nil, err
 	}
 
-	accessToken := oauthToken.AccessToken
-
-	// TODO: Will.IAM is not able to support JWT tokens due a restriction of 300 characters
-	// in accessToken field when saving a Token on database
-	if len(accessToken) > 300 {
-		accessToken = accessToken[0:300]
-	}
-
 	return &models.Token{
-		AccessToken:  accessToken,
+		AccessToken:  oauthToken.AccessToken,
 		RefreshToken: oauthToken.RefreshToken,
 		TokenType:    oauthToken.TokenType,
 		Expiry: time.Now().UTC().Add(
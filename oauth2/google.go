@@ -20,8 +20,9 @@ const tokenEndpoint = "https://www.googleapis.com/oauth2/v4/token"
 const userEndpoint = "https://www.googleapis.com/oauth2/v2/userinfo"
 
 // GoogleConfig are the basic required informations to use Google
-// as oauth2 provider
+// as oauth2 provider; Name identifies the provider in the tokens it saves
 type GoogleConfig struct {
+	Name              string
 	ClientID          string
 	ClientSecret      string
 	RedirectURL       string
@@ -51,9 +52,9 @@ type Google struct {
 }
 
 // BuildAuthURL returns an URL authenticate with Google
-func (g *Google) BuildAuthURL(state string) string {
+func (g *Google) BuildAuthURL(state string) (string, error) {
 	qs := mapToQueryStrings(map[string]string{
-		"state":        state,
+		"state":        url.QueryEscape(state),
 		"redirect_uri": g.config.RedirectURL,
 		"client_id":    g.config.ClientID,
 		"scope": strings.Join([]string{
@@ -65,7 +66,7 @@ func (g *Google) BuildAuthURL(state string) string {
 		"response_type":          "code",
 		"prompt":                 "consent",
 	})
-	return buildURL("https://accounts.google.com/o/oauth2/v2/auth", qs)
+	return buildURL("https://accounts.google.com/o/oauth2/v2/auth", qs), nil
 }
 
 func (g *Google) buildExchangeCodeForm(code string) string {
@@ -88,11 +89,14 @@ func (g *Google) ExchangeCode(code string) (*models.AuthResult, error) {
 	if err != nil {
 		return nil, err
 	}
-	allowed := g.checkHostedDomain(userInfo.HostedDomain)
+	allowed := checkHostedDomain(
+		g.config.CheckHostedDomain, g.config.HostedDomains, userInfo.HostedDomain,
+	)
 	if !allowed {
 		return nil, errors.NewNonAllowedEmailDomainError(userInfo.HostedDomain)
 	}
 	t.Email = userInfo.Email
+	t.Provider = g.config.Name
 	// TODO: don't return sso_access_token to user, return 2 tokens to sso
 	t.Expiry = time.Now().UTC().Add(14 * 24 * 3600 * time.Second)
 	if err := g.repo.Tokens.Save(t); err != nil {
@@ -102,6 +106,8 @@ func (g *Google) ExchangeCode(code string) (*models.AuthResult, error) {
 		AccessToken: t.AccessToken,
 		Email:       t.Email,
 		Picture:     userInfo.Picture,
+		// Google only sets hd for Workspace accounts of that domain
+		HostedDomain: userInfo.HostedDomain,
 	}, nil
 }
 
@@ -196,11 +202,13 @@ func (g *Google) getUserInfo(accessToken string) (*userInfo, error) {
 	return ui, nil
 }
 
-func (g *Google) checkHostedDomain(hd string) bool {
-	if !g.config.CheckHostedDomain || g.config.HostedDomains == nil || len(g.config.HostedDomains) == 0 {
+// checkHostedDomain tells if hd is allowed, when check is enabled and
+// there are hostedDomains to allow
+func checkHostedDomain(check bool, hostedDomains []string, hd string) bool {
+	if !check || hostedDomains == nil || len(hostedDomains) == 0 {
 		return true
 	}
-	for _, allowed := range g.config.HostedDomains {
+	for _, allowed := range hostedDomains {
 		if hd == allowed {
 			return true
 		}
@@ -19,8 +19,8 @@ func NewProviderBlankMock() *ProviderBlankMock {
 }
 
 // BuildAuthURL dummy
-func (p *ProviderBlankMock) BuildAuthURL(any string) string {
-	return "any"
+func (p *ProviderBlankMock) BuildAuthURL(any string) (string, error) {
+	return "any", nil
 }
 
 // ExchangeCode dummy
@@ -0,0 +1,409 @@
+package oauth2
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/pkg/jwt"
+	"github.com/topfreegames/Will.IAM/repositories"
+	extensionsHttp "github.com/topfreegames/extensions/http"
+)
+
+// minOIDCJWKSRefetchInterval bounds how often an ID token signed by an
+// unknown key makes OIDC fetch the issuer JWKS again
+const minOIDCJWKSRefetchInterval = 10 * time.Second
+
+// OIDCConfig are the basic required informations to use any OpenID Connect
+// issuer as oauth2 provider; the *Claim fields name the ID token claims
+// AuthResult fields are read from. Name identifies the provider in the
+// tokens it saves. ID tokens whose EmailVerifiedClaim is false are always
+// rejected, and the ones without it too with RequireVerifiedEmail
+type OIDCConfig struct {
+	Name                 string
+	Issuer               string
+	ClientID             string
+	ClientSecret         string
+	RedirectURL          string
+	Scopes               []string
+	EmailClaim           string
+	EmailVerifiedClaim   string
+	NameClaim            string
+	PictureClaim         string
+	GroupsClaim          string
+	HostedDomainClaim    string
+	RequireVerifiedEmail bool
+	CheckHostedDomain    bool
+	HostedDomains        []string
+}
+
+// oidcDiscovery is the part of the issuer discovery document OIDC uses
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcIssuer caches the discovery document and keys of an issuer, shared
+// by every OIDC built from the same one by WithContext
+type oidcIssuer struct {
+	mutex         sync.Mutex
+	discovery     *oidcDiscovery
+	jwks          jwt.JWKS
+	jwksFetchedAt time.Time
+}
+
+// OIDC implements Provider for any OpenID Connect issuer, found through its
+// /.well-known/openid-configuration
+type OIDC struct {
+	config OIDCConfig
+	repo   *repositories.All
+	client *http.Client
+	issuer *oidcIssuer
+}
+
+// NewOIDC ctor
+func NewOIDC(config OIDCConfig, repo *repositories.All) *OIDC {
+	return &OIDC{
+		config: config,
+		repo:   repo,
+		client: extensionsHttp.New(),
+		issuer: &oidcIssuer{},
+	}
+}
+
+// WithContext returns a new instance of *OIDC using ctx
+func (o *OIDC) WithContext(ctx context.Context) Provider {
+	return &OIDC{
+		config: o.config,
+		repo:   o.repo.WithContext(ctx),
+		client: o.client,
+		issuer: o.issuer,
+	}
+}
+
+// BuildAuthURL returns an URL to authenticate with the issuer
+func (o *OIDC) BuildAuthURL(state string) (string, error) {
+	d, err := o.discovery()
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Add("response_type", "code")
+	v.Add("client_id", o.config.ClientID)
+	v.Add("redirect_uri", o.config.RedirectURL)
+	v.Add("scope", strings.Join(o.config.Scopes, " "))
+	v.Add("state", state)
+	return buildURL(d.AuthorizationEndpoint, v.Encode()), nil
+}
+
+// ExchangeCode trades code for tokens with the issuer, and reads the
+// authenticated user from the ID token that comes with them
+func (o *OIDC) ExchangeCode(code string) (*models.AuthResult, error) {
+	v := url.Values{}
+	v.Add("code", code)
+	v.Add("client_id", o.config.ClientID)
+	v.Add("client_secret", o.config.ClientSecret)
+	v.Add("redirect_uri", o.config.RedirectURL)
+	v.Add("grant_type", "authorization_code")
+	ot, err := o.postToTokenEndpoint(v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	authResult, err := o.verifyIDToken(ot.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	t := ot.token()
+	t.Email = authResult.Email
+	t.Provider = o.config.Name
+	// TODO: don't return sso_access_token to user, return 2 tokens to sso
+	t.Expiry = time.Now().UTC().Add(14 * 24 * 3600 * time.Second)
+	if err := o.repo.Tokens.Save(t); err != nil {
+		return nil, err
+	}
+	authResult.AccessToken = t.AccessToken
+	return authResult, nil
+}
+
+// Authenticate verifies if an accessToken is valid and maybe refresh it
+func (o *OIDC) Authenticate(accessToken string) (*models.AuthResult, error) {
+	t, err := o.repo.Tokens.Get(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	authResult, err := o.maybeRefresh(t)
+	if err != nil {
+		return nil, err
+	}
+	if authResult == nil {
+		authResult = &models.AuthResult{Email: t.Email}
+	}
+	authResult.AccessToken = t.AccessToken
+	return authResult, nil
+}
+
+func (o *OIDC) maybeRefresh(t *models.Token) (*models.AuthResult, error) {
+	if t.Expiry.After(time.Now().UTC()) || !t.ExpiredAt.IsZero() {
+		return nil, nil
+	}
+	v := url.Values{}
+	v.Add("refresh_token", t.RefreshToken)
+	v.Add("client_id", o.config.ClientID)
+	v.Add("client_secret", o.config.ClientSecret)
+	v.Add("grant_type", "refresh_token")
+	ot, err := o.postToTokenEndpoint(v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	// issuers aren't required to send a new ID token on refresh
+	var authResult *models.AuthResult
+	if ot.IDToken != "" {
+		if authResult, err = o.verifyIDToken(ot.IDToken); err != nil {
+			return nil, err
+		}
+		if authResult.Email != t.Email {
+			return nil, fmt.Errorf("refreshed id_token is for %s", authResult.Email)
+		}
+	}
+	oldT := t.Clone()
+	oldT.ExpiredAt.Time = time.Now().UTC()
+	t.ID = ""
+	t.AccessToken = ot.AccessToken
+	if ot.RefreshToken != "" {
+		t.RefreshToken = ot.RefreshToken
+	}
+	t.Expiry = time.Now().UTC().Add(
+		time.Second * time.Duration(ot.ExpiresIn),
+	)
+	if err := o.repo.WithPGTx(
+		context.Background(), func(repo *repositories.All) error {
+			if err := repo.Tokens.Save(t); err != nil {
+				return err
+			}
+			return repo.Tokens.Save(oldT)
+		}); err != nil {
+		return nil, err
+	}
+	return authResult, nil
+}
+
+// OIDCToken is the expected response for token endpoints of OpenID
+// Connect issuers
+type OIDCToken struct {
+	AccessToken  string  `json:"access_token"`
+	RefreshToken string  `json:"refresh_token"`
+	TokenType    string  `json:"token_type"`
+	ExpiresIn    float64 `json:"expires_in"`
+	IDToken      string  `json:"id_token"`
+}
+
+// Validate OIDCToken; id_token is only required when exchanging codes
+func (ot OIDCToken) Validate() *models.Validation {
+	validation := &models.Validation{}
+	if ot.AccessToken == "" {
+		validation.AddError("access_token", "required")
+	}
+	if ot.TokenType == "" {
+		validation.AddError("token_type", "required")
+	}
+	if ot.ExpiresIn <= 0 {
+		validation.AddError("expires_in", "should be greater than 0")
+	}
+	return validation
+}
+
+func (ot OIDCToken) token() *models.Token {
+	return &models.Token{
+		AccessToken:  ot.AccessToken,
+		RefreshToken: ot.RefreshToken,
+		TokenType:    ot.TokenType,
+		Expiry: time.Now().UTC().Add(
+			time.Second * time.Duration(ot.ExpiresIn),
+		),
+	}
+}
+
+func (o *OIDC) postToTokenEndpoint(urlencoded string) (*OIDCToken, error) {
+	d, err := o.discovery()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(
+		"POST", d.TokenEndpoint, strings.NewReader(urlencoded),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	ot := &OIDCToken{}
+	if err := o.getJSON(req, ot); err != nil {
+		return nil, err
+	}
+	v := ot.Validate()
+	if !v.Valid() {
+		return nil, v.Error()
+	}
+	return ot, nil
+}
+
+// verifyIDToken checks idToken was signed by the issuer for this client,
+// and maps its claims into an AuthResult
+func (o *OIDC) verifyIDToken(idToken string) (*models.AuthResult, error) {
+	d, err := o.discovery()
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	claims, err := jwt.ParseWithClaims(idToken, time.Now(), o.key, &raw)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != d.Issuer {
+		return nil, fmt.Errorf("id_token issued by %s", claims.Issuer)
+	}
+	if !audienceContains(raw["aud"], o.config.ClientID) {
+		return nil, fmt.Errorf("id_token not issued to %s", o.config.ClientID)
+	}
+	authResult := &models.AuthResult{
+		Email:   stringClaim(raw, o.config.EmailClaim),
+		Name:    stringClaim(raw, o.config.NameClaim),
+		Picture: stringClaim(raw, o.config.PictureClaim),
+		Groups:  stringsClaim(raw, o.config.GroupsClaim),
+	}
+	if authResult.Email == "" {
+		return nil, fmt.Errorf("id_token has no %s claim", o.config.EmailClaim)
+	}
+	// people are found by email, so one the issuer doesn't vouch for could
+	// be anyone's
+	verified, ok := boolClaim(raw, o.config.EmailVerifiedClaim)
+	if (ok && !verified) || (!ok && o.config.RequireVerifiedEmail) {
+		return nil, errors.NewUnverifiedEmailError(authResult.Email)
+	}
+	hd := stringClaim(raw, o.config.HostedDomainClaim)
+	if hd == "" {
+		hd = authResult.Email[strings.LastIndex(authResult.Email, "@")+1:]
+	}
+	if !checkHostedDomain(
+		o.config.CheckHostedDomain, o.config.HostedDomains, hd,
+	) {
+		return nil, errors.NewNonAllowedEmailDomainError(hd)
+	}
+	authResult.HostedDomain = hd
+	return authResult, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func stringClaim(raw map[string]interface{}, claim string) string {
+	s, _ := raw[claim].(string)
+	return s
+}
+
+// boolClaim reads claim, which some issuers send as a "true" or "false"
+// string; ok is false when it's missing or neither
+func boolClaim(raw map[string]interface{}, claim string) (value, ok bool) {
+	switch v := raw[claim].(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+func stringsClaim(raw map[string]interface{}, claim string) []string {
+	values, _ := raw[claim].([]interface{})
+	ss := []string{}
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+func (o *OIDC) discovery() (*oidcDiscovery, error) {
+	o.issuer.mutex.Lock()
+	defer o.issuer.mutex.Unlock()
+	if o.issuer.discovery != nil {
+		return o.issuer.discovery, nil
+	}
+	req, err := http.NewRequest("GET", fmt.Sprintf(
+		"%s/.well-known/openid-configuration",
+		strings.TrimSuffix(o.config.Issuer, "/"),
+	), nil)
+	if err != nil {
+		return nil, err
+	}
+	d := &oidcDiscovery{}
+	if err := o.getJSON(req, d); err != nil {
+		return nil, err
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" ||
+		d.JWKSURI == "" {
+		return nil, fmt.Errorf("incomplete discovery document at %s", req.URL)
+	}
+	o.issuer.discovery = d
+	return d, nil
+}
+
+func (o *OIDC) key(kid string) (*rsa.PublicKey, error) {
+	o.issuer.mutex.Lock()
+	defer o.issuer.mutex.Unlock()
+	key, err := o.issuer.jwks.Key(kid)
+	if err != jwt.ErrUnknownKey ||
+		time.Since(o.issuer.jwksFetchedAt) < minOIDCJWKSRefetchInterval {
+		return key, err
+	}
+	req, err := http.NewRequest("GET", o.issuer.discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	jwks := jwt.JWKS{}
+	if err := o.getJSON(req, &jwks); err != nil {
+		return nil, err
+	}
+	o.issuer.jwks = jwks
+	o.issuer.jwksFetchedAt = time.Now()
+	return jwks.Key(kid)
+}
+
+func (o *OIDC) getJSON(req *http.Request, v interface{}) error {
+	res, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf(
+			"%s %s returned %d: %s", req.Method, req.URL, res.StatusCode, body,
+		)
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,239 @@
+// +build integration
+
+package oauth2_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/oauth2"
+	"github.com/topfreegames/Will.IAM/pkg/jwt"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+)
+
+// mockIssuer is an OpenID Connect issuer whose token endpoint answers each
+// code with an ID token carrying idTokens[code]
+type mockIssuer struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	idTokens map[string]map[string]interface{}
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	mi := &mockIssuer{key: key, idTokens: map[string]map[string]interface{}{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 mi.URL,
+			"authorization_endpoint": mi.URL + "/authorize",
+			"token_endpoint":         mi.URL + "/token",
+			"jwks_uri":               mi.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jwt.JWKS{
+			Keys: []jwt.JWK{jwt.NewJWK("kid", &key.PublicKey)},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		claims, ok := mi.idTokens[r.PostForm.Get("code")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access " + r.PostForm.Get("code"),
+			"refresh_token": "refresh " + r.PostForm.Get("code"),
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"id_token":      mi.sign(t, claims),
+		})
+	})
+	mi.Server = httptest.NewServer(mux)
+	return mi
+}
+
+func (mi *mockIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "kid"})
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." +
+		base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, mi.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (mi *mockIssuer) claims(overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":    mi.URL,
+		"sub":    "some subject",
+		"aud":    "client id",
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"mail":   "some.user@corp.com",
+		"name":   "Some User",
+		"avatar": "http://some.picture",
+		"roles":  []string{"developers", "admins"},
+
+		"email_verified": true,
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+	return claims
+}
+
+func TestOIDC(t *testing.T) {
+	mi := newMockIssuer(t)
+	defer mi.Close()
+	config := oauth2.OIDCConfig{
+		Issuer:               mi.URL,
+		ClientID:             "client id",
+		ClientSecret:         "client secret",
+		RedirectURL:          "http://localhost:4040/sso/auth/done",
+		Scopes:               []string{"openid", "email"},
+		EmailClaim:           "mail",
+		EmailVerifiedClaim:   "email_verified",
+		NameClaim:            "name",
+		PictureClaim:         "avatar",
+		GroupsClaim:          "roles",
+		HostedDomainClaim:    "hd",
+		RequireVerifiedEmail: true,
+		CheckHostedDomain:    true,
+		HostedDomains:        []string{"corp.com"},
+	}
+
+	t.Run("BuildAuthURL", func(t *testing.T) {
+		authURL, err := oauth2.NewOIDC(config, nil).BuildAuthURL("some state")
+		if err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+		u, _ := url.Parse(authURL)
+		if got := u.Scheme + "://" + u.Host + u.Path; got != mi.URL+"/authorize" {
+			t.Errorf("Expected authorization endpoint %s. Got %s", mi.URL+"/authorize", got)
+		}
+		want := url.Values{
+			"response_type": {"code"},
+			"client_id":     {"client id"},
+			"redirect_uri":  {"http://localhost:4040/sso/auth/done"},
+			"scope":         {"openid email"},
+			"state":         {"some state"},
+		}
+		if !reflect.DeepEqual(u.Query(), want) {
+			t.Errorf("Expected query %v. Got %v", want, u.Query())
+		}
+	})
+
+	t.Run("ExchangeCode", func(t *testing.T) {
+		helpers.CleanupPG(t)
+		repo := helpers.GetRepo(t)
+		mi.idTokens["valid"] = mi.claims(nil)
+		authResult, err := oauth2.NewOIDC(config, repo).ExchangeCode("valid")
+		if err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+		want := &models.AuthResult{
+			AccessToken: "access valid",
+			Email:       "some.user@corp.com",
+			Name:        "Some User",
+			Picture:     "http://some.picture",
+			Groups:      []string{"developers", "admins"},
+
+			HostedDomain: "corp.com",
+		}
+		if !reflect.DeepEqual(authResult, want) {
+			t.Errorf("Expected %#v. Got %#v", want, authResult)
+		}
+		token, err := repo.Tokens.Get("access valid")
+		if err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+		if token.Email != want.Email || token.RefreshToken != "refresh valid" {
+			t.Errorf("Expected token saved for %s. Got %#v", want.Email, token)
+		}
+	})
+
+	t.Run("ExchangeCode accepts emails verified or not required to be", func(t *testing.T) {
+		helpers.CleanupPG(t)
+		repo := helpers.GetRepo(t)
+		mi.idTokens["verified-string"] = mi.claims(map[string]interface{}{"email_verified": "true"})
+		if _, err := oauth2.NewOIDC(config, repo).ExchangeCode("verified-string"); err != nil {
+			t.Errorf("Unexpected error %s", err.Error())
+		}
+		notRequired := config
+		notRequired.RequireVerifiedEmail = false
+		mi.idTokens["no-verified-claim"] = mi.claims(map[string]interface{}{"email_verified": nil})
+		if _, err := oauth2.NewOIDC(notRequired, repo).ExchangeCode("no-verified-claim"); err != nil {
+			t.Errorf("Unexpected error %s", err.Error())
+		}
+		mi.idTokens["unverified-not-required"] = mi.claims(map[string]interface{}{"email_verified": false})
+		_, err := oauth2.NewOIDC(notRequired, repo).ExchangeCode("unverified-not-required")
+		if _, ok := err.(*errors.UnverifiedEmailError); !ok {
+			t.Errorf("Expected UnverifiedEmailError. Got %v", err)
+		}
+	})
+
+	tt := []struct {
+		name        string
+		overrides   map[string]interface{}
+		domainErr   bool
+		verifiedErr bool
+	}{
+		{"other issuer", map[string]interface{}{"iss": "http://other.issuer"}, false, false},
+		{"other audience", map[string]interface{}{"aud": "other client"}, false, false},
+		{"audience list", map[string]interface{}{"aud": []string{"other client"}}, false, false},
+		{"expired", map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}, false, false},
+		{"no email", map[string]interface{}{"mail": nil}, false, false},
+		{"non allowed email domain", map[string]interface{}{"mail": "some.user@other.com"}, true, false},
+		{"non allowed hosted domain", map[string]interface{}{"hd": "other.com"}, true, false},
+		{"unverified email", map[string]interface{}{"email_verified": false}, false, true},
+		{"unverified email string", map[string]interface{}{"email_verified": "false"}, false, true},
+		{"no email verified claim", map[string]interface{}{"email_verified": nil}, false, true},
+	}
+	for _, tt := range tt {
+		t.Run(fmt.Sprintf("ExchangeCode rejects %s", tt.name), func(t *testing.T) {
+			code := strings.Replace(tt.name, " ", "-", -1)
+			mi.idTokens[code] = mi.claims(tt.overrides)
+			_, err := oauth2.NewOIDC(config, nil).ExchangeCode(code)
+			if err == nil {
+				t.Fatal("Expected error. Got nil")
+			}
+			_, isDomainErr := err.(*errors.NonAllowedEmailDomainError)
+			if isDomainErr != tt.domainErr {
+				t.Errorf("Expected NonAllowedEmailDomainError %t. Got %s", tt.domainErr, err.Error())
+			}
+			_, isVerifiedErr := err.(*errors.UnverifiedEmailError)
+			if isVerifiedErr != tt.verifiedErr {
+				t.Errorf("Expected UnverifiedEmailError %t. Got %s", tt.verifiedErr, err.Error())
+			}
+		})
+	}
+}
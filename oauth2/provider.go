@@ -2,6 +2,7 @@ package oauth2
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/spf13/viper"
 	"github.com/topfreegames/Will.IAM/models"
@@ -10,7 +11,7 @@ import (
 
 // Provider is the contract any OAuth2 implementation must follow
 type Provider interface {
-	BuildAuthURL(string) string
+	BuildAuthURL(string) (string, error)
 	ExchangeCode(string) (*models.AuthResult, error)
 	Authenticate(string) (*models.AuthResult, error)
 	WithContext(context.Context) Provider
@@ -18,21 +19,111 @@ type Provider interface {
 
 // GetOAuthProvider returns an instance of a provider given a type selection on config
 func GetOAuthProvider(config *viper.Viper, repo *repositories.All) Provider {
-	providerType := config.GetString("oauth2.provider")
+	providerType := defaultOAuthProviderType(config)
+	return getOAuthProvider(config, providerType, providerType, repo)
+}
+
+// GetOAuthProviders returns every provider named in oauth2.providers, each
+// configured under oauth2.{name} and of type oauth2.{name}.type, which
+// defaults to name. Without oauth2.providers, it returns the single
+// provider GetOAuthProvider would, named after its type. Since accounts are
+// linked by email whatever provider authenticated them, every google or oidc
+// provider but the default must check hosted domains
+func GetOAuthProviders(
+	config *viper.Viper, repo *repositories.All,
+) (*Providers, error) {
+	names := config.GetStringSlice("oauth2.providers")
+	if len(names) == 0 {
+		names = []string{defaultOAuthProviderType(config)}
+	}
+	providers := NewProviders(repo)
+	for i, name := range names {
+		typeKey := fmt.Sprintf("oauth2.%s.type", name)
+		config.SetDefault(typeKey, name)
+		providerType := config.GetString(typeKey)
+		if i > 0 && !checksHostedDomain(config, name, providerType) {
+			return nil, fmt.Errorf(
+				"oauth2 provider %s must set checkHostedDomain and hostedDomains",
+				name,
+			)
+		}
+		providers.Add(name, getOAuthProvider(config, name, providerType, repo))
+	}
+	return providers, nil
+}
+
+func checksHostedDomain(config *viper.Viper, name, providerType string) bool {
+	if providerType != "google" && providerType != "oidc" {
+		return true
+	}
+	return config.GetBool(fmt.Sprintf("oauth2.%s.checkHostedDomain", name)) &&
+		len(config.GetStringSlice(fmt.Sprintf("oauth2.%s.hostedDomains", name))) > 0
+}
+
+func defaultOAuthProviderType(config *viper.Viper) string {
+	if providerType := config.GetString("oauth2.provider"); providerType != "" {
+		return providerType
+	}
+	return "google"
+}
+
+func getOAuthProvider(
+	config *viper.Viper, name, providerType string, repo *repositories.All,
+) Provider {
+	key := func(k string) string {
+		return fmt.Sprintf("oauth2.%s.%s", name, k)
+	}
 
 	if providerType == "dev" {
 		return NewDevOAuth2Provider(DevOAuth2ProviderConfig{
-			RedirectURL:      config.GetString("oauth2.dev.redirectUrl"),
-			AuthorizationURL: config.GetString("oauth2.dev.authorizationUrl"),
-			TokenURL:         config.GetString("oauth2.dev.tokenUrl"),
+			Name:             name,
+			RedirectURL:      config.GetString(key("redirectUrl")),
+			AuthorizationURL: config.GetString(key("authorizationUrl")),
+			TokenURL:         config.GetString(key("tokenUrl")),
+		}, repo)
+	}
+
+	if providerType == "oidc" {
+		loadDefaultOIDCConfig(config, name)
+		return NewOIDC(OIDCConfig{
+			Name:                 name,
+			Issuer:               config.GetString(key("issuer")),
+			ClientID:             config.GetString(key("clientId")),
+			ClientSecret:         config.GetString(key("clientSecret")),
+			RedirectURL:          config.GetString(key("redirectUrl")),
+			Scopes:               config.GetStringSlice(key("scopes")),
+			EmailClaim:           config.GetString(key("claims.email")),
+			EmailVerifiedClaim:   config.GetString(key("claims.emailVerified")),
+			NameClaim:            config.GetString(key("claims.name")),
+			PictureClaim:         config.GetString(key("claims.picture")),
+			GroupsClaim:          config.GetString(key("claims.groups")),
+			HostedDomainClaim:    config.GetString(key("claims.hostedDomain")),
+			RequireVerifiedEmail: config.GetBool(key("requireVerifiedEmail")),
+			CheckHostedDomain:    config.GetBool(key("checkHostedDomain")),
+			HostedDomains:        config.GetStringSlice(key("hostedDomains")),
 		}, repo)
 	}
 
 	return NewGoogle(GoogleConfig{
-		ClientID:          config.GetString("oauth2.google.clientId"),
-		ClientSecret:      config.GetString("oauth2.google.clientSecret"),
-		RedirectURL:       config.GetString("oauth2.google.redirectUrl"),
-		CheckHostedDomain: config.GetBool("oauth2.google.checkHostedDomain"),
-		HostedDomains:     config.GetStringSlice("oauth2.google.hostedDomains"),
+		Name:              name,
+		ClientID:          config.GetString(key("clientId")),
+		ClientSecret:      config.GetString(key("clientSecret")),
+		RedirectURL:       config.GetString(key("redirectUrl")),
+		CheckHostedDomain: config.GetBool(key("checkHostedDomain")),
+		HostedDomains:     config.GetStringSlice(key("hostedDomains")),
 	}, repo)
 }
+
+func loadDefaultOIDCConfig(config *viper.Viper, name string) {
+	key := func(k string) string {
+		return fmt.Sprintf("oauth2.%s.%s", name, k)
+	}
+	config.SetDefault(key("scopes"), []string{"openid", "email", "profile"})
+	config.SetDefault(key("claims.email"), "email")
+	config.SetDefault(key("claims.emailVerified"), "email_verified")
+	config.SetDefault(key("claims.name"), "name")
+	config.SetDefault(key("claims.picture"), "picture")
+	config.SetDefault(key("claims.groups"), "groups")
+	config.SetDefault(key("claims.hostedDomain"), "hd")
+	config.SetDefault(key("requireVerifiedEmail"), true)
+}
@@ -1,6 +1,7 @@
 package oauth2_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/topfreegames/Will.IAM/oauth2"
@@ -20,6 +21,15 @@ func TestGetOAuthProvider(t *testing.T) {
 		} 
 	})
 
+	t.Run("OIDCProvider", func (t *testing.T) {
+		config.Set("oauth2.provider", "oidc")
+		provider := oauth2.GetOAuthProvider(config, repo)
+
+		if _, ok := provider.(*oauth2.OIDC); !ok {
+			t.Errorf("Expected provider *oauth2.OIDC, received %T", provider)
+		} 
+	})
+
 	t.Run("DevOAuth2Provider", func (t *testing.T) {
 		config.Set("oauth2.provider", "dev")
 		provider := oauth2.GetOAuthProvider(config, repo)
@@ -28,4 +38,79 @@ func TestGetOAuthProvider(t *testing.T) {
 			t.Errorf("Expected provider *oauth2.DevOAuth2Provider, received %T", provider)
 		} 
 	})
-}
\ No newline at end of file
+}
+func TestGetOAuthProviders(t *testing.T) {
+	config := helpers.GetConfig(t)
+	repo := helpers.GetRepo(t)
+
+	t.Run("SingleProvider", func(t *testing.T) {
+		config.Set("oauth2.provider", "dev")
+		providers, err := oauth2.GetOAuthProviders(config, repo)
+		if err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+
+		if names := providers.Names(); len(names) != 1 || names[0] != "dev" {
+			t.Errorf("Expected providers [dev], received %v", names)
+		}
+		if provider, _ := providers.Get(""); provider == nil {
+			t.Error("Expected default provider dev, received nil")
+		}
+	})
+
+	t.Run("NamedProviders", func(t *testing.T) {
+		config.Set("oauth2.providers", []string{"employees", "contractors", "google"})
+		config.Set("oauth2.employees.type", "google")
+		config.Set("oauth2.contractors.type", "oidc")
+		for _, name := range []string{"contractors", "google"} {
+			config.Set(fmt.Sprintf("oauth2.%s.checkHostedDomain", name), true)
+			config.Set(fmt.Sprintf("oauth2.%s.hostedDomains", name), []string{"corp.com"})
+		}
+		providers, err := oauth2.GetOAuthProviders(config, repo)
+		if err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+
+		if providers.Default() != "employees" {
+			t.Errorf("Expected default provider employees, received %s", providers.Default())
+		}
+		for name, want := range map[string]oauth2.Provider{
+			"employees":   &oauth2.Google{},
+			"contractors": &oauth2.OIDC{},
+			"google":      &oauth2.Google{},
+		} {
+			provider, ok := providers.Get(name)
+			if !ok {
+				t.Errorf("Expected provider %s", name)
+				continue
+			}
+			if fmt.Sprintf("%T", provider) != fmt.Sprintf("%T", want) {
+				t.Errorf("Expected provider %s %T, received %T", name, want, provider)
+			}
+		}
+		if _, ok := providers.Get("other"); ok {
+			t.Error("Expected no provider other")
+		}
+	})
+
+	t.Run("NonDefaultProviderWithoutHostedDomains", func(t *testing.T) {
+		config.Set("oauth2.providers", []string{"employees", "partners"})
+		config.Set("oauth2.employees.type", "google")
+		config.Set("oauth2.partners.type", "oidc")
+		config.Set("oauth2.partners.checkHostedDomain", false)
+		if _, err := oauth2.GetOAuthProviders(config, repo); err == nil {
+			t.Error("Expected error without checkHostedDomain on partners")
+		}
+
+		config.Set("oauth2.partners.checkHostedDomain", true)
+		config.Set("oauth2.partners.hostedDomains", []string{})
+		if _, err := oauth2.GetOAuthProviders(config, repo); err == nil {
+			t.Error("Expected error without hostedDomains on partners")
+		}
+
+		config.Set("oauth2.partners.hostedDomains", []string{"partner.com"})
+		if _, err := oauth2.GetOAuthProviders(config, repo); err != nil {
+			t.Errorf("Unexpected error %s", err.Error())
+		}
+	})
+}
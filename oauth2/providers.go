@@ -0,0 +1,91 @@
+package oauth2
+
+import (
+	"context"
+
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// Providers are several named Providers configured at once; the first one
+// added is the default. Providers is a Provider too, that builds auth URLs
+// and exchanges codes with the default one, and sends Authenticate to the
+// one that issued the token
+type Providers struct {
+	names     []string
+	providers map[string]Provider
+	repo      *repositories.All
+}
+
+// NewProviders ctor
+func NewProviders(repo *repositories.All) *Providers {
+	return &Providers{
+		names:     []string{},
+		providers: map[string]Provider{},
+		repo:      repo,
+	}
+}
+
+// Add provider named name
+func (ps *Providers) Add(name string, provider Provider) *Providers {
+	if _, ok := ps.providers[name]; !ok {
+		ps.names = append(ps.names, name)
+	}
+	ps.providers[name] = provider
+	return ps
+}
+
+// Names returns the names of every provider, the default first
+func (ps *Providers) Names() []string {
+	return ps.names
+}
+
+// Default returns the name of the default provider
+func (ps *Providers) Default() string {
+	if len(ps.names) == 0 {
+		return ""
+	}
+	return ps.names[0]
+}
+
+// Get returns the provider named name, or the default one if name is empty
+func (ps *Providers) Get(name string) (Provider, bool) {
+	if name == "" {
+		name = ps.Default()
+	}
+	provider, ok := ps.providers[name]
+	return provider, ok
+}
+
+// BuildAuthURL returns an URL to authenticate with the default provider
+func (ps *Providers) BuildAuthURL(state string) (string, error) {
+	return ps.providers[ps.Default()].BuildAuthURL(state)
+}
+
+// ExchangeCode will trade code for full token with the default provider
+func (ps *Providers) ExchangeCode(code string) (*models.AuthResult, error) {
+	return ps.providers[ps.Default()].ExchangeCode(code)
+}
+
+// Authenticate verifies accessToken with the provider that issued it;
+// tokens saved before providers were recorded go to the default one
+func (ps *Providers) Authenticate(accessToken string) (*models.AuthResult, error) {
+	t, err := ps.repo.Tokens.Get(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := ps.Get(t.Provider)
+	if !ok {
+		provider, _ = ps.Get("")
+	}
+	return provider.Authenticate(accessToken)
+}
+
+// WithContext returns a new instance of *Providers using ctx
+func (ps *Providers) WithContext(ctx context.Context) Provider {
+	providers := NewProviders(ps.repo.WithContext(ctx))
+	for _, name := range ps.names {
+		providers.Add(name, ps.providers[name].WithContext(ctx))
+	}
+	return providers
+}
@@ -0,0 +1,105 @@
+package oauth2
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State is what SSO carries through providers: the provider chosen, where
+// to send the user back to, and the nonce the browser that started it
+// keeps, so that only it can finish it
+// Authorization: the query of the /oauth2/authorize request SSO was started
+// by, if it was, to answer it once finished
+type State struct {
+	Provider      string
+	Referer       string
+	Authorization string
+	Nonce         string
+	ExpiresAt     time.Time
+}
+
+// StateSigner signs States so they can't be forged nor tampered with
+type StateSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewStateSigner ctor; States expire ttl after being signed
+func NewStateSigner(secret []byte, ttl time.Duration) *StateSigner {
+	return &StateSigner{secret: secret, ttl: ttl}
+}
+
+// NewNonce generates a random nonce to bind a State to
+func NewNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *StateSigner) mac(payload string) string {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Sign returns st as a signed state, expiring from now on; st.ExpiresAt is
+// ignored
+func (s *StateSigner) Sign(st State, now time.Time) string {
+	v := url.Values{}
+	v.Add("provider", st.Provider)
+	v.Add("referer", st.Referer)
+	v.Add("authorization", st.Authorization)
+	v.Add("nonce", st.Nonce)
+	v.Add("exp", strconv.FormatInt(now.Add(s.ttl).Unix(), 10))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(v.Encode()))
+	return fmt.Sprintf("%s.%s", payload, s.mac(payload))
+}
+
+// Verify returns the State signed as state, failing if it wasn't signed by
+// s, expired or isn't bound to nonce
+func (s *StateSigner) Verify(
+	state, nonce string, now time.Time,
+) (*State, error) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 2 ||
+		!hmac.Equal([]byte(parts[1]), []byte(s.mac(parts[0]))) {
+		return nil, fmt.Errorf("invalid state signature")
+	}
+	bts, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	v, err := url.ParseQuery(string(bts))
+	if err != nil {
+		return nil, err
+	}
+	exp, err := strconv.ParseInt(v.Get("exp"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	st := &State{
+		Provider:      v.Get("provider"),
+		Referer:       v.Get("referer"),
+		Authorization: v.Get("authorization"),
+		Nonce:         v.Get("nonce"),
+		ExpiresAt:     time.Unix(exp, 0),
+	}
+	if !now.Before(st.ExpiresAt) {
+		return nil, fmt.Errorf("state expired at %s", st.ExpiresAt)
+	}
+	if nonce == "" ||
+		!hmac.Equal([]byte(st.Nonce), []byte(nonce)) {
+		return nil, fmt.Errorf("state isn't bound to this browser")
+	}
+	return st, nil
+}
@@ -0,0 +1,48 @@
+// +build unit
+
+package oauth2_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/oauth2"
+)
+
+func TestStateSigner(t *testing.T) {
+	now := time.Now()
+	signer := oauth2.NewStateSigner([]byte("secret"), 10*time.Minute)
+	state := signer.Sign(oauth2.State{
+		Provider: "google", Referer: "http://some.referer/?some=query", Nonce: "nonce",
+	}, now)
+
+	st, err := signer.Verify(state, "nonce", now)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if st.Provider != "google" || st.Referer != "http://some.referer/?some=query" {
+		t.Errorf("Unexpected state %#v", st)
+	}
+
+	tt := []struct {
+		name   string
+		signer *oauth2.StateSigner
+		state  string
+		nonce  string
+		now    time.Time
+	}{
+		{"other secret", oauth2.NewStateSigner([]byte("other"), 10*time.Minute), state, "nonce", now},
+		{"tampered", signer, "x" + state, "nonce", now},
+		{"not signed", signer, "referer=http://evil.com", "nonce", now},
+		{"other nonce", signer, state, "other", now},
+		{"no nonce", signer, state, "", now},
+		{"expired", signer, state, "nonce", now.Add(11 * time.Minute)},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.Verify(tt.state, tt.nonce, tt.now); err == nil {
+				t.Errorf("Expected error")
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ type (
 		URL        string
 		Middleware *configMiddleware
 		Permission *configPermission
+		JWKS       *configJWKS
 	}
 
 	configHTTP struct {
@@ -26,6 +27,12 @@ type (
 	configPermission struct {
 		Service string
 	}
+
+	configJWKS struct {
+		Issuer          string
+		Audience        string
+		RefreshInterval time.Duration
+	}
 )
 
 // NewConfig returns the config struct with default values
@@ -43,5 +50,9 @@ func NewConfig() *config {
 		Permission: &configPermission{
 			Service: "service",
 		},
+		JWKS: &configJWKS{
+			Issuer:          "Will.IAM",
+			RefreshInterval: time.Hour,
+		},
 	}
 }
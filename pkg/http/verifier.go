@@ -0,0 +1,107 @@
+package http
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/pkg/jwt"
+)
+
+// minJWKSRefetchInterval bounds how often an unknown kid makes Verifier
+// fetch the JWKS again
+const minJWKSRefetchInterval = 10 * time.Second
+
+// ErrUnexpectedIssuer is returned for tokens not issued by the configured
+// Will.IAM
+var ErrUnexpectedIssuer = errors.New("unexpected access token issuer")
+
+// ErrUnexpectedAudience is returned for tokens issued to another OAuth2
+// client
+var ErrUnexpectedAudience = errors.New("unexpected access token audience")
+
+// Verifier verifies access tokens issued by Will.IAM offline, against the
+// keys it publishes at /.well-known/jwks.json, without calling it on every
+// request. Keys are fetched again every JWKS.RefreshInterval, or as soon
+// as a token signed by an unknown key shows up, since keys rotate
+type Verifier struct {
+	client          *http.Client
+	jwksURL         string
+	issuer          string
+	audience        string
+	refreshInterval time.Duration
+
+	mutex     sync.Mutex
+	jwks      jwt.JWKS
+	fetchedAt time.Time
+}
+
+// NewVerifier returns a Verifier of access tokens issued by the Will.IAM
+// at cnf.URL
+func NewVerifier(cnf *config) *Verifier {
+	return &Verifier{
+		client: &http.Client{
+			Transport: getHTTPTransport(cnf),
+			Timeout:   cnf.HTTP.Timeout,
+		},
+		jwksURL:         fmt.Sprintf("%s/.well-known/jwks.json", cnf.URL),
+		issuer:          cnf.JWKS.Issuer,
+		audience:        cnf.JWKS.Audience,
+		refreshInterval: cnf.JWKS.RefreshInterval,
+	}
+}
+
+// Verify returns token claims, whose Subject is the service account id,
+// if token was signed by Will.IAM, hasn't expired and, if it was issued to
+// an OAuth2 client, that client is JWKS.Audience
+func (v *Verifier) Verify(token string) (*jwt.Claims, error) {
+	claims, err := jwt.Parse(token, time.Now(), v.key)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != v.issuer {
+		return nil, ErrUnexpectedIssuer
+	}
+	if claims.Audience != "" && claims.Audience != v.audience {
+		return nil, ErrUnexpectedAudience
+	}
+	return claims, nil
+}
+
+func (v *Verifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	sinceFetch := time.Since(v.fetchedAt)
+	if sinceFetch < v.refreshInterval {
+		key, err := v.jwks.Key(kid)
+		if err != jwt.ErrUnknownKey || sinceFetch < minJWKSRefetchInterval {
+			return key, err
+		}
+	}
+	if err := v.fetch(); err != nil {
+		return nil, err
+	}
+	return v.jwks.Key(kid)
+}
+
+func (v *Verifier) fetch() error {
+	res, err := v.client.Get(v.jwksURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s returned status %d", v.jwksURL, res.StatusCode)
+	}
+	jwks := jwt.JWKS{}
+	if err := json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return err
+	}
+	v.jwks = jwks
+	v.fetchedAt = time.Now()
+	return nil
+}
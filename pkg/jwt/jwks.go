@@ -0,0 +1,66 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"math/big"
+)
+
+// JWK is an RSA public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// NewJWK builds the JWK of key, identified by kid
+func NewJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: Algorithm,
+		KeyID:     kid,
+		Modulus:   encoding.EncodeToString(key.N.Bytes()),
+		Exponent:  encoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// PublicKey decodes k into an RSA public key
+func (k JWK) PublicKey() (*rsa.PublicKey, error) {
+	if k.KeyType != "RSA" {
+		return nil, ErrMalformed
+	}
+	n, err := encoding.DecodeString(k.Modulus)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	e, err := encoding.DecodeString(k.Exponent)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, ErrMalformed
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Key returns the public key identified by kid
+func (s JWKS) Key(kid string) (*rsa.PublicKey, error) {
+	for _, k := range s.Keys {
+		if k.KeyID == kid {
+			return k.PublicKey()
+		}
+	}
+	return nil, ErrUnknownKey
+}
@@ -0,0 +1,158 @@
+// Package jwt signs and verifies the RS256 JSON Web Tokens Will.IAM issues
+// as access tokens, and the JSON Web Key Sets it publishes their keys in
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Algorithm is the only signing algorithm issued and accepted
+const Algorithm = "RS256"
+
+var (
+	// ErrMalformed is returned for tokens that aren't RS256 JWTs
+	ErrMalformed = errors.New("jwt: malformed token")
+	// ErrUnknownKey is returned when no key matches the token kid
+	ErrUnknownKey = errors.New("jwt: unknown signing key")
+	// ErrInvalidSignature is returned when the token signature doesn't match
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	// ErrExpired is returned for tokens past their exp
+	ErrExpired = errors.New("jwt: token expired")
+)
+
+// Claims carried by Will.IAM access tokens
+// Subject: the service account id
+// Audience: the client id of tokens issued to an OAuth2 client for its own
+// use, empty for tokens valid against Will.IAM and every service
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Name      string `json:"name,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	ID        string `json:"jti"`
+}
+
+// Expiry returns c ExpiresAt as time
+func (c Claims) Expiry() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Type      string `json:"typ"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// Sign encodes claims as a JWT signed by key, identified by kid
+func Sign(claims Claims, kid string, key *rsa.PrivateKey) (string, error) {
+	h, err := json.Marshal(header{Algorithm: Algorithm, KeyID: kid, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := encoding.EncodeToString(h) + "." + encoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + encoding.EncodeToString(sig), nil
+}
+
+// IsJWT checks if token has the shape of a JWT, without verifying it
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Unverified returns token claims without verifying its signature; it
+// must only be used to tell which verification token needs
+func Unverified(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Parse verifies token was signed by the key keyFor returns for its kid,
+// and that it hasn't expired at now, returning its claims
+func Parse(
+	token string, now time.Time, keyFor func(string) (*rsa.PublicKey, error),
+) (*Claims, error) {
+	return ParseWithClaims(token, now, keyFor, nil)
+}
+
+// ParseWithClaims is Parse that also unmarshals every claim of token into
+// extra, when not nil, for tokens carrying claims Claims doesn't know
+func ParseWithClaims(
+	token string, now time.Time, keyFor func(string) (*rsa.PublicKey, error),
+	extra interface{},
+) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	h := &header{}
+	if err := decodeSegment(parts[0], h); err != nil {
+		return nil, err
+	}
+	if h.Algorithm != Algorithm {
+		return nil, ErrMalformed
+	}
+	sig, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	key, err := keyFor(h.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	claims := &Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, err
+	}
+	if !now.Before(claims.Expiry()) {
+		return nil, ErrExpired
+	}
+	if extra != nil {
+		if err := decodeSegment(parts[1], extra); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	bts, err := encoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformed
+	}
+	if err := json.Unmarshal(bts, v); err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// +build unit
+
+package jwt_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/pkg/jwt"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	return key
+}
+
+func TestSignParse(t *testing.T) {
+	key := generateKey(t)
+	otherKey := generateKey(t)
+	now := time.Now()
+	claims := jwt.Claims{
+		Issuer:    "Will.IAM",
+		Subject:   "some sa id",
+		Email:     "some@email.com",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+		ID:        "some jti",
+	}
+	token, err := jwt.Sign(claims, "kid", key)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if !jwt.IsJWT(token) {
+		t.Fatalf("Expected %s to look like a JWT", token)
+	}
+	jwks := jwt.JWKS{Keys: []jwt.JWK{jwt.NewJWK("kid", &key.PublicKey)}}
+	otherJWKS := jwt.JWKS{Keys: []jwt.JWK{jwt.NewJWK("kid", &otherKey.PublicKey)}}
+	parts := strings.Split(token, ".")
+	tamperedClaims, _ := json.Marshal(jwt.Claims{
+		Subject: "other sa id", ExpiresAt: claims.ExpiresAt,
+	})
+	tampered := strings.Join([]string{
+		parts[0], base64.RawURLEncoding.EncodeToString(tamperedClaims), parts[2],
+	}, ".")
+
+	tt := []struct {
+		name  string
+		token string
+		now   time.Time
+		jwks  jwt.JWKS
+		err   error
+	}{
+		{"valid", token, now, jwks, nil},
+		{"expired", token, now.Add(2 * time.Hour), jwks, jwt.ErrExpired},
+		{"other key", token, now, otherJWKS, jwt.ErrInvalidSignature},
+		{"unknown key", token, now, jwt.JWKS{}, jwt.ErrUnknownKey},
+		{"tampered", tampered, now, jwks, jwt.ErrInvalidSignature},
+		{"malformed", "not a jwt", now, jwks, jwt.ErrMalformed},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jwt.Parse(tt.token, tt.now, tt.jwks.Key)
+			if err != tt.err {
+				t.Fatalf("Expected error %v. Got %v", tt.err, err)
+			}
+			if err == nil && *got != claims {
+				t.Errorf("Expected claims %#v. Got %#v", claims, got)
+			}
+		})
+	}
+}
+
+func TestParseWithClaims(t *testing.T) {
+	key := generateKey(t)
+	now := time.Now()
+	token, err := jwt.Sign(jwt.Claims{
+		Subject: "some sa id", ExpiresAt: now.Add(time.Hour).Unix(),
+	}, "kid", key)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	jwks := jwt.JWKS{Keys: []jwt.JWK{jwt.NewJWK("kid", &key.PublicKey)}}
+	extra := map[string]interface{}{}
+	claims, err := jwt.ParseWithClaims(token, now, jwks.Key, &extra)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if claims.Subject != "some sa id" || extra["sub"] != "some sa id" {
+		t.Errorf("Expected sub some sa id. Got %#v and %#v", claims, extra)
+	}
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	key := generateKey(t)
+	got, err := jwt.NewJWK("kid", &key.PublicKey).PublicKey()
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if got.N.Cmp(key.N) != 0 || got.E != key.E {
+		t.Error("Expected JWK to decode to the same public key")
+	}
+}
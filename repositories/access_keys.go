@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// AccessKeys contract
+type AccessKeys interface {
+	Clone() AccessKeys
+	Create(*models.AccessKey) error
+	Deactivate(string) error
+	ForKeyID(string) (*models.AccessKey, error)
+	ForServiceAccount(string) ([]models.AccessKey, error)
+	Get(string) (*models.AccessKey, error)
+	Touch(string) error
+	setStorage(*Storage)
+}
+
+type accessKeys struct {
+	*withStorage
+}
+
+func (aks *accessKeys) Clone() AccessKeys {
+	return NewAccessKeys(aks.storage.Clone())
+}
+
+// Create stores ak, with its key secret hash only
+func (aks accessKeys) Create(ak *models.AccessKey) error {
+	_, err := aks.storage.PG.DB.Query(
+		ak, `INSERT INTO access_keys (service_account_id, label, key_id,
+		key_secret_hash, active, expires_at) VALUES (?service_account_id,
+		?label, ?key_id, ?key_secret_hash, ?active, ?expires_at)
+		RETURNING id, created_at, updated_at`, ak,
+	)
+	return err
+}
+
+// Deactivate makes an access key unusable, keeping it for reference
+func (aks accessKeys) Deactivate(id string) error {
+	_, err := aks.storage.PG.DB.Exec(
+		`UPDATE access_keys SET active = false, updated_at = now()
+		WHERE id = ?`, id,
+	)
+	return err
+}
+
+// ForKeyID retrieves the access key with keyID, along with its key
+// secret hash
+func (aks accessKeys) ForKeyID(keyID string) (*models.AccessKey, error) {
+	ak := new(models.AccessKey)
+	if _, err := aks.storage.PG.DB.Query(
+		ak, `SELECT * FROM access_keys WHERE key_id = ?`, keyID,
+	); err != nil {
+		return nil, err
+	}
+	if ak.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.AccessKey{}, keyID)
+	}
+	return ak, nil
+}
+
+// ForServiceAccount retrieves all access keys of a service account,
+// active or not
+func (aks accessKeys) ForServiceAccount(
+	saID string,
+) ([]models.AccessKey, error) {
+	akSl := []models.AccessKey{}
+	if _, err := aks.storage.PG.DB.Query(
+		&akSl, `SELECT * FROM access_keys WHERE service_account_id = ?
+		ORDER BY created_at ASC`, saID,
+	); err != nil {
+		return nil, err
+	}
+	return akSl, nil
+}
+
+// Get retrieves an access key by id
+func (aks accessKeys) Get(id string) (*models.AccessKey, error) {
+	ak := new(models.AccessKey)
+	if _, err := aks.storage.PG.DB.Query(
+		ak, `SELECT * FROM access_keys WHERE id = ?`, id,
+	); err != nil {
+		return nil, err
+	}
+	if ak.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.AccessKey{}, id)
+	}
+	return ak, nil
+}
+
+// Touch sets an access key last_used_at to now, at most once a minute
+func (aks accessKeys) Touch(id string) error {
+	_, err := aks.storage.PG.DB.Exec(
+		`UPDATE access_keys SET last_used_at = now() WHERE id = ?
+		AND (last_used_at IS NULL OR last_used_at < now() - INTERVAL '1 minute')`,
+		id,
+	)
+	return err
+}
+
+// NewAccessKeys ctor
+func NewAccessKeys(s *Storage) AccessKeys {
+	return &accessKeys{&withStorage{storage: s}}
+}
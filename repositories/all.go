@@ -8,27 +8,39 @@ import (
 
 // All holds a reference to each possible repository interface
 type All struct {
+	AccessKeys
+	AuditEvents
+	AuthorizationCodes
+	AutoApprovalRules
 	Permissions
 	PermissionsRequests
 	Roles
 	ServiceAccounts
 	Services
+	SigningKeys
 	Tokens
 	Healthcheck
+	IdPGroupRoleMappings
 	storage *Storage
 }
 
 // New All ctor
 func New(s *Storage) *All {
 	return &All{
-		Permissions:         NewPermissions(s),
-		PermissionsRequests: NewPermissionsRequests(s),
-		Roles:               NewRoles(s),
-		ServiceAccounts:     NewServiceAccounts(s),
-		Services:            NewServices(s),
-		Tokens:              NewTokens(s),
-		Healthcheck:         NewHealthcheck(s),
-		storage:             s,
+		AccessKeys:           NewAccessKeys(s),
+		AuditEvents:          NewAuditEvents(s),
+		AuthorizationCodes:   NewAuthorizationCodes(s),
+		AutoApprovalRules:    NewAutoApprovalRules(s),
+		Permissions:          NewPermissions(s),
+		PermissionsRequests:  NewPermissionsRequests(s),
+		Roles:                NewRoles(s),
+		ServiceAccounts:      NewServiceAccounts(s),
+		Services:             NewServices(s),
+		SigningKeys:          NewSigningKeys(s),
+		Tokens:               NewTokens(s),
+		Healthcheck:          NewHealthcheck(s),
+		IdPGroupRoleMappings: NewIdPGroupRoleMappings(s),
+		storage:              s,
 	}
 }
 
@@ -49,6 +61,7 @@ func (a *All) WithPGTx(ctx context.Context, fn func(repo *All) error) error {
 		return err
 	}
 	s.PG.DB = tx
+	s.inTx = true
 	c := a.cloneWithStorage(s)
 
 	defer pg.Rollback(c.storage.PG.DB)
@@ -61,19 +74,31 @@ func (a *All) WithPGTx(ctx context.Context, fn func(repo *All) error) error {
 
 func (a *All) cloneWithStorage(s *Storage) *All {
 	c := &All{
-		Permissions:         a.Permissions.Clone(),
-		PermissionsRequests: a.PermissionsRequests.Clone(),
-		Roles:               a.Roles.Clone(),
-		ServiceAccounts:     a.ServiceAccounts.Clone(),
-		Services:            a.Services.Clone(),
-		Tokens:              a.Tokens.Clone(),
-		storage:             s,
+		AccessKeys:           a.AccessKeys.Clone(),
+		AuditEvents:          a.AuditEvents.Clone(),
+		AuthorizationCodes:   a.AuthorizationCodes.Clone(),
+		AutoApprovalRules:    a.AutoApprovalRules.Clone(),
+		Permissions:          a.Permissions.Clone(),
+		PermissionsRequests:  a.PermissionsRequests.Clone(),
+		Roles:                a.Roles.Clone(),
+		ServiceAccounts:      a.ServiceAccounts.Clone(),
+		Services:             a.Services.Clone(),
+		SigningKeys:          a.SigningKeys.Clone(),
+		Tokens:               a.Tokens.Clone(),
+		IdPGroupRoleMappings: a.IdPGroupRoleMappings.Clone(),
+		storage:              s,
 	}
+	c.AccessKeys.setStorage(s)
+	c.AuditEvents.setStorage(s)
+	c.AuthorizationCodes.setStorage(s)
+	c.AutoApprovalRules.setStorage(s)
 	c.Permissions.setStorage(s)
 	c.PermissionsRequests.setStorage(s)
 	c.Roles.setStorage(s)
 	c.ServiceAccounts.setStorage(s)
 	c.Services.setStorage(s)
+	c.SigningKeys.setStorage(s)
 	c.Tokens.setStorage(s)
+	c.IdPGroupRoleMappings.setStorage(s)
 	return c
 }
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/gofrs/uuid"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// AuditEventsFilter narrows down audit events; zero fields don't filter
+type AuditEventsFilter struct {
+	ActorServiceAccountID string
+	Action                string
+	TargetType            string
+	TargetID              string
+	Since                 time.Time
+	Until                 time.Time
+}
+
+// params are the query params ?0 to ?5 of auditEventsWhere
+func (f AuditEventsFilter) params() []interface{} {
+	return []interface{}{
+		f.ActorServiceAccountID, f.Action, f.TargetType, f.TargetID,
+		pg.NullTime{Time: f.Since}, pg.NullTime{Time: f.Until},
+	}
+}
+
+const auditEventsWhere = `WHERE
+	(?0 = '' OR actor_service_account_id = NULLIF(?0, '')::uuid)
+	AND (?1 = '' OR action = ?1)
+	AND (?2 = '' OR target_type = ?2)
+	AND (?3 = '' OR target_id = ?3)
+	AND (?4 IS NULL OR created_at >= ?4)
+	AND (?5 IS NULL OR created_at < ?5)`
+
+// AuditEvents contract
+type AuditEvents interface {
+	Create(*models.AuditEvent) error
+	List(*AuditEventsFilter, *ListOptions) ([]models.AuditEvent, error)
+	ListChain(int64, int) ([]models.AuditEvent, error)
+	ListCount(*AuditEventsFilter) (int64, error)
+	Clone() AuditEvents
+	setStorage(*Storage)
+}
+
+type auditEvents struct {
+	*withStorage
+}
+
+func (aes *auditEvents) Clone() AuditEvents {
+	return NewAuditEvents(aes.storage.Clone())
+}
+
+// Create seals ae after the last chained audit event and appends it
+// It must run in a transaction: chaining is serialized by a lock held
+// until it ends
+func (aes auditEvents) Create(ae *models.AuditEvent) error {
+	if _, err := aes.storage.PG.DB.Exec(
+		`SELECT pg_advisory_xact_lock(hashtext('audit_events'))`,
+	); err != nil {
+		return err
+	}
+	var previousHash string
+	if _, err := aes.storage.PG.DB.Query(
+		pg.Scan(&previousHash), `SELECT COALESCE((SELECT hash FROM audit_events
+	WHERE hash IS NOT NULL ORDER BY seq DESC LIMIT 1), '')`,
+	); err != nil {
+		return err
+	}
+	ae.ID = uuid.Must(uuid.NewV4()).String()
+	// Postgres keeps microseconds, hashes must match what is read back
+	ae.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	ae.Seal(previousHash)
+	_, err := aes.storage.PG.DB.Query(
+		ae, `INSERT INTO audit_events (id, actor_service_account_id, action,
+	target_type, target_id, before, after, request_id, created_at,
+	previous_hash, hash)
+	VALUES (?id, ?actor_service_account_id, ?action, ?target_type, ?target_id,
+	?before, ?after, ?request_id, ?created_at, ?previous_hash, ?hash)
+	RETURNING seq`, ae,
+	)
+	return err
+}
+
+// ListChain lists up to limit audit events after afterSeq, in chain order
+func (aes auditEvents) ListChain(
+	afterSeq int64, limit int,
+) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	if _, err := aes.storage.PG.DB.Query(
+		&events, `SELECT * FROM audit_events WHERE seq > ?
+	ORDER BY seq ASC LIMIT ?`, afterSeq, limit,
+	); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// List audit events matching f, newest first
+func (aes auditEvents) List(
+	f *AuditEventsFilter, lo *ListOptions,
+) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	params := append(f.params(), lo.Limit(), lo.Offset())
+	if _, err := aes.storage.PG.DB.Query(
+		&events, `SELECT * FROM audit_events `+auditEventsWhere+`
+	ORDER BY seq DESC LIMIT ?6 OFFSET ?7`, params...,
+	); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ListCount counts audit events matching f
+func (aes auditEvents) ListCount(f *AuditEventsFilter) (int64, error) {
+	var count int64
+	if _, err := aes.storage.PG.DB.Query(
+		&count, `SELECT count(*) FROM audit_events `+auditEventsWhere,
+		f.params()...,
+	); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// NewAuditEvents ctor
+func NewAuditEvents(s *Storage) AuditEvents {
+	return &auditEvents{&withStorage{storage: s}}
+}
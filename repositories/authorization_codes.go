@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// AuthorizationCodes contract
+type AuthorizationCodes interface {
+	Clone() AuthorizationCodes
+	Create(*models.AuthorizationCode) error
+	DeleteExpired() (int, error)
+	Redeem(string) (*models.AuthorizationCode, error)
+	setStorage(*Storage)
+}
+
+type authorizationCodes struct {
+	*withStorage
+}
+
+func (acs *authorizationCodes) Clone() AuthorizationCodes {
+	return NewAuthorizationCodes(acs.storage.Clone())
+}
+
+// Create stores ac, with its code hash only
+func (acs authorizationCodes) Create(ac *models.AuthorizationCode) error {
+	_, err := acs.storage.PG.DB.Query(
+		ac, `INSERT INTO oauth2_authorization_codes (code_hash, client_id,
+		service_account_id, redirect_uri, code_challenge, expires_at)
+		VALUES (?code_hash, ?client_id, ?service_account_id, ?redirect_uri,
+		?code_challenge, ?expires_at) RETURNING id, created_at, updated_at`, ac,
+	)
+	return err
+}
+
+// Redeem deletes and returns the authorization code of codeHash, so it can
+// only be redeemed once, expired or not
+func (acs authorizationCodes) Redeem(
+	codeHash string,
+) (*models.AuthorizationCode, error) {
+	ac := new(models.AuthorizationCode)
+	if _, err := acs.storage.PG.DB.Query(
+		ac, `DELETE FROM oauth2_authorization_codes WHERE code_hash = ?
+		RETURNING *`, codeHash,
+	); err != nil {
+		return nil, err
+	}
+	if ac.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.AuthorizationCode{}, codeHash)
+	}
+	return ac, nil
+}
+
+// DeleteExpired removes authorization codes no longer redeemable, returning
+// how many
+func (acs authorizationCodes) DeleteExpired() (int, error) {
+	res, err := acs.storage.PG.DB.Exec(
+		`DELETE FROM oauth2_authorization_codes WHERE expires_at <= now()`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+// NewAuthorizationCodes ctor
+func NewAuthorizationCodes(s *Storage) AuthorizationCodes {
+	return &authorizationCodes{&withStorage{storage: s}}
+}
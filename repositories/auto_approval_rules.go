@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// AutoApprovalRules contract
+type AutoApprovalRules interface {
+	Clone() AutoApprovalRules
+	Create(*models.AutoApprovalRule) error
+	Delete(string) error
+	Get(string) (*models.AutoApprovalRule, error)
+	List() ([]models.AutoApprovalRule, error)
+	setStorage(*Storage)
+}
+
+type autoApprovalRules struct {
+	*withStorage
+}
+
+func (rs *autoApprovalRules) Clone() AutoApprovalRules {
+	return NewAutoApprovalRules(rs.storage.Clone())
+}
+
+// Create stores r
+func (rs autoApprovalRules) Create(r *models.AutoApprovalRule) error {
+	_, err := rs.storage.PG.DB.Query(
+		r, `INSERT INTO auto_approval_rules (permission, hosted_domain, role_id,
+		duration, creator_service_account_id)
+		VALUES (?permission, ?hosted_domain, ?role_id, ?duration,
+		?creator_service_account_id)
+		RETURNING id, created_at, updated_at`, r,
+	)
+	return err
+}
+
+// Delete removes rule id
+func (rs autoApprovalRules) Delete(id string) error {
+	_, err := rs.storage.PG.DB.Exec(
+		`DELETE FROM auto_approval_rules WHERE id = ?`, id,
+	)
+	return err
+}
+
+// Get retrieves rule id
+func (rs autoApprovalRules) Get(id string) (*models.AutoApprovalRule, error) {
+	r := new(models.AutoApprovalRule)
+	if _, err := rs.storage.PG.DB.Query(
+		r, `SELECT * FROM auto_approval_rules WHERE id = ?`, id,
+	); err != nil {
+		return nil, err
+	}
+	if r.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.AutoApprovalRule{}, id)
+	}
+	return r, nil
+}
+
+// List retrieves every rule, oldest first
+func (rs autoApprovalRules) List() ([]models.AutoApprovalRule, error) {
+	rSl := []models.AutoApprovalRule{}
+	if _, err := rs.storage.PG.DB.Query(
+		&rSl, `SELECT * FROM auto_approval_rules ORDER BY created_at`,
+	); err != nil {
+		return nil, err
+	}
+	return rSl, nil
+}
+
+// NewAutoApprovalRules ctor
+func NewAutoApprovalRules(s *Storage) AutoApprovalRules {
+	return &autoApprovalRules{&withStorage{storage: s}}
+}
@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// CacheInvalidationChannel is the Postgres channel notified, with the
+// changed table name as payload, whenever cached data is written
+const CacheInvalidationChannel = "will_iam_cache_invalidation"
+
+// Cache keeps each service account effective permissions and each access
+// token in memory for up to a TTL, or until a CacheInvalidationChannel
+// notification drops them
+type Cache struct {
+	ttl         time.Duration
+	mutex       sync.RWMutex
+	permissions map[string]cachedPermissions
+	tokens      map[string]cachedToken
+}
+
+type cachedPermissions struct {
+	permissions []models.Permission
+	storedAt    time.Time
+}
+
+type cachedToken struct {
+	token    models.Token
+	storedAt time.Time
+}
+
+// NewCache ctor
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:         ttl,
+		permissions: map[string]cachedPermissions{},
+		tokens:      map[string]cachedToken{},
+	}
+}
+
+func (c *Cache) fresh(storedAt time.Time) bool {
+	return time.Since(storedAt) < c.ttl
+}
+
+// GetPermissions returns a copy of saID cached permissions, if any
+func (c *Cache) GetPermissions(saID string) ([]models.Permission, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	cp, ok := c.permissions[saID]
+	if !ok || !c.fresh(cp.storedAt) {
+		return nil, false
+	}
+	ps := make([]models.Permission, len(cp.permissions))
+	copy(ps, cp.permissions)
+	return ps, true
+}
+
+// SetPermissions caches a copy of saID permissions
+func (c *Cache) SetPermissions(saID string, permissions []models.Permission) {
+	ps := make([]models.Permission, len(permissions))
+	copy(ps, permissions)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.permissions[saID] = cachedPermissions{ps, time.Now()}
+}
+
+// GetToken returns a copy of the cached token for accessToken, if any
+func (c *Cache) GetToken(accessToken string) (*models.Token, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	ct, ok := c.tokens[accessToken]
+	if !ok || !c.fresh(ct.storedAt) {
+		return nil, false
+	}
+	return ct.token.Clone(), true
+}
+
+// SetToken caches a copy of token
+func (c *Cache) SetToken(token *models.Token) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokens[token.AccessToken] = cachedToken{*token, time.Now()}
+}
+
+// FlushPermissions drops all cached permissions
+func (c *Cache) FlushPermissions() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.permissions = map[string]cachedPermissions{}
+}
+
+// FlushTokens drops all cached tokens
+func (c *Cache) FlushTokens() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokens = map[string]cachedToken{}
+}
+
+// Flush drops everything cached
+func (c *Cache) Flush() {
+	c.FlushPermissions()
+	c.FlushTokens()
+}
+
+// Invalidate drops what a write to table may have changed
+func (c *Cache) Invalidate(table string) {
+	if table == "tokens" {
+		c.FlushTokens()
+		return
+	}
+	c.FlushPermissions()
+}
+
+// Listen invalidates c on every CacheInvalidationChannel notification
+// until ctx is done. Since notifications sent while the connection is down
+// are lost, everything is flushed whenever receiving fails
+func (c *Cache) Listen(
+	ctx context.Context, s *Storage, logger logrus.FieldLogger,
+) {
+	ln := s.PG.DB.WithContext(ctx).Listen(CacheInvalidationChannel)
+	defer ln.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		_, table, err := ln.ReceiveTimeout(c.ttl)
+		if err == nil {
+			c.Invalidate(table)
+			continue
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			continue
+		}
+		logger.WithError(err).Error("cache invalidation listener failed")
+		c.Flush()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func loadDefaultConfigCache(config *viper.Viper) {
+	config.SetDefault("cache.enabled", false)
+	config.SetDefault("cache.ttl", "30s")
+}
+
+// ConfigureCache sets s.Cache when cache.enabled is set
+func (s *Storage) ConfigureCache(config *viper.Viper) {
+	loadDefaultConfigCache(config)
+	if !config.GetBool("cache.enabled") {
+		return
+	}
+	s.Cache = NewCache(config.GetDuration("cache.ttl"))
+}
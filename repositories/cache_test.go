@@ -0,0 +1,70 @@
+// +build unit
+
+package repositories_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+func TestCachePermissions(t *testing.T) {
+	c := repositories.NewCache(time.Minute)
+	if _, ok := c.GetPermissions("sa"); ok {
+		t.Fatal("Expected empty cache to miss")
+	}
+	p, err := models.BuildPermission("Maestro::RL::ListSchedulers::*")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	c.SetPermissions("sa", []models.Permission{p})
+	ps, ok := c.GetPermissions("sa")
+	if !ok || len(ps) != 1 {
+		t.Fatalf("Expected 1 cached permission. Got %v", ps)
+	}
+	ps[0].Action = "Changed"
+	ps, _ = c.GetPermissions("sa")
+	if ps[0].Action != p.Action {
+		t.Errorf("Expected cached permission to be a copy. Got %s", ps[0].Action)
+	}
+	c.Invalidate("tokens")
+	if _, ok := c.GetPermissions("sa"); !ok {
+		t.Error("Expected tokens invalidation to keep permissions")
+	}
+	c.Invalidate("role_bindings")
+	if _, ok := c.GetPermissions("sa"); ok {
+		t.Error("Expected role_bindings invalidation to drop permissions")
+	}
+}
+
+func TestCacheTokens(t *testing.T) {
+	c := repositories.NewCache(time.Minute)
+	c.SetToken(&models.Token{AccessToken: "at", Email: "a@b.com"})
+	tk, ok := c.GetToken("at")
+	if !ok || tk.Email != "a@b.com" {
+		t.Fatalf("Expected cached token. Got %v", tk)
+	}
+	tk.Email = "changed"
+	if tk, _ = c.GetToken("at"); tk.Email != "a@b.com" {
+		t.Errorf("Expected cached token to be a copy. Got %s", tk.Email)
+	}
+	c.Invalidate("permissions")
+	if _, ok := c.GetToken("at"); !ok {
+		t.Error("Expected permissions invalidation to keep tokens")
+	}
+	c.Invalidate("tokens")
+	if _, ok := c.GetToken("at"); ok {
+		t.Error("Expected tokens invalidation to drop tokens")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := repositories.NewCache(time.Millisecond)
+	c.SetToken(&models.Token{AccessToken: "at"})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := c.GetToken("at"); ok {
+		t.Error("Expected token past TTL to miss")
+	}
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// IdPGroupRoleMappings contract
+type IdPGroupRoleMappings interface {
+	Clone() IdPGroupRoleMappings
+	Create(*models.IdPGroupRoleMapping) error
+	Delete(string) error
+	ForProvider(string) ([]models.IdPGroupRoleMapping, error)
+	ForRole(string) ([]models.IdPGroupRoleMapping, error)
+	Get(string) (*models.IdPGroupRoleMapping, error)
+	setStorage(*Storage)
+}
+
+type idpGroupRoleMappings struct {
+	*withStorage
+}
+
+func (ms *idpGroupRoleMappings) Clone() IdPGroupRoleMappings {
+	return NewIdPGroupRoleMappings(ms.storage.Clone())
+}
+
+// Create stores m; mapping the same group of a provider to a role twice,
+// in whatever case, is a conflict
+func (ms idpGroupRoleMappings) Create(m *models.IdPGroupRoleMapping) error {
+	_, err := ms.storage.PG.DB.Query(
+		m, `INSERT INTO idp_group_role_mappings (provider, group_name, role_id)
+		VALUES (?provider, ?group_name, ?role_id)
+		RETURNING id, created_at, updated_at`, m,
+	)
+	return err
+}
+
+// Delete removes mapping id
+func (ms idpGroupRoleMappings) Delete(id string) error {
+	_, err := ms.storage.PG.DB.Exec(
+		`DELETE FROM idp_group_role_mappings WHERE id = ?`, id,
+	)
+	return err
+}
+
+// ForProvider retrieves the mappings of the groups of provider
+func (ms idpGroupRoleMappings) ForProvider(
+	provider string,
+) ([]models.IdPGroupRoleMapping, error) {
+	mSl := []models.IdPGroupRoleMapping{}
+	if _, err := ms.storage.PG.DB.Query(
+		&mSl, `SELECT * FROM idp_group_role_mappings WHERE provider = ?`,
+		provider,
+	); err != nil {
+		return nil, err
+	}
+	return mSl, nil
+}
+
+// ForRole retrieves the mappings to roleID
+func (ms idpGroupRoleMappings) ForRole(
+	roleID string,
+) ([]models.IdPGroupRoleMapping, error) {
+	mSl := []models.IdPGroupRoleMapping{}
+	if _, err := ms.storage.PG.DB.Query(
+		&mSl, `SELECT * FROM idp_group_role_mappings WHERE role_id = ?
+		ORDER BY provider, group_name`, roleID,
+	); err != nil {
+		return nil, err
+	}
+	return mSl, nil
+}
+
+// Get retrieves mapping id
+func (ms idpGroupRoleMappings) Get(
+	id string,
+) (*models.IdPGroupRoleMapping, error) {
+	m := new(models.IdPGroupRoleMapping)
+	if _, err := ms.storage.PG.DB.Query(
+		m, `SELECT * FROM idp_group_role_mappings WHERE id = ?`, id,
+	); err != nil {
+		return nil, err
+	}
+	if m.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.IdPGroupRoleMapping{}, id)
+	}
+	return m, nil
+}
+
+// NewIdPGroupRoleMappings ctor
+func NewIdPGroupRoleMappings(s *Storage) IdPGroupRoleMappings {
+	return &idpGroupRoleMappings{&withStorage{storage: s}}
+}
@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"github.com/go-pg/pg"
+)
+
+// LeaderLock elects a single leader among processes sharing a database
+// through a Postgres session advisory lock named Name. Session locks live as
+// long as the connection that took them, so LeaderLock keeps a connection of
+// its own instead of using pooled ones
+type LeaderLock struct {
+	Name string
+	db   *pg.DB
+	pid  int
+}
+
+// NewLeaderLock ctor; s PG must be configured
+func NewLeaderLock(s *Storage, name string) *LeaderLock {
+	opts := *s.PG.Options
+	opts.PoolSize = 1
+	opts.IdleTimeout = -1
+	opts.MaxConnAge = 0
+	return &LeaderLock{Name: name, db: pg.Connect(&opts)}
+}
+
+// Elect tries to take the lock, unless it's already held, and tells if it's
+// held. When the connection holding it was lost, Postgres released it along,
+// so it's taken again if no one else did in the meantime
+func (l *LeaderLock) Elect() (bool, error) {
+	if l.pid != 0 {
+		var pid int
+		_, err := l.db.QueryOne(pg.Scan(&pid), `SELECT pg_backend_pid()`)
+		if err == nil && pid == l.pid {
+			return true, nil
+		}
+		l.pid = 0
+		if err != nil {
+			return false, err
+		}
+	}
+	var acquired bool
+	var pid int
+	if _, err := l.db.QueryOne(
+		pg.Scan(&acquired, &pid),
+		`SELECT pg_try_advisory_lock(hashtext(?)), pg_backend_pid()`, l.Name,
+	); err != nil {
+		return false, err
+	}
+	if acquired {
+		l.pid = pid
+	}
+	return acquired, nil
+}
+
+// Resign releases the lock, if held, and closes l connection
+func (l *LeaderLock) Resign() error {
+	if l.pid != 0 {
+		l.pid = 0
+		if _, err := l.db.Exec(
+			`SELECT pg_advisory_unlock(hashtext(?))`, l.Name,
+		); err != nil {
+			l.db.Close()
+			return err
+		}
+	}
+	return l.db.Close()
+}
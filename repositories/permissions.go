@@ -1,6 +1,9 @@
 package repositories
 
 import (
+	"strings"
+
+	"github.com/go-pg/pg"
 	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 )
@@ -9,9 +12,11 @@ import (
 type Permissions interface {
 	Get(string) (*models.Permission, error)
 	ForServiceAccount(string) ([]models.Permission, error)
+	GrantsForServiceAccount(string, string) ([]models.PermissionGrant, error)
 	ForRole(string) ([]models.Permission, error)
 	Create(*models.Permission) error
 	Delete(string) error
+	DeleteExpired() ([]models.Permission, error)
 	Clone() Permissions
 	setStorage(*Storage)
 }
@@ -29,7 +34,7 @@ func (ps *permissions) Get(id string) (*models.Permission, error) {
 	p := new(models.Permission)
 	if info, err := ps.storage.PG.DB.Query(
 		p, `SELECT id, role_id, service, ownership_level,
-action, resource_hierarchy, alias FROM permissions
+action, resource_hierarchy, alias, not_before, expires_at FROM permissions
 	WHERE id = ?`, id,
 	); err != nil {
 		return nil, err
@@ -39,27 +44,60 @@ action, resource_hierarchy, alias FROM permissions
 	return p, nil
 }
 
+// ForServiceAccount retrieves all permissions in effect for a service account
+// Permissions or role bindings out of their time bounds are left out
+// When storage has a Cache, out of transactions, results are served from
+// it while fresh
 func (ps *permissions) ForServiceAccount(
 	saID string,
 ) ([]models.Permission, error) {
+	cache := ps.storage.cache()
+	if cache != nil {
+		if permissions, ok := cache.GetPermissions(saID); ok {
+			return permissions, nil
+		}
+	}
 	permissions := []models.Permission{}
 	if _, err := ps.storage.PG.DB.Query(
-		&permissions, `SELECT p.id, p.role_id, p.service, p.ownership_level,
-p.action, p.resource_hierarchy, p.alias FROM permissions p
-	JOIN role_bindings rb ON rb.role_id = p.role_id
-	WHERE rb.service_account_id = ?
-	ORDER BY p.service, p.ownership_level, p.action, p.resource_hierarchy`, saID,
+		&permissions, `SELECT id, role_id, service, ownership_level,
+action, resource_hierarchy, alias, not_before, expires_at
+	FROM service_account_permissions(?)
+	ORDER BY service, ownership_level, action, resource_hierarchy`, saID,
 	); err != nil {
 		return nil, err
 	}
+	if cache != nil {
+		cache.SetPermissions(saID, permissions)
+	}
 	return permissions, nil
 }
 
+// GrantsForServiceAccount retrieves all permissions in effect for a service
+// account over service, with the names of the roles they come from
+func (ps *permissions) GrantsForServiceAccount(
+	saID, service string,
+) ([]models.PermissionGrant, error) {
+	grants := []models.PermissionGrant{}
+	if _, err := ps.storage.PG.DB.Query(
+		&grants, `SELECT sap.id, sap.role_id, r.name AS role_name, sap.service,
+sap.ownership_level, sap.action, sap.resource_hierarchy, sap.alias,
+sap.not_before, sap.expires_at
+	FROM service_account_permissions(?0) sap
+	JOIN roles r ON r.id = sap.role_id
+	WHERE sap.service = ?1 OR sap.service = '*' OR ?1 = '*'
+	ORDER BY sap.service, sap.ownership_level, sap.action, sap.resource_hierarchy`,
+		saID, service,
+	); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
 func (ps *permissions) ForRole(roleID string) ([]models.Permission, error) {
 	permissions := []models.Permission{}
 	if _, err := ps.storage.PG.DB.Query(
 		&permissions, `SELECT id, role_id, service, ownership_level,
-action, resource_hierarchy, alias FROM permissions
+action, resource_hierarchy, alias, not_before, expires_at FROM permissions
 	WHERE role_id = ?
 	ORDER BY service, ownership_level, action, resource_hierarchy`, roleID,
 	); err != nil {
@@ -68,12 +106,28 @@ action, resource_hierarchy, alias FROM permissions
 	return permissions, nil
 }
 
+// Create a permission; if the role already has it, its time bounds are
+// widened to cover both periods, or replaced if the existing one expired
 func (ps *permissions) Create(p *models.Permission) error {
-	_, err := ps.storage.PG.DB.Exec(
+	_, err := ps.storage.PG.DB.Query(pg.Scan(&p.ID),
 		`INSERT INTO permissions (role_id, service, ownership_level, action,
-		resource_hierarchy, alias) VALUES (?, ?, ?, ?, ?, ?)
-		ON CONFLICT DO NOTHING RETURNING id`, p.RoleID, p.Service, p.OwnershipLevel,
-		p.Action, p.ResourceHierarchy, p.Alias,
+		resource_hierarchy, alias, not_before, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (role_id, ownership_level, action, service, resource_hierarchy)
+		DO UPDATE SET
+		not_before = CASE
+			WHEN permissions.expires_at <= now() THEN EXCLUDED.not_before
+			WHEN permissions.not_before IS NULL OR EXCLUDED.not_before IS NULL THEN NULL
+			ELSE LEAST(permissions.not_before, EXCLUDED.not_before)
+		END,
+		expires_at = CASE
+			WHEN permissions.expires_at <= now() THEN EXCLUDED.expires_at
+			WHEN permissions.expires_at IS NULL OR EXCLUDED.expires_at IS NULL THEN NULL
+			ELSE GREATEST(permissions.expires_at, EXCLUDED.expires_at)
+		END,
+		updated_at = now()
+		RETURNING id`, p.RoleID, p.Service, p.OwnershipLevel, p.Action,
+		p.ResourceHierarchy, p.Alias, p.NotBefore, p.ExpiresAt,
 	)
 	return err
 }
@@ -85,6 +139,31 @@ func (ps *permissions) Delete(id string) error {
 	return err
 }
 
+// DeleteExpired removes permissions whose time bound ended, returning them
+func (ps *permissions) DeleteExpired() ([]models.Permission, error) {
+	pSl := []models.Permission{}
+	if _, err := ps.storage.PG.DB.Query(
+		&pSl, `DELETE FROM permissions WHERE expires_at <= now()
+		RETURNING id, role_id, service, ownership_level, action,
+		resource_hierarchy, alias, not_before, expires_at`,
+	); err != nil {
+		return nil, err
+	}
+	return pSl, nil
+}
+
+// containedResourceHierarchiesPattern builds a LIKE pattern that matches rh
+// and, if rh is open, every resource hierarchy under it
+// Eg: "x::*" => "x::%"
+func containedResourceHierarchiesPattern(rh models.ResourceHierarchy) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).
+		Replace(rh.String())
+	if strings.HasSuffix(escaped, "*") {
+		return strings.TrimSuffix(escaped, "*") + "%"
+	}
+	return escaped
+}
+
 // NewPermissions users ctor
 func NewPermissions(s *Storage) Permissions {
 	return &permissions{&withStorage{storage: s}}
@@ -1,16 +1,30 @@
 package repositories
 
-import "github.com/topfreegames/Will.IAM/models"
+import (
+	"time"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+)
 
 // PermissionsRequests repository
 type PermissionsRequests interface {
+	Approvals(string) ([]models.PermissionRequestApproval, error)
+	Approve(*models.PermissionRequestApproval) error
+	Cancel(string) error
 	Clone() PermissionsRequests
 	Create(*models.PermissionRequest) error
+	DeleteOpenForServiceAccount(string) error
 	Deny(string, string) error
+	ExpireOpen(time.Time) ([]models.PermissionRequest, error)
 	Get(string) (*models.PermissionRequest, error)
-	Grant(string, string) error
+	GetForUpdate(string) (*models.PermissionRequest, error)
+	Grant(string, string, models.Duration) error
 	ListOpenRequestsVisibleTo(*ListOptions, string) ([]models.PermissionRequest, error)
 	ListOpenRequestsVisibleToCount(string) (int64, error)
+	ListForServiceAccount(*ListOptions, string) ([]models.PermissionRequest, error)
+	ListForServiceAccountCount(string) (int64, error)
+	OpenCount() (int64, error)
 	setStorage(*Storage)
 }
 
@@ -25,36 +39,126 @@ func (prs *permissionsRequests) Clone() PermissionsRequests {
 func (prs *permissionsRequests) Create(pr *models.PermissionRequest) error {
 	_, err := prs.storage.PG.DB.Query(
 		pr, `INSERT INTO permissions_requests (service, ownership_level, action, resource_hierarchy,
-    alias, message, state, service_account_id) VALUES (?service, ?ownership_level, ?action,
-    ?resource_hierarchy, ?alias, ?message, ?state, ?service_account_id)
+    alias, message, state, service_account_id, duration) VALUES (?service, ?ownership_level, ?action,
+    ?resource_hierarchy, ?alias, ?message, ?state, ?service_account_id, ?duration)
     ON CONFLICT (service, ownership_level, action, resource_hierarchy, service_account_id)
     WHERE state = 'open' DO NOTHING RETURNING id`, pr,
 	)
 	return err
 }
 
+// Approvals lists approvals of prID, oldest first
+func (prs *permissionsRequests) Approvals(
+	prID string,
+) ([]models.PermissionRequestApproval, error) {
+	as := []models.PermissionRequestApproval{}
+	if _, err := prs.storage.PG.DB.Query(
+		&as, `SELECT * FROM permissions_requests_approvals
+    WHERE permission_request_id = ? ORDER BY created_at ASC`, prID,
+	); err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+// Approve records a; an owner approving the same request again keeps their
+// first approval
+func (prs *permissionsRequests) Approve(
+	a *models.PermissionRequestApproval,
+) error {
+	_, err := prs.storage.PG.DB.Query(
+		a, `INSERT INTO permissions_requests_approvals (permission_request_id,
+    service_account_id, duration) VALUES (?permission_request_id,
+    ?service_account_id, ?duration)
+    ON CONFLICT (permission_request_id, service_account_id) DO NOTHING
+    RETURNING id`, a,
+	)
+	return err
+}
+
+// Cancel closes prID on behalf of its requester, if it's still open
+func (prs *permissionsRequests) Cancel(prID string) error {
+	_, err := prs.storage.PG.DB.Exec(
+		`UPDATE permissions_requests SET state = ?, updated_at = now()
+    WHERE id = ? AND state = ?`, models.PermissionRequestStates.Cancelled, prID,
+		models.PermissionRequestStates.Open,
+	)
+	return err
+}
+
+// DeleteOpenForServiceAccount removes open requests made by saID
+func (prs *permissionsRequests) DeleteOpenForServiceAccount(saID string) error {
+	_, err := prs.storage.PG.DB.Exec(
+		`DELETE FROM permissions_requests WHERE service_account_id = ? AND state = ?`,
+		saID, models.PermissionRequestStates.Open,
+	)
+	return err
+}
+
+// Deny closes prID on behalf of moderator saID, if it's still open
 func (prs *permissionsRequests) Deny(saID, prID string) error {
 	_, err := prs.storage.PG.DB.Exec(
 		`UPDATE permissions_requests SET state = ?, moderator_service_account_id = ?, updated_at = now()
-    WHERE id = ?`, models.PermissionRequestStates.Denied, saID, prID,
+    WHERE id = ? AND state = ?`, models.PermissionRequestStates.Denied, saID, prID,
+		models.PermissionRequestStates.Open,
 	)
 	return err
 }
 
-func (prs *permissionsRequests) Get(prID string) (*models.PermissionRequest, error) {
-	var pr models.PermissionRequest
+// ExpireOpen expires requests still open that were made before
+// createdBefore, returning them
+func (prs *permissionsRequests) ExpireOpen(
+	createdBefore time.Time,
+) ([]models.PermissionRequest, error) {
+	prSl := []models.PermissionRequest{}
 	if _, err := prs.storage.PG.DB.Query(
-		&pr, ` SELECT * FROM permissions_requests WHERE id = ? `, prID,
+		&prSl, `UPDATE permissions_requests SET state = ?, updated_at = now()
+    WHERE state = ? AND created_at < ? RETURNING *`,
+		models.PermissionRequestStates.Expired,
+		models.PermissionRequestStates.Open, createdBefore,
 	); err != nil {
 		return nil, err
 	}
+	return prSl, nil
+}
+
+func (prs *permissionsRequests) Get(prID string) (*models.PermissionRequest, error) {
+	return prs.get(` SELECT * FROM permissions_requests WHERE id = ? `, prID)
+}
+
+// GetForUpdate gets prID, locking it until the transaction ends so
+// concurrent approvals, cancellations and denials of it happen one after
+// the other
+func (prs *permissionsRequests) GetForUpdate(
+	prID string,
+) (*models.PermissionRequest, error) {
+	return prs.get(
+		`SELECT * FROM permissions_requests WHERE id = ? FOR UPDATE`, prID,
+	)
+}
+
+func (prs *permissionsRequests) get(
+	query, prID string,
+) (*models.PermissionRequest, error) {
+	var pr models.PermissionRequest
+	if _, err := prs.storage.PG.DB.Query(&pr, query, prID); err != nil {
+		return nil, err
+	}
+	if pr.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.PermissionRequest{}, prID)
+	}
 	return &pr, nil
 }
 
-func (prs *permissionsRequests) Grant(saID, prID string) error {
+// Grant closes prID as granted by saID for duration, zero if it doesn't
+// expire
+func (prs *permissionsRequests) Grant(
+	saID, prID string, duration models.Duration,
+) error {
 	_, err := prs.storage.PG.DB.Exec(
-		`UPDATE permissions_requests SET state = ?, moderator_service_account_id = ?, updated_at = now()
-    WHERE id = ?`, models.PermissionRequestStates.Granted, saID, prID,
+		`UPDATE permissions_requests SET state = ?, moderator_service_account_id = ?, duration = ?,
+    updated_at = now() WHERE id = ?`,
+		models.PermissionRequestStates.Granted, saID, duration, prID,
 	)
 	return err
 }
@@ -70,11 +174,12 @@ func (prs *permissionsRequests) ListOpenRequestsVisibleTo(
 		&prSl, `
     SELECT DISTINCT pr.id, pr.service, pr.ownership_level, pr.action, pr.resource_hierarchy,
     pr.service_account_id, sas.picture AS requester_picture, sas.name AS requester_name, pr.state,
-    pr.message, pr.alias
+    pr.message, pr.alias, pr.duration,
+    (SELECT count(*) FROM permissions_requests_approvals pra
+        WHERE pra.permission_request_id = pr.id) AS approvals
     FROM permissions_requests pr
-    CROSS JOIN (SELECT service, action, resource_hierarchy FROM permissions
-        WHERE role_id = ANY (SELECT role_id FROM role_bindings WHERE service_account_id = ?)
-        AND ownership_level = 'RO') saop
+    CROSS JOIN (SELECT service, action, resource_hierarchy FROM service_account_permissions(?)
+        WHERE ownership_level = 'RO') saop
     INNER JOIN service_accounts sas ON sas.id = pr.service_account_id
     WHERE state = 'open'
       AND CASE WHEN saop.service = '*' THEN true ELSE pr.service = saop.service END
@@ -98,9 +203,8 @@ func (prs *permissionsRequests) ListOpenRequestsVisibleToCount(
 	if _, err := prs.storage.PG.DB.Query(
 		&count, `
     SELECT COUNT(DISTINCT pr.id) FROM permissions_requests pr
-    CROSS JOIN (SELECT service, action, resource_hierarchy FROM permissions
-        WHERE role_id = ANY (SELECT role_id FROM role_bindings WHERE service_account_id = ?)
-        AND ownership_level = 'RO') saop
+    CROSS JOIN (SELECT service, action, resource_hierarchy FROM service_account_permissions(?)
+        WHERE ownership_level = 'RO') saop
     WHERE state = 'open'
       AND CASE WHEN saop.service = '*' THEN true ELSE pr.service = saop.service END
       AND CASE WHEN saop.action = '*' THEN true ELSE pr.action = saop.action END
@@ -115,6 +219,47 @@ func (prs *permissionsRequests) ListOpenRequestsVisibleToCount(
 	return count, nil
 }
 
+// ListForServiceAccount lists requests made by saID, in any state, newest
+// first
+func (prs *permissionsRequests) ListForServiceAccount(
+	lo *ListOptions, saID string,
+) ([]models.PermissionRequest, error) {
+	prSl := []models.PermissionRequest{}
+	if _, err := prs.storage.PG.DB.Query(
+		&prSl, `SELECT * FROM permissions_requests WHERE service_account_id = ?
+    ORDER BY created_at DESC LIMIT ? OFFSET ?`, saID, lo.Limit(), lo.Offset(),
+	); err != nil {
+		return nil, err
+	}
+	return prSl, nil
+}
+
+// ListForServiceAccountCount counts requests made by saID
+func (prs *permissionsRequests) ListForServiceAccountCount(
+	saID string,
+) (int64, error) {
+	var count int64
+	if _, err := prs.storage.PG.DB.Query(
+		&count, `SELECT count(*) FROM permissions_requests
+    WHERE service_account_id = ?`, saID,
+	); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// OpenCount counts requests still open
+func (prs *permissionsRequests) OpenCount() (int64, error) {
+	var count int64
+	if _, err := prs.storage.PG.DB.Query(
+		&count, `SELECT count(*) FROM permissions_requests WHERE state = ?`,
+		models.PermissionRequestStates.Open,
+	); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 // NewPermissionsRequests users ctor
 func NewPermissionsRequests(s *Storage) PermissionsRequests {
 	return &permissionsRequests{&withStorage{storage: s}}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"fmt"
 
+	"github.com/go-pg/pg"
 	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 )
@@ -10,17 +11,29 @@ import (
 // Roles repository
 type Roles interface {
 	Bind(*models.RoleBinding) error
+	BindingsForServiceAccountID(string) ([]models.RoleBinding, error)
 	Clone() Roles
 	Create(*models.Role) error
+	Delete(string) error
 	DropBindings(string) error
+	DropInclusions(string) error
 	DropPermissions(string) error
+	ForName(string) (*models.Role, error)
 	ForServiceAccountID(string) ([]models.Role, error)
 	Get(string) (*models.Role, error)
+	GetBindings(string) ([]models.RoleBinding, error)
+	GetIncludedRoles(string) ([]models.Role, error)
+	Include(*models.RoleInclusion) error
+	IncludedRolesIDs([]string) ([]string, error)
 	GetServiceAccounts(string) ([]models.ServiceAccount, error)
 	List(*ListOptions) ([]models.Role, error)
 	ListCount() (int64, error)
+	ListSCIMManaged(*ListOptions) ([]models.Role, error)
+	ListSCIMManagedCount() (int64, error)
 	Search(string, *ListOptions) ([]models.Role, error)
 	SearchCount(string) (int64, error)
+	Unbind(string, string) error
+	UnbindExpired() ([]models.RoleBinding, error)
 	Update(*models.Role) error
 	WithNamePrefix(string, int) ([]models.Role, error)
 	setStorage(*Storage)
@@ -40,7 +53,8 @@ func (rs roles) GetServiceAccounts(
 	sas := []models.ServiceAccount{}
 	_, err := rs.storage.PG.DB.Query(
 		&sas,
-		`SELECT sa.id, sa.name, sa.picture, sa.email FROM service_accounts sa
+		`SELECT sa.id, sa.name, sa.key_id, sa.picture, sa.email
+		FROM service_accounts sa
 		JOIN role_bindings rb ON rb.service_account_id = sa.id
 		WHERE rb.role_id = ?
 		ORDER BY sa.created_at DESC`,
@@ -52,6 +66,21 @@ func (rs roles) GetServiceAccounts(
 	return sas, nil
 }
 
+// ForName retrieves the role, other than base roles, named name
+func (rs roles) ForName(name string) (*models.Role, error) {
+	r := new(models.Role)
+	if _, err := rs.storage.PG.DB.Query(
+		r, `SELECT id, name, is_base_role, scim_managed, created_at, updated_at
+		FROM roles WHERE name = ? AND is_base_role = false`, name,
+	); err != nil {
+		return nil, err
+	}
+	if r.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.Role{}, name)
+	}
+	return r, nil
+}
+
 func (rs roles) ForServiceAccountID(serviceAccountID string) ([]models.Role, error) {
 	var roles []models.Role
 	_, err := rs.storage.PG.DB.Query(
@@ -69,7 +98,8 @@ func (rs roles) ForServiceAccountID(serviceAccountID string) ([]models.Role, err
 
 func (rs roles) Create(r *models.Role) error {
 	_, err := rs.storage.PG.DB.Query(
-		r, `INSERT INTO roles (name, is_base_role) VALUES (?name, ?is_base_role)
+		r, `INSERT INTO roles (name, is_base_role, scim_managed)
+		VALUES (?name, ?is_base_role, ?scim_managed)
 		RETURNING id`, r,
 	)
 	return err
@@ -85,12 +115,62 @@ func (rs roles) Update(r *models.Role) error {
 
 func (rs roles) Bind(rb *models.RoleBinding) error {
 	_, err := rs.storage.PG.DB.Exec(
-		`INSERT INTO role_bindings (role_id, service_account_id)
-		VALUES (?role_id, ?service_account_id)`, rb,
+		`INSERT INTO role_bindings (role_id, service_account_id, from_provider,
+		not_before, expires_at) VALUES (?role_id, ?service_account_id,
+		?from_provider, ?not_before, ?expires_at)`, rb,
 	)
 	return err
 }
 
+// Unbind removes every binding of a service account to a role
+func (rs roles) Unbind(roleID, serviceAccountID string) error {
+	_, err := rs.storage.PG.DB.Exec(
+		`DELETE FROM role_bindings WHERE role_id = ? AND service_account_id = ?`,
+		roleID, serviceAccountID,
+	)
+	return err
+}
+
+// UnbindExpired removes role bindings whose time bound ended, returning them
+func (rs roles) UnbindExpired() ([]models.RoleBinding, error) {
+	rbs := []models.RoleBinding{}
+	if _, err := rs.storage.PG.DB.Query(
+		&rbs, `DELETE FROM role_bindings WHERE expires_at <= now()
+		RETURNING id, role_id, service_account_id, from_provider, not_before,
+		expires_at`,
+	); err != nil {
+		return nil, err
+	}
+	return rbs, nil
+}
+
+// GetBindings retrieves all bindings of a role, in effect or not
+func (rs roles) GetBindings(roleID string) ([]models.RoleBinding, error) {
+	rbs := []models.RoleBinding{}
+	if _, err := rs.storage.PG.DB.Query(
+		&rbs, `SELECT id, role_id, service_account_id, from_provider, not_before,
+		expires_at FROM role_bindings WHERE role_id = ?`, roleID,
+	); err != nil {
+		return nil, err
+	}
+	return rbs, nil
+}
+
+// BindingsForServiceAccountID retrieves all bindings of a service account,
+// in effect or not
+func (rs roles) BindingsForServiceAccountID(
+	serviceAccountID string,
+) ([]models.RoleBinding, error) {
+	rbs := []models.RoleBinding{}
+	if _, err := rs.storage.PG.DB.Query(
+		&rbs, `SELECT id, role_id, service_account_id, from_provider, not_before,
+		expires_at FROM role_bindings WHERE service_account_id = ?`, serviceAccountID,
+	); err != nil {
+		return nil, err
+	}
+	return rbs, nil
+}
+
 func (rs roles) WithNamePrefix(
 	prefix string, maxResults int,
 ) ([]models.Role, error) {
@@ -125,6 +205,30 @@ func (rs roles) ListCount() (int64, error) {
 	return count, nil
 }
 
+// ListSCIMManaged lists roles created through SCIM
+func (rs roles) ListSCIMManaged(lo *ListOptions) ([]models.Role, error) {
+	var rsSl []models.Role
+	if _, err := rs.storage.PG.DB.Query(
+		&rsSl, `SELECT id, name, scim_managed FROM roles
+		WHERE scim_managed = true
+		ORDER BY name ASC LIMIT ? OFFSET ?`, lo.Limit(), lo.Offset(),
+	); err != nil {
+		return nil, err
+	}
+	return rsSl, nil
+}
+
+// ListSCIMManagedCount counts roles created through SCIM
+func (rs roles) ListSCIMManagedCount() (int64, error) {
+	var count int64
+	if _, err := rs.storage.PG.DB.Query(
+		&count, `SELECT count(*) FROM roles WHERE scim_managed = true`,
+	); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (rs roles) Search(term string, lo *ListOptions) ([]models.Role, error) {
 	var rsSl []models.Role
 	if _, err := rs.storage.PG.DB.Query(
@@ -153,7 +257,7 @@ func (rs roles) SearchCount(term string) (int64, error) {
 func (rs roles) Get(id string) (*models.Role, error) {
 	r := new(models.Role)
 	if _, err := rs.storage.PG.DB.Query(
-		r, `SELECT id, name, is_base_role, created_at, updated_at
+		r, `SELECT id, name, is_base_role, scim_managed, created_at, updated_at
 		FROM roles WHERE id = ?`, id,
 	); err != nil {
 		return nil, err
@@ -171,6 +275,62 @@ func (rs roles) DropPermissions(roleID string) error {
 	return err
 }
 
+func (rs roles) Include(ri *models.RoleInclusion) error {
+	_, err := rs.storage.PG.DB.Exec(
+		`INSERT INTO role_inclusions (role_id, included_role_id)
+		VALUES (?role_id, ?included_role_id)`, ri,
+	)
+	return err
+}
+
+// GetIncludedRoles retrieves roles directly included by roleID
+func (rs roles) GetIncludedRoles(roleID string) ([]models.Role, error) {
+	rsSl := []models.Role{}
+	if _, err := rs.storage.PG.DB.Query(
+		&rsSl, `SELECT r.id, r.name FROM roles r
+		JOIN role_inclusions ri ON ri.included_role_id = r.id
+		WHERE ri.role_id = ?
+		ORDER BY r.name ASC`, roleID,
+	); err != nil {
+		return nil, err
+	}
+	return rsSl, nil
+}
+
+// IncludedRolesIDs retrieves ids of every role included by rolesIDs,
+// directly or through other roles
+func (rs roles) IncludedRolesIDs(rolesIDs []string) ([]string, error) {
+	ids := []string{}
+	if _, err := rs.storage.PG.DB.Query(
+		&ids, `WITH RECURSIVE included (role_id) AS (
+			SELECT included_role_id FROM role_inclusions WHERE role_id = ANY (?)
+			UNION
+			SELECT ri.included_role_id FROM role_inclusions ri
+			JOIN included i ON i.role_id = ri.role_id
+		)
+		SELECT role_id FROM included`,
+		pg.Array(rolesIDs),
+	); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (rs roles) DropInclusions(roleID string) error {
+	_, err := rs.storage.PG.DB.Exec(
+		`DELETE FROM role_inclusions WHERE role_id = ?`, roleID,
+	)
+	return err
+}
+
+// Delete removes a role, along with its permissions, bindings and inclusions
+func (rs roles) Delete(roleID string) error {
+	_, err := rs.storage.PG.DB.Exec(
+		`DELETE FROM roles WHERE id = ?`, roleID,
+	)
+	return err
+}
+
 func (rs roles) DropBindings(roleID string) error {
 	_, err := rs.storage.PG.DB.Exec(
 		`DELETE FROM role_bindings WHERE role_id = ?`, roleID,
@@ -12,18 +12,22 @@ import (
 type ServiceAccounts interface {
 	Clone() ServiceAccounts
 	Create(*models.ServiceAccount) error
+	Delete(string) error
 	DropBindings(string) error
 	ForEmail(string) (*models.ServiceAccount, error)
 	ForEmails([]string) ([]models.ServiceAccount, error)
-	ForKeyPair(string, string) (*models.ServiceAccount, error)
 	Get(string) (*models.ServiceAccount, error)
 	HasPermission(string, models.Permission) (bool, error)
 	List(*ListOptions) ([]models.ServiceAccount, error)
 	ListCount() (int64, error)
+	ListOAuth2Type(*ListOptions) ([]models.ServiceAccount, error)
+	ListOAuth2TypeCount() (int64, error)
 	ListWithPermission(*ListOptions, models.Permission) ([]models.ServiceAccount, error)
 	ListWithPermissionCount(models.Permission) (int64, error)
 	Search(string, *ListOptions) ([]models.ServiceAccount, error)
 	SearchCount(string) (int64, error)
+	SetActive(string, bool) error
+	SetVerifiedHostedDomain(string, string) error
 	Update(*models.ServiceAccount) error
 	setStorage(*Storage)
 }
@@ -40,7 +44,8 @@ func (sas serviceAccounts) Get(id string) (*models.ServiceAccount, error) {
 	sa := new(models.ServiceAccount)
 	if _, err := sas.storage.PG.DB.Query(
 		sa,
-		`SELECT id, name, key_id, key_secret, email, base_role_id, picture
+		`SELECT id, name, key_id, email, base_role_id, picture, active,
+		verified_hosted_domain
 		FROM service_accounts
 		WHERE id = ?`,
 		id,
@@ -70,23 +75,34 @@ func (sas serviceAccounts) DropBindings(saID string) error {
 	return err
 }
 
+// HasPermission checks if a service account has permission, considering
+// only permissions and role bindings in effect
 func (sas serviceAccounts) HasPermission(
 	serviceAccountID string, permission models.Permission,
 ) (bool, error) {
-	var count int64
+	if permission.IsDeny() {
+		return false, nil
+	}
+	var has bool
 	if _, err := sas.storage.PG.DB.Query(
-		&count,
-		`SELECT count(*) FROM permissions
-    WHERE (service = ? OR service = '*') AND (action = ? OR action = '*')
-    AND CASE WHEN ? = 'RO' THEN ownership_level = 'RO' ELSE true END
-    AND role_id = ANY (SELECT role_id FROM role_bindings WHERE service_account_id = ?)
-    AND resource_hierarchy = ANY (?)
-    `, permission.Service, permission.Action.String(), permission.OwnershipLevel.String(),
+		&has,
+		`SELECT EXISTS (
+      SELECT 1 FROM service_account_permissions(?3)
+      WHERE (service = ?0 OR service = '*') AND (action = ?1 OR action = '*')
+      AND CASE WHEN ?2 = 'RO' THEN ownership_level = 'RO' ELSE ownership_level != 'DENY' END
+      AND resource_hierarchy = ANY (?4)
+    ) AND NOT EXISTS (
+      SELECT 1 FROM service_account_permissions(?3)
+      WHERE ownership_level = 'DENY'
+      AND (service = ?0 OR service = '*' OR ?0 = '*') AND (action = ?1 OR action = '*' OR ?1 = '*')
+      AND (resource_hierarchy = ANY (?4) OR resource_hierarchy LIKE ?5)
+    )`, permission.Service, permission.Action.String(), permission.OwnershipLevel.String(),
 		serviceAccountID, pg.Array(permission.ResourceHierarchy.PermissionMatches()),
+		containedResourceHierarchiesPattern(permission.ResourceHierarchy),
 	); err != nil {
 		return false, err
 	}
-	return count > 0, nil
+	return has, nil
 }
 
 func (sas serviceAccounts) List(
@@ -95,7 +111,7 @@ func (sas serviceAccounts) List(
 	var saSl []models.ServiceAccount
 	if _, err := sas.storage.PG.DB.Query(
 		&saSl,
-		`SELECT id, name, email, picture, base_role_id FROM service_accounts
+		`SELECT id, name, email, picture, base_role_id, active FROM service_accounts
 		ORDER BY name ASC LIMIT ? OFFSET ?`, lo.Limit(), lo.Offset(),
 	); err != nil {
 		return nil, err
@@ -121,23 +137,61 @@ func (sas serviceAccounts) ListCount() (int64, error) {
 	return count, nil
 }
 
+// ListOAuth2Type lists the service accounts of people, the ones without
+// a key pair, ordered by email
+func (sas serviceAccounts) ListOAuth2Type(
+	lo *ListOptions,
+) ([]models.ServiceAccount, error) {
+	saSl := []models.ServiceAccount{}
+	if _, err := sas.storage.PG.DB.Query(
+		&saSl,
+		`SELECT id, name, email, picture, base_role_id, active FROM service_accounts
+		WHERE coalesce(key_id, '') = '' ORDER BY email ASC LIMIT ? OFFSET ?`,
+		lo.Limit(), lo.Offset(),
+	); err != nil {
+		return nil, err
+	}
+	for i := range saSl {
+		saSl[i].AuthenticationType = models.AuthenticationTypes.OAuth2
+	}
+	return saSl, nil
+}
+
+// ListOAuth2TypeCount counts the service accounts without a key pair
+func (sas serviceAccounts) ListOAuth2TypeCount() (int64, error) {
+	var count int64
+	if _, err := sas.storage.PG.DB.Query(
+		&count,
+		`SELECT count(*) FROM service_accounts WHERE coalesce(key_id, '') = ''`,
+	); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 func (sas serviceAccounts) ListWithPermission(
 	lo *ListOptions, permission models.Permission,
 ) ([]models.ServiceAccount, error) {
 	var saSl []models.ServiceAccount
 	if _, err := sas.storage.PG.DB.Query(
 		&saSl,
-		`SELECT DISTINCT sas.id, sas.name, sas.email, sas.picture, sas.base_role_id FROM service_accounts sas
-    INNER JOIN role_bindings rb ON rb.service_account_id = sas.id
-    WHERE rb.role_id = ANY (
-      SELECT DISTINCT(role_id) FROM permissions
-      WHERE (service = ? OR service = '*') AND (action = ? OR action = '*')
-      AND CASE WHEN ? = 'RO' THEN ownership_level = 'RO' ELSE true END
-      AND resource_hierarchy = ANY (?)
+		`SELECT sas.id, sas.name, sas.email, sas.picture, sas.base_role_id, sas.active FROM service_accounts sas
+    WHERE EXISTS (
+      SELECT 1 FROM service_account_permissions(sas.id)
+      WHERE (service = ?0 OR service = '*') AND (action = ?1 OR action = '*')
+      AND CASE WHEN ?2 = 'RO' THEN ownership_level = 'RO' ELSE ownership_level != 'DENY' END
+      AND resource_hierarchy = ANY (?3)
+    ) AND NOT EXISTS (
+      SELECT 1 FROM service_account_permissions(sas.id)
+      WHERE ownership_level = 'DENY'
+      AND (service = ?0 OR service = '*' OR ?0 = '*') AND (action = ?1 OR action = '*' OR ?1 = '*')
+      AND (resource_hierarchy = ANY (?3) OR resource_hierarchy LIKE ?4)
     )
-    ORDER BY name ASC LIMIT ? OFFSET ?
+    ORDER BY name ASC LIMIT ?5 OFFSET ?6
     `, permission.Service, permission.Action.String(), permission.OwnershipLevel.String(),
-		pg.Array(permission.ResourceHierarchy.PermissionMatches()), lo.Limit(), lo.Offset(),
+		pg.Array(permission.ResourceHierarchy.PermissionMatches()),
+		containedResourceHierarchiesPattern(permission.ResourceHierarchy),
+		lo.Limit(), lo.Offset(),
 	); err != nil {
 		return nil, err
 	}
@@ -148,16 +202,21 @@ func (sas serviceAccounts) ListWithPermissionCount(permission models.Permission)
 	var count int64
 	if _, err := sas.storage.PG.DB.Query(
 		&count,
-		`SELECT count(distinct sas.id) FROM service_accounts sas
-    INNER JOIN role_bindings rb ON rb.service_account_id = sas.id
-    WHERE rb.role_id = ANY (
-      SELECT DISTINCT(role_id) FROM permissions
-      WHERE (service = ? OR service = '*') AND (action = ? OR action = '*')
-      AND CASE WHEN ? = 'RO' THEN ownership_level = 'RO' ELSE true END
-      AND resource_hierarchy = ANY (?)
+		`SELECT count(*) FROM service_accounts sas
+    WHERE EXISTS (
+      SELECT 1 FROM service_account_permissions(sas.id)
+      WHERE (service = ?0 OR service = '*') AND (action = ?1 OR action = '*')
+      AND CASE WHEN ?2 = 'RO' THEN ownership_level = 'RO' ELSE ownership_level != 'DENY' END
+      AND resource_hierarchy = ANY (?3)
+    ) AND NOT EXISTS (
+      SELECT 1 FROM service_account_permissions(sas.id)
+      WHERE ownership_level = 'DENY'
+      AND (service = ?0 OR service = '*' OR ?0 = '*') AND (action = ?1 OR action = '*' OR ?1 = '*')
+      AND (resource_hierarchy = ANY (?3) OR resource_hierarchy LIKE ?4)
     )
     `, permission.Service, permission.Action.String(), permission.OwnershipLevel.String(),
 		pg.Array(permission.ResourceHierarchy.PermissionMatches()),
+		containedResourceHierarchiesPattern(permission.ResourceHierarchy),
 	); err != nil {
 		return 0, err
 	}
@@ -170,7 +229,7 @@ func (sas serviceAccounts) Search(
 	saSl := []models.ServiceAccount{}
 	if _, err := sas.storage.PG.DB.Query(
 		&saSl,
-		`SELECT id, name, email, picture, base_role_id FROM service_accounts
+		`SELECT id, name, email, picture, base_role_id, active FROM service_accounts
 		WHERE name ILIKE ?0 OR email ILIKE ?0
 		ORDER BY name ASC LIMIT ?1 OFFSET ?2`,
 		fmt.Sprintf("%%%s%%", term), lo.Limit(), lo.Offset(),
@@ -205,7 +264,8 @@ func (sas serviceAccounts) ForEmail(
 ) (*models.ServiceAccount, error) {
 	sa := new(models.ServiceAccount)
 	if _, err := sas.storage.PG.DB.Query(
-		sa, `SELECT id, name, key_id, key_secret, email, base_role_id, picture
+		sa, `SELECT id, name, key_id, email, base_role_id, picture, active,
+		verified_hosted_domain
 		FROM service_accounts WHERE email = ?`, email,
 	); err != nil {
 		return nil, err
@@ -222,7 +282,7 @@ func (sas serviceAccounts) ForEmails(
 ) ([]models.ServiceAccount, error) {
 	saSl := []models.ServiceAccount{}
 	if _, err := sas.storage.PG.DB.Query(
-		&saSl, `SELECT id, name, key_id, key_secret, email, base_role_id, picture
+		&saSl, `SELECT id, name, key_id, email, base_role_id, picture, active
 		FROM service_accounts WHERE email = ANY(?)`, pg.Array(emails),
 	); err != nil {
 		return nil, err
@@ -230,29 +290,11 @@ func (sas serviceAccounts) ForEmails(
 	return saSl, nil
 }
 
-// ForKeyPair retrieves Service Account corresponding
-func (sas serviceAccounts) ForKeyPair(
-	keyID, keySecret string,
-) (*models.ServiceAccount, error) {
-	sa := new(models.ServiceAccount)
-	if _, err := sas.storage.PG.DB.Query(
-		sa, `SELECT id, name, key_id, key_secret, email, base_role_id
-		FROM service_accounts WHERE key_id = ? AND key_secret = ?`,
-		keyID, keySecret,
-	); err != nil {
-		return nil, err
-	}
-	if sa.ID == "" {
-		return nil, errors.NewEntityNotFoundError(models.ServiceAccount{}, keyID)
-	}
-	return sa, nil
-}
-
 func (sas serviceAccounts) Create(sa *models.ServiceAccount) error {
 	_, err := sas.storage.PG.DB.Query(
-		sa, `INSERT INTO service_accounts (id, name, email, key_id, key_secret,
-		base_role_id) VALUES (?id, ?name, ?email, ?key_id, ?key_secret,
-		?base_role_id) RETURNING id`, sa,
+		sa, `INSERT INTO service_accounts (id, name, email, key_id,
+		base_role_id) VALUES (?id, ?name, ?email, ?key_id, ?base_role_id)
+		RETURNING id, active`, sa,
 	)
 	return err
 }
@@ -260,8 +302,38 @@ func (sas serviceAccounts) Create(sa *models.ServiceAccount) error {
 func (sas serviceAccounts) Update(sa *models.ServiceAccount) error {
 	_, err := sas.storage.PG.DB.Exec(
 		`UPDATE service_accounts SET name = ?name, email = ?email,
-		key_id = ?key_id, key_secret = ?key_secret, base_role_id = ?base_role_id,
-		picture = ?picture, updated_at = now() WHERE id = ?id`, sa,
+		key_id = ?key_id, base_role_id = ?base_role_id,
+		picture = ?picture, verified_hosted_domain = CASE
+		WHEN email = ?email THEN verified_hosted_domain ELSE '' END,
+		updated_at = now() WHERE id = ?id`, sa,
+	)
+	return err
+}
+
+// SetActive activates or deactivates a service account
+func (sas serviceAccounts) SetActive(id string, active bool) error {
+	_, err := sas.storage.PG.DB.Exec(
+		`UPDATE service_accounts SET active = ?, updated_at = now()
+		WHERE id = ?`, active, id,
+	)
+	return err
+}
+
+// SetVerifiedHostedDomain records the domain an OAuth2 provider vouched id
+// belongs to
+func (sas serviceAccounts) SetVerifiedHostedDomain(id, hostedDomain string) error {
+	_, err := sas.storage.PG.DB.Exec(
+		`UPDATE service_accounts SET verified_hosted_domain = ?,
+		updated_at = now() WHERE id = ?`, hostedDomain, id,
+	)
+	return err
+}
+
+// Delete removes a service account, along with its role bindings and
+// access keys
+func (sas serviceAccounts) Delete(id string) error {
+	_, err := sas.storage.PG.DB.Exec(
+		`DELETE FROM service_accounts WHERE id = ?`, id,
 	)
 	return err
 }
@@ -6,6 +6,7 @@ import "github.com/topfreegames/Will.IAM/models"
 type Services interface {
 	List() ([]models.Service, error)
 	Get(string) (*models.Service, error)
+	ForServiceAccount(string) ([]models.Service, error)
 	WithPermissionName(string) (*models.Service, error)
 	Create(*models.Service) error
 	Update(*models.Service) error
@@ -24,8 +25,10 @@ func (ss *services) Clone() Services {
 func (ss services) Create(s *models.Service) error {
 	_, err := ss.storage.PG.DB.Query(
 		s, `INSERT INTO services (name, permission_name, service_account_id,
-		creator_service_account_id, am_url) VALUES (?name, ?permission_name,
-		?service_account_id, ?creator_service_account_id, ?am_url) RETURNING id`,
+		creator_service_account_id, am_url, redirect_origins, redirect_uris)
+		VALUES (?name, ?permission_name, ?service_account_id,
+		?creator_service_account_id, ?am_url, coalesce(?redirect_origins, '{}'),
+		coalesce(?redirect_uris, '{}')) RETURNING id`,
 		s,
 	)
 	return err
@@ -53,6 +56,17 @@ func (ss services) Get(id string) (*models.Service, error) {
 	return s, nil
 }
 
+// ForServiceAccount returns the services backed by service account saID
+func (ss services) ForServiceAccount(saID string) ([]models.Service, error) {
+	sSl := []models.Service{}
+	if _, err := ss.storage.PG.DB.Query(
+		&sSl, `SELECT * FROM services WHERE service_account_id = ?`, saID,
+	); err != nil {
+		return nil, err
+	}
+	return sSl, nil
+}
+
 // WithPermissionName looks for a service given a PermissionName
 func (ss services) WithPermissionName(
 	permissionName string,
@@ -69,7 +83,8 @@ func (ss services) WithPermissionName(
 func (ss services) Update(s *models.Service) error {
 	_, err := ss.storage.PG.DB.Exec(
 		`UPDATE services SET name = ?name, permission_name = ?permission_name,
-		am_url = ?am_url WHERE id = ?id`, s,
+		am_url = ?am_url, redirect_origins = coalesce(?redirect_origins, '{}'),
+		redirect_uris = coalesce(?redirect_uris, '{}') WHERE id = ?id`, s,
 	)
 	return err
 }
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// SigningKeys contract
+type SigningKeys interface {
+	Clone() SigningKeys
+	Create(*models.SigningKey) error
+	CreatedSince(time.Time) ([]models.SigningKey, error)
+	Get(string) (*models.SigningKey, error)
+	Latest() (*models.SigningKey, error)
+	Lock() error
+	setStorage(*Storage)
+}
+
+type signingKeys struct {
+	*withStorage
+}
+
+func (sks *signingKeys) Clone() SigningKeys {
+	return NewSigningKeys(sks.storage.Clone())
+}
+
+func (sks signingKeys) Create(sk *models.SigningKey) error {
+	_, err := sks.storage.PG.DB.Query(
+		sk, `INSERT INTO signing_keys (id, private_key) VALUES (?id, ?private_key)
+		RETURNING created_at`, sk,
+	)
+	return err
+}
+
+// CreatedSince retrieves signing keys created after t, newest first
+func (sks signingKeys) CreatedSince(t time.Time) ([]models.SigningKey, error) {
+	skSl := []models.SigningKey{}
+	if _, err := sks.storage.PG.DB.Query(
+		&skSl, `SELECT * FROM signing_keys WHERE created_at > ?
+		ORDER BY created_at DESC`, t,
+	); err != nil {
+		return nil, err
+	}
+	return skSl, nil
+}
+
+func (sks signingKeys) Get(id string) (*models.SigningKey, error) {
+	sk := new(models.SigningKey)
+	if _, err := sks.storage.PG.DB.Query(
+		sk, `SELECT * FROM signing_keys WHERE id = ?`, id,
+	); err != nil {
+		return nil, err
+	}
+	if sk.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.SigningKey{}, id)
+	}
+	return sk, nil
+}
+
+// Latest retrieves the newest signing key
+func (sks signingKeys) Latest() (*models.SigningKey, error) {
+	sk := new(models.SigningKey)
+	if _, err := sks.storage.PG.DB.Query(
+		sk, `SELECT * FROM signing_keys ORDER BY created_at DESC LIMIT 1`,
+	); err != nil {
+		return nil, err
+	}
+	if sk.ID == "" {
+		return nil, errors.NewEntityNotFoundError(models.SigningKey{}, "latest")
+	}
+	return sk, nil
+}
+
+// Lock serializes signing keys rotation until the end of the current
+// transaction
+func (sks signingKeys) Lock() error {
+	_, err := sks.storage.PG.DB.Exec(
+		`SELECT pg_advisory_xact_lock(hashtext('signing_keys'))`,
+	)
+	return err
+}
+
+// NewSigningKeys ctor
+func NewSigningKeys(s *Storage) SigningKeys {
+	return &signingKeys{&withStorage{storage: s}}
+}
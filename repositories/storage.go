@@ -7,7 +7,9 @@ import (
 // Storage holds pointers to storage engines used by
 // repositories
 type Storage struct {
-	PG *pg.Client
+	PG    *pg.Client
+	Cache *Cache
+	inTx  bool
 }
 
 // NewStorage ctor
@@ -21,7 +23,16 @@ func (s *Storage) Clone() *Storage {
 	if s.PG != nil {
 		*pg = *s.PG
 	}
-	return &Storage{PG: pg}
+	return &Storage{PG: pg, Cache: s.Cache, inTx: s.inTx}
+}
+
+// cache returns s.Cache, or nil when s is inside a transaction: its reads
+// may see uncommitted rows, which a rollback would never invalidate
+func (s *Storage) cache() *Cache {
+	if s.inTx {
+		return nil
+	}
+	return s.Cache
 }
 
 type withStorage struct {
@@ -0,0 +1,42 @@
+// +build integration
+
+package repositories_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/topfreegames/Will.IAM/repositories"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+)
+
+func TestStorageCacheBypassedInTx(t *testing.T) {
+	storage := helpers.GetStorage(t)
+	storage.Cache = repositories.NewCache(time.Minute)
+	repo := repositories.New(storage)
+	saID := uuid.Must(uuid.NewV4()).String()
+
+	rollback := errors.New("rollback")
+	err := repo.WithPGTx(context.Background(), func(repo *repositories.All) error {
+		if _, err := repo.Permissions.ForServiceAccount(saID); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf("Expected rollback error. Got %v", err)
+	}
+	if _, ok := storage.Cache.GetPermissions(saID); ok {
+		t.Error("Expected permissions read inside a transaction not to be cached")
+	}
+
+	if _, err := repo.Permissions.ForServiceAccount(saID); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if _, ok := storage.Cache.GetPermissions(saID); !ok {
+		t.Error("Expected permissions read outside transactions to be cached")
+	}
+}
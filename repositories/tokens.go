@@ -1,13 +1,22 @@
 package repositories
 
 import (
+	"time"
+
+	"github.com/go-pg/pg"
 	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 )
 
 // Tokens contract
 type Tokens interface {
+	DeleteForEmail(string) error
 	Get(string) (*models.Token, error)
+	PurgeExpired(time.Time) (int, error)
+	PurgeRevokedJTIs() (int, error)
+	Revoke(string) ([]models.Token, error)
+	RevokeJTI(string, time.Time) error
+	JTIRevoked(string) (bool, error)
 	Save(*models.Token) error
 	Clone() Tokens
 	setStorage(*Storage)
@@ -23,6 +32,12 @@ func (ts *tokens) Clone() Tokens {
 }
 
 func (ts tokens) Get(accessToken string) (*models.Token, error) {
+	cache := ts.storage.cache()
+	if cache != nil {
+		if t, ok := cache.GetToken(accessToken); ok && tokenUsable(t) {
+			return t, nil
+		}
+	}
 	t := new(models.Token)
 	if _, err := ts.storage.PG.DB.Query(
 		t, `SELECT * FROM tokens WHERE access_token = ?0 AND
@@ -34,14 +49,23 @@ func (ts tokens) Get(accessToken string) (*models.Token, error) {
 	if t.AccessToken == "" {
 		return nil, errors.NewEntityNotFoundError(models.Token{}, accessToken)
 	}
+	if cache != nil {
+		cache.SetToken(t)
+	}
 	return t, nil
 }
 
+// tokenUsable mirrors Get expired_at condition for cached tokens
+func tokenUsable(t *models.Token) bool {
+	return t.ExpiredAt.IsZero() ||
+		t.ExpiredAt.After(time.Now().Add(-60*time.Second))
+}
+
 func (ts tokens) Save(token *models.Token) error {
 	_, err := ts.storage.PG.DB.Exec(`INSERT INTO tokens (access_token,
-	refresh_token, expired_at, token_type, expiry, email, updated_at)
-	VALUES (?access_token, ?refresh_token, ?expired_at, ?token_type,
-	?expiry, ?email, now()) ON CONFLICT (access_token) DO UPDATE SET
+	refresh_token, expired_at, token_type, expiry, email, provider,
+	updated_at) VALUES (?access_token, ?refresh_token, ?expired_at,
+	?token_type, ?expiry, ?email, ?provider, now()) ON CONFLICT (access_token) DO UPDATE SET
 	expired_at = ?expired_at, updated_at = now()`, token)
 	return err
 }
@@ -56,6 +80,78 @@ func (ts tokens) FindByEmail(email string) ([]models.Token, error) {
 	return tokens, nil
 }
 
+// Revoke expires token, either an access or a refresh token, along with
+// every token refreshed from the same refresh token, past the 60 seconds
+// Get still accepts expired tokens for
+func (ts tokens) Revoke(token string) ([]models.Token, error) {
+	revoked := []models.Token{}
+	if _, err := ts.storage.PG.DB.Query(
+		&revoked, `UPDATE tokens SET expired_at = now() - INTERVAL '60 sec',
+		updated_at = now()
+		WHERE access_token = ?0 OR (refresh_token != '' AND refresh_token IN (
+			?0, (SELECT refresh_token FROM tokens WHERE access_token = ?0)
+		))
+		RETURNING id, email`, token,
+	); err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// RevokeJTI revokes the signed access token identified by jti, until it
+// expires at expiresAt
+func (ts tokens) RevokeJTI(jti string, expiresAt time.Time) error {
+	_, err := ts.storage.PG.DB.Exec(
+		`INSERT INTO revoked_access_tokens (jti, expires_at) VALUES (?, ?)
+		ON CONFLICT (jti) DO NOTHING`, jti, expiresAt,
+	)
+	return err
+}
+
+// JTIRevoked checks if the signed access token identified by jti was revoked
+func (ts tokens) JTIRevoked(jti string) (bool, error) {
+	var revoked bool
+	if _, err := ts.storage.PG.DB.Query(
+		pg.Scan(&revoked),
+		`SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = ?)`, jti,
+	); err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// DeleteForEmail removes every token issued to email
+func (ts tokens) DeleteForEmail(email string) error {
+	_, err := ts.storage.PG.DB.Exec(
+		`DELETE FROM tokens WHERE email = ?`, email,
+	)
+	return err
+}
+
+// PurgeExpired removes tokens expired before expiredBefore, returning how
+// many; they stop being accepted 60 seconds after expiring
+func (ts tokens) PurgeExpired(expiredBefore time.Time) (int, error) {
+	res, err := ts.storage.PG.DB.Exec(
+		`DELETE FROM tokens WHERE expired_at < ?`, expiredBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
+// PurgeRevokedJTIs removes revocations of signed access tokens that expired
+// anyway, returning how many
+func (ts tokens) PurgeRevokedJTIs() (int, error) {
+	res, err := ts.storage.PG.DB.Exec(
+		`DELETE FROM revoked_access_tokens WHERE expires_at <= now()`,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected(), nil
+}
+
 // NewTokens ctor
 func NewTokens(storage *Storage) Tokens {
 	return &tokens{&withStorage{storage: storage}}
@@ -0,0 +1,33 @@
+package scim
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter is an equality filter, the only kind identity providers use to
+// look up Users and Groups; Attribute is in lower case, as attribute names
+// are case insensitive
+type Filter struct {
+	Attribute string
+	Value     string
+}
+
+var equalityFilter = regexp.MustCompile(
+	`^\s*([A-Za-z][A-Za-z0-9._:-]*)\s+(?i:eq)\s+("(?:[^"\\]|\\.)*")\s*$`,
+)
+
+// ParseFilter parses filters as `userName eq "some.user@example.com"`
+func ParseFilter(filter string) (*Filter, error) {
+	m := equalityFilter.FindStringSubmatch(filter)
+	if m == nil {
+		return nil, fmt.Errorf("unsupported filter %s", filter)
+	}
+	value, err := strconv.Unquote(m[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid value in filter %s", filter)
+	}
+	return &Filter{Attribute: strings.ToLower(m[1]), Value: value}, nil
+}
@@ -0,0 +1,244 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is the body of PATCH requests
+type PatchOp struct {
+	Schemas    []string    `json:"schemas"`
+	Operations []Operation `json:"Operations"`
+}
+
+// Operation is an add, replace or remove of the attribute at Path, or of
+// the attributes in Value when there's no Path
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// PatchError is a PatchOp that can't be applied; ScimType tells why
+type PatchError struct {
+	ScimType string
+	Detail   string
+}
+
+func (e *PatchError) Error() string {
+	return e.Detail
+}
+
+func newPatchError(scimType, format string, a ...interface{}) *PatchError {
+	return &PatchError{ScimType: scimType, Detail: fmt.Sprintf(format, a...)}
+}
+
+// membersFilterPrefix starts paths as members[value eq "some id"]
+const membersFilterPrefix = "members["
+
+// emailsFilterPrefix starts paths as emails[type eq "work"].value
+const emailsFilterPrefix = "emails["
+
+// ApplyToUser applies p operations to u, in order. Attributes Users don't
+// keep, as the enterprise extension ones, are ignored, for identity
+// providers send every attribute they map
+func (p PatchOp) ApplyToUser(u *User) error {
+	for _, o := range p.Operations {
+		op := strings.ToLower(o.Op)
+		switch {
+		case op != "add" && op != "replace" && op != "remove":
+			return newPatchError("invalidSyntax", "unsupported op %s", o.Op)
+		case op == "remove":
+			if o.Path == "" {
+				return newPatchError("noTarget", "remove requires a path")
+			}
+			removeUserAttribute(u, o.Path)
+		case o.Path == "":
+			attributes := map[string]json.RawMessage{}
+			if err := json.Unmarshal(o.Value, &attributes); err != nil {
+				return newPatchError("invalidValue", "value must be an object")
+			}
+			for path, value := range attributes {
+				if err := setUserAttribute(u, path, value); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := setUserAttribute(u, o.Path, o.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setUserAttribute(u *User, path string, value json.RawMessage) error {
+	var err error
+	lower := strings.ToLower(path)
+	switch {
+	case lower == "username":
+		err = json.Unmarshal(value, &u.UserName)
+	case lower == "displayname":
+		err = json.Unmarshal(value, &u.DisplayName)
+	case lower == "active":
+		var active bool
+		active, err = unmarshalBool(value)
+		u.Active = &active
+	case lower == "name":
+		u.Name = &Name{}
+		err = json.Unmarshal(value, u.Name)
+	case strings.HasPrefix(lower, "name."):
+		if u.Name == nil {
+			u.Name = &Name{}
+		}
+		switch strings.TrimPrefix(lower, "name.") {
+		case "formatted":
+			err = json.Unmarshal(value, &u.Name.Formatted)
+		case "givenname":
+			err = json.Unmarshal(value, &u.Name.GivenName)
+		case "familyname":
+			err = json.Unmarshal(value, &u.Name.FamilyName)
+		}
+	case lower == "emails":
+		err = json.Unmarshal(value, &u.Emails)
+	case strings.HasPrefix(lower, emailsFilterPrefix) &&
+		strings.HasSuffix(lower, "].value"):
+		var email string
+		if err = json.Unmarshal(value, &email); err == nil {
+			u.Emails = []Email{{Value: email, Type: "work", Primary: true}}
+		}
+	}
+	if err != nil {
+		return newPatchError("invalidValue", "invalid value for %s", path)
+	}
+	return nil
+}
+
+func removeUserAttribute(u *User, path string) {
+	switch strings.ToLower(path) {
+	case "displayname":
+		u.DisplayName = ""
+	case "name":
+		u.Name = nil
+	}
+}
+
+// unmarshalBool accepts booleans as strings too, as some identity
+// providers send "True" and "False"
+func unmarshalBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(s)
+}
+
+// ApplyToGroup applies p operations to g, in order
+func (p PatchOp) ApplyToGroup(g *Group) error {
+	for _, o := range p.Operations {
+		op := strings.ToLower(o.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return newPatchError("invalidSyntax", "unsupported op %s", o.Op)
+		}
+		if o.Path != "" {
+			if err := applyToGroupAttribute(g, op, o.Path, o.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if op == "remove" {
+			return newPatchError("noTarget", "remove requires a path")
+		}
+		attributes := map[string]json.RawMessage{}
+		if err := json.Unmarshal(o.Value, &attributes); err != nil {
+			return newPatchError("invalidValue", "value must be an object")
+		}
+		for path, value := range attributes {
+			// identity providers send id and externalId along
+			if l := strings.ToLower(path); l != "displayname" && l != "members" {
+				continue
+			}
+			if err := applyToGroupAttribute(g, op, path, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func applyToGroupAttribute(
+	g *Group, op, path string, value json.RawMessage,
+) error {
+	lower := strings.ToLower(path)
+	switch {
+	case lower == "displayname":
+		if op == "remove" {
+			return newPatchError("mutability", "displayName is required")
+		}
+		if err := json.Unmarshal(value, &g.DisplayName); err != nil {
+			return newPatchError("invalidValue", "invalid value for %s", path)
+		}
+	case lower == "members":
+		noValue := len(value) == 0 || string(value) == "null"
+		members := []Reference{}
+		if !noValue {
+			if err := json.Unmarshal(value, &members); err != nil {
+				return newPatchError("invalidValue", "invalid value for %s", path)
+			}
+		}
+		switch {
+		case op == "replace":
+			g.Members = members
+		case op == "add":
+			g.Members = addMembers(g.Members, members)
+		case noValue:
+			g.Members = []Reference{}
+		default:
+			g.Members = removeMembers(g.Members, members)
+		}
+	case strings.HasPrefix(lower, membersFilterPrefix) &&
+		strings.HasSuffix(lower, "]") && op == "remove":
+		f, err := ParseFilter(path[len(membersFilterPrefix) : len(path)-1])
+		if err != nil || f.Attribute != "value" {
+			return newPatchError("invalidFilter", "unsupported path %s", path)
+		}
+		g.Members = removeMembers(g.Members, []Reference{{Value: f.Value}})
+	default:
+		return newPatchError("invalidPath", "unsupported path %s", path)
+	}
+	return nil
+}
+
+func addMembers(members, added []Reference) []Reference {
+	in := map[string]bool{}
+	for _, m := range members {
+		in[m.Value] = true
+	}
+	for _, m := range added {
+		if !in[m.Value] {
+			in[m.Value] = true
+			members = append(members, m)
+		}
+	}
+	return members
+}
+
+func removeMembers(members, removed []Reference) []Reference {
+	out := map[string]bool{}
+	for _, m := range removed {
+		out[m.Value] = true
+	}
+	kept := []Reference{}
+	for _, m := range members {
+		if !out[m.Value] {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
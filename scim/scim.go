@@ -0,0 +1,243 @@
+// Package scim translates SCIM 2.0 (RFC 7643 and 7644) Users and Groups,
+// as identity providers provision them, to and from service accounts and
+// roles
+package scim
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/topfreegames/Will.IAM/models"
+)
+
+// Schemas of the resources and messages handled
+const (
+	UserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ContentType of SCIM requests and responses
+const ContentType = "application/scim+json"
+
+// Meta is the metadata of a resource
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+// Name is the name of a User
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Email is an email address of a User
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Reference points to another resource, as Group members and User groups
+// do
+type Reference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User is a SCIM User, backed by an OAuth2 type service account whose
+// email is UserName
+type User struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        *Name       `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []Email     `json:"emails,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Groups      []Reference `json:"groups,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// Validate User
+func (u User) Validate() models.Validation {
+	v := &models.Validation{}
+	if u.UserName == "" && u.email() == "" {
+		v.AddError("userName", "required")
+	}
+	return *v
+}
+
+func (u User) email() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// ApplyTo sets sa name, email and active as u has them; the email is
+// UserName, or else the primary email, and the name the first of
+// DisplayName, Name and the email that is set
+func (u User) ApplyTo(sa *models.ServiceAccount) {
+	sa.Email = u.UserName
+	if sa.Email == "" {
+		sa.Email = u.email()
+	}
+	sa.Name = u.DisplayName
+	if sa.Name == "" && u.Name != nil {
+		sa.Name = u.Name.Formatted
+		if sa.Name == "" {
+			sa.Name = strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+		}
+	}
+	if sa.Name == "" {
+		sa.Name = sa.Email
+	}
+	if u.Active != nil {
+		sa.Active = *u.Active
+	}
+}
+
+// UserFromServiceAccount builds the User of sa, a member of roles, whose
+// location is under baseURL
+func UserFromServiceAccount(
+	sa *models.ServiceAccount, roles []models.Role, baseURL string,
+) User {
+	active := sa.Active
+	u := User{
+		Schemas:     []string{UserSchema},
+		ID:          sa.ID,
+		UserName:    sa.Email,
+		Name:        &Name{Formatted: sa.Name},
+		DisplayName: sa.Name,
+		Emails:      []Email{{Value: sa.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta:        buildMeta("User", sa.CreatedUpdatedAt, baseURL, "Users", sa.ID),
+	}
+	for _, r := range roles {
+		if r.IsBaseRole {
+			continue
+		}
+		u.Groups = append(u.Groups, Reference{
+			Value:   r.ID,
+			Display: r.Name,
+			Ref:     fmt.Sprintf("%s/Groups/%s", baseURL, r.ID),
+		})
+	}
+	return u
+}
+
+// Group is a SCIM Group, backed by a role whose members are the service
+// accounts bound to it
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// Validate Group
+func (g Group) Validate() models.Validation {
+	v := &models.Validation{}
+	if g.DisplayName == "" {
+		v.AddError("displayName", "required")
+	}
+	return *v
+}
+
+// MembersIDs are the ids of the service accounts members of g
+func (g Group) MembersIDs() []string {
+	ids := make([]string, len(g.Members))
+	for i := range g.Members {
+		ids[i] = g.Members[i].Value
+	}
+	return ids
+}
+
+// GroupFromRole builds the Group of r, with sas as members, whose location
+// is under baseURL
+func GroupFromRole(
+	r *models.Role, sas []models.ServiceAccount, baseURL string,
+) Group {
+	g := Group{
+		Schemas:     []string{GroupSchema},
+		ID:          r.ID,
+		DisplayName: r.Name,
+		Members:     []Reference{},
+		Meta:        buildMeta("Group", r.CreatedUpdatedAt, baseURL, "Groups", r.ID),
+	}
+	for _, sa := range sas {
+		g.Members = append(g.Members, Reference{
+			Value:   sa.ID,
+			Display: sa.Email,
+			Ref:     fmt.Sprintf("%s/Users/%s", baseURL, sa.ID),
+		})
+	}
+	return g
+}
+
+func buildMeta(
+	resourceType string, cu models.CreatedUpdatedAt,
+	baseURL, endpoint, id string,
+) *Meta {
+	return &Meta{
+		ResourceType: resourceType,
+		Created:      cu.CreatedAt,
+		LastModified: cu.UpdatedAt,
+		Location:     fmt.Sprintf("%s/%s/%s", baseURL, endpoint, id),
+	}
+}
+
+// ListResponse is the result of a query, paginated by StartIndex, counted
+// from 1
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// NewListResponse ctor
+func NewListResponse(
+	resources interface{}, count int, total int64, startIndex int,
+) ListResponse {
+	return ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: count,
+		Resources:    resources,
+	}
+}
+
+// Error is the body of unsuccessful responses
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewError ctor; scimType is one of RFC 7644 3.12 error types, or empty
+func NewError(status int, scimType, detail string) Error {
+	return Error{
+		Schemas:  []string{ErrorSchema},
+		Status:   fmt.Sprint(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
@@ -0,0 +1,158 @@
+// +build unit
+
+package scim_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/scim"
+)
+
+func TestParseFilter(t *testing.T) {
+	tt := []struct {
+		filter string
+		want   *scim.Filter
+	}{
+		{`userName eq "some.user@example.com"`, &scim.Filter{"username", "some.user@example.com"}},
+		{`displayName EQ "with \"quotes\""`, &scim.Filter{"displayname", `with "quotes"`}},
+		{`emails.value eq "a@b.com"`, &scim.Filter{"emails.value", "a@b.com"}},
+		{`userName sw "some"`, nil},
+		{`userName eq "a" and active eq true`, nil},
+		{`userName eq unquoted`, nil},
+	}
+	for _, tt := range tt {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := scim.ParseFilter(tt.filter)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("Expected error. Got %#v", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error %s", err.Error())
+			}
+			if !reflect.DeepEqual(f, tt.want) {
+				t.Errorf("Expected %#v. Got %#v", tt.want, f)
+			}
+		})
+	}
+}
+
+func TestUserApplyTo(t *testing.T) {
+	active := false
+	sa := &models.ServiceAccount{Active: true}
+	scim.User{
+		UserName: "some.user@example.com",
+		Name:     &scim.Name{GivenName: "Some", FamilyName: "User"},
+		Active:   &active,
+	}.ApplyTo(sa)
+	want := &models.ServiceAccount{
+		Name: "Some User", Email: "some.user@example.com", Active: false,
+	}
+	if !reflect.DeepEqual(sa, want) {
+		t.Errorf("Expected %#v. Got %#v", want, sa)
+	}
+}
+
+func buildPatchOp(t *testing.T, body string) scim.PatchOp {
+	t.Helper()
+	p := scim.PatchOp{}
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	return p
+}
+
+func TestPatchOpApplyToUser(t *testing.T) {
+	active := true
+	u := scim.User{
+		UserName:    "some.user@example.com",
+		DisplayName: "Some User",
+		Active:      &active,
+	}
+	p := buildPatchOp(t, `{"Operations": [
+		{"op": "Replace", "path": "active", "value": "False"},
+		{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "other@example.com"},
+		{"op": "add", "value": {"displayName": "Other User", "title": "ignored"}}
+	]}`)
+	if err := p.ApplyToUser(&u); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if *u.Active || u.DisplayName != "Other User" ||
+		len(u.Emails) != 1 || u.Emails[0].Value != "other@example.com" {
+		t.Errorf("Unexpected user after patch %#v", u)
+	}
+
+	tt := []struct {
+		body     string
+		scimType string
+	}{
+		{`{"Operations": [{"op": "move", "path": "active"}]}`, "invalidSyntax"},
+		{`{"Operations": [{"op": "remove"}]}`, "noTarget"},
+		{`{"Operations": [{"op": "replace", "path": "active", "value": "maybe"}]}`, "invalidValue"},
+	}
+	for _, tt := range tt {
+		err := buildPatchOp(t, tt.body).ApplyToUser(&u)
+		if e, ok := err.(*scim.PatchError); !ok || e.ScimType != tt.scimType {
+			t.Errorf("Expected PatchError %s for %s. Got %v", tt.scimType, tt.body, err)
+		}
+	}
+}
+
+func TestPatchOpApplyToGroup(t *testing.T) {
+	tt := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"add", `{"Operations": [{"op": "add", "path": "members",
+			"value": [{"value": "b"}, {"value": "c"}]}]}`, []string{"a", "b", "c"}},
+		{"remove", `{"Operations": [{"op": "remove", "path": "members",
+			"value": [{"value": "a"}]}]}`, []string{"b"}},
+		{"remove filtered", `{"Operations": [{"op": "remove",
+			"path": "members[value eq \"b\"]"}]}`, []string{"a"}},
+		{"remove all", `{"Operations": [{"op": "remove", "path": "members"}]}`, []string{}},
+		{"replace without path", `{"Operations": [{"op": "replace",
+			"value": {"id": "ignored", "members": [{"value": "c"}]}}]}`, []string{"c"}},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			g := scim.Group{
+				DisplayName: "developers",
+				Members:     []scim.Reference{{Value: "a"}, {Value: "b"}},
+			}
+			if err := buildPatchOp(t, tt.body).ApplyToGroup(&g); err != nil {
+				t.Fatalf("Unexpected error %s", err.Error())
+			}
+			if got := g.MembersIDs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected members %v. Got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		g := scim.Group{DisplayName: "developers"}
+		p := buildPatchOp(t, `{"Operations": [{"op": "replace",
+			"path": "displayName", "value": "engineers"}]}`)
+		if err := p.ApplyToGroup(&g); err != nil {
+			t.Fatalf("Unexpected error %s", err.Error())
+		}
+		if g.DisplayName != "engineers" {
+			t.Errorf("Expected displayName engineers. Got %s", g.DisplayName)
+		}
+	})
+
+	t.Run("unsupported path", func(t *testing.T) {
+		g := scim.Group{DisplayName: "developers"}
+		p := buildPatchOp(t, `{"Operations": [{"op": "replace",
+			"path": "owners", "value": []}]}`)
+		err := p.ApplyToGroup(&g)
+		if e, ok := err.(*scim.PatchError); !ok || e.ScimType != "invalidPath" {
+			t.Errorf("Expected PatchError invalidPath. Got %v", err)
+		}
+	})
+}
@@ -99,7 +99,25 @@ func GetServicesUseCase(t *testing.T) usecases.Services {
 // GetPermissionsRequestsUseCase returns a usecases.PermissionsRequests
 func GetPermissionsRequestsUseCase(t *testing.T) usecases.PermissionsRequests {
 	t.Helper()
-	return usecases.NewPermissionsRequests(GetRepo(t)).WithContext(context.Background())
+	prsConfig, err := usecases.GetPermissionsRequestsConfig(GetConfig(t))
+	if err != nil {
+		panic(err)
+	}
+	return usecases.NewPermissionsRequests(GetRepo(t), prsConfig).
+		WithContext(context.Background())
+}
+
+// GetAutoApprovalRulesUseCase returns a usecases.AutoApprovalRules
+func GetAutoApprovalRulesUseCase(t *testing.T) usecases.AutoApprovalRules {
+	t.Helper()
+	return usecases.NewAutoApprovalRules(GetRepo(t)).
+		WithContext(context.Background())
+}
+
+// GetHousekeepingUseCase returns a usecases.Housekeeping
+func GetHousekeepingUseCase(t *testing.T) usecases.Housekeeping {
+	t.Helper()
+	return usecases.NewHousekeeping(GetRepo(t)).WithContext(context.Background())
 }
 
 // CreateRootServiceAccountWithKeyPair creates a root service account with root access using KeyPair
@@ -165,6 +183,7 @@ func CleanupPG(t *testing.T) {
 		"permissions_requests",
 		"permissions",
 		"role_bindings",
+		"role_inclusions",
 		"roles",
 		"service_accounts",
 		"services",
@@ -174,4 +193,8 @@ func CleanupPG(t *testing.T) {
 			panic(err)
 		}
 	}
+	// audit_events rejects DELETE
+	if _, err := storage.PG.DB.Exec("TRUNCATE audit_events;"); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,99 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// AccessKeys define entrypoints for service accounts access keys actions
+type AccessKeys interface {
+	Create(string, *models.AccessKey) (*models.AccessKey, error)
+	Deactivate(string, string) error
+	List(string) ([]models.AccessKey, error)
+	WithContext(context.Context) AccessKeys
+}
+
+type accessKeys struct {
+	repo *repositories.All
+	ctx  context.Context
+}
+
+func (aks accessKeys) WithContext(ctx context.Context) AccessKeys {
+	return &accessKeys{aks.repo.WithContext(ctx), ctx}
+}
+
+// Create generates an access key for saID, labeled and expiring as
+// requested; the returned key is the only one ever carrying KeySecret
+// OAuth2 service accounts are people, so they can't get keys to be
+// impersonated with
+func (aks accessKeys) Create(
+	saID string, requested *models.AccessKey,
+) (*models.AccessKey, error) {
+	ak, err := models.BuildAccessKey(saID, requested.Label)
+	if err != nil {
+		return nil, err
+	}
+	ak.ExpiresAt = requested.ExpiresAt
+	err = aks.repo.WithPGTx(aks.ctx, func(repo *repositories.All) error {
+		sa, err := repo.ServiceAccounts.Get(saID)
+		if err != nil {
+			return err
+		}
+		if sa.AuthenticationType != models.AuthenticationTypes.KeyPair {
+			return errors.NewOAuth2ServiceAccountKeyError(saID)
+		}
+		if err := repo.AccessKeys.Create(ak); err != nil {
+			return err
+		}
+		after := *ak
+		after.KeySecret = ""
+		return recordAuditEvent(
+			aks.ctx, repo, models.AuditActions.CreateAccessKey,
+			models.AuditTargetTypes.AccessKey, ak.ID, nil, after,
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ak, nil
+}
+
+// Deactivate makes access key id of saID unusable
+func (aks accessKeys) Deactivate(saID, id string) error {
+	return aks.repo.WithPGTx(aks.ctx, func(repo *repositories.All) error {
+		before, err := repo.AccessKeys.Get(id)
+		if err != nil {
+			return err
+		}
+		if before.ServiceAccountID != saID {
+			return errors.NewEntityNotFoundError(models.AccessKey{}, id)
+		}
+		if err := repo.AccessKeys.Deactivate(id); err != nil {
+			return err
+		}
+		after, err := repo.AccessKeys.Get(id)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			aks.ctx, repo, models.AuditActions.DeactivateAccessKey,
+			models.AuditTargetTypes.AccessKey, id, before, after,
+		)
+	})
+}
+
+// List returns all access keys of saID, without their secrets
+func (aks accessKeys) List(saID string) ([]models.AccessKey, error) {
+	if _, err := aks.repo.ServiceAccounts.Get(saID); err != nil {
+		return nil, err
+	}
+	return aks.repo.AccessKeys.ForServiceAccount(saID)
+}
+
+// NewAccessKeys ctor
+func NewAccessKeys(repo *repositories.All) AccessKeys {
+	return &accessKeys{repo: repo}
+}
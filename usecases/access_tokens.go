@@ -0,0 +1,416 @@
+package usecases
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/pkg/jwt"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// AccessTokens define entrypoints for access tokens Will.IAM signs
+type AccessTokens interface {
+	Authenticate(string) (*models.AccessTokenAuth, error)
+	Introspect(string) (*models.TokenIntrospection, error)
+	Issue(string) (*models.IssuedAccessToken, error)
+	IssueForClient(string, string) (*models.IssuedAccessToken, error)
+	Issued(string) bool
+	JWKS() (*jwt.JWKS, error)
+	Revoke(string) error
+	WithContext(context.Context) AccessTokens
+}
+
+// AccessTokensConfig configures how access tokens are issued
+// TTL: how long issued access tokens last
+// KeyRotationInterval: how long a signing key signs tokens before a new
+// one is generated; keys stay published for KeyRotationInterval + TTL
+type AccessTokensConfig struct {
+	Issuer              string
+	TTL                 time.Duration
+	KeyRotationInterval time.Duration
+}
+
+func loadDefaultConfigAccessTokens(config *viper.Viper) {
+	config.SetDefault("accessTokens.issuer", "Will.IAM")
+	config.SetDefault("accessTokens.ttl", "1h")
+	config.SetDefault("accessTokens.keyRotationInterval", "24h")
+}
+
+// GetAccessTokensConfig reads AccessTokensConfig from config
+func GetAccessTokensConfig(config *viper.Viper) AccessTokensConfig {
+	loadDefaultConfigAccessTokens(config)
+	return AccessTokensConfig{
+		Issuer:              config.GetString("accessTokens.issuer"),
+		TTL:                 config.GetDuration("accessTokens.ttl"),
+		KeyRotationInterval: config.GetDuration("accessTokens.keyRotationInterval"),
+	}
+}
+
+// errRevokedAccessToken is the reason revoked access tokens are invalid
+var errRevokedAccessToken = fmt.Errorf("token revoked")
+
+// errClientAccessToken is the reason tokens issued to an OAuth2 client
+// aren't valid against Will.IAM
+var errClientAccessToken = fmt.Errorf("token issued to a client")
+
+type accessTokens struct {
+	repo       *repositories.All
+	ctx        context.Context
+	config     AccessTokensConfig
+	publicKeys *publicKeys
+}
+
+// publicKeys keeps the public keys of signing keys already loaded, since
+// signing keys never change
+type publicKeys struct {
+	mutex sync.RWMutex
+	keys  map[string]*rsa.PublicKey
+}
+
+func (ats accessTokens) WithContext(ctx context.Context) AccessTokens {
+	return &accessTokens{ats.repo.WithContext(ctx), ctx, ats.config, ats.publicKeys}
+}
+
+// NewAccessTokens ctor
+func NewAccessTokens(
+	repo *repositories.All, config AccessTokensConfig,
+) AccessTokens {
+	return &accessTokens{
+		repo:       repo,
+		config:     config,
+		publicKeys: &publicKeys{keys: map[string]*rsa.PublicKey{}},
+	}
+}
+
+// Issue signs an access token for serviceAccountID
+func (ats accessTokens) Issue(
+	serviceAccountID string,
+) (*models.IssuedAccessToken, error) {
+	return ats.issue(serviceAccountID, "")
+}
+
+// IssueForClient signs an access token for serviceAccountID whose audience
+// is clientID, the OAuth2 client it was issued to; Authenticate refuses
+// it, so the client can't use it against Will.IAM on their behalf
+func (ats accessTokens) IssueForClient(
+	serviceAccountID, clientID string,
+) (*models.IssuedAccessToken, error) {
+	return ats.issue(serviceAccountID, clientID)
+}
+
+func (ats accessTokens) issue(
+	serviceAccountID, audience string,
+) (*models.IssuedAccessToken, error) {
+	sa, err := ats.repo.ServiceAccounts.Get(serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if !sa.Active {
+		return nil, errors.NewServiceAccountInactiveError(sa.ID)
+	}
+	sk, err := ats.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := sk.RSAPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	token, err := jwt.Sign(jwt.Claims{
+		Issuer:    ats.config.Issuer,
+		Subject:   sa.ID,
+		Audience:  audience,
+		Email:     sa.Email,
+		Name:      sa.Name,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ats.config.TTL).Unix(),
+		ID:        uuid.Must(uuid.NewV4()).String(),
+	}, sk.ID, key)
+	if err != nil {
+		return nil, err
+	}
+	return &models.IssuedAccessToken{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(ats.config.TTL / time.Second),
+	}, nil
+}
+
+// signingKey returns the signing key tokens are signed with now, rotating
+// it when it's older than KeyRotationInterval
+func (ats accessTokens) signingKey() (*models.SigningKey, error) {
+	sk, err := ats.repo.SigningKeys.Latest()
+	if err == nil && ats.current(sk) {
+		return sk, nil
+	}
+	if _, ok := err.(*errors.EntityNotFoundError); err != nil && !ok {
+		return nil, err
+	}
+	err = ats.repo.WithPGTx(ats.ctx, func(repo *repositories.All) error {
+		if err := repo.SigningKeys.Lock(); err != nil {
+			return err
+		}
+		// another instance may have rotated it while we waited for the lock
+		latest, err := repo.SigningKeys.Latest()
+		if err == nil && ats.current(latest) {
+			sk = latest
+			return nil
+		}
+		if _, ok := err.(*errors.EntityNotFoundError); err != nil && !ok {
+			return err
+		}
+		if sk, err = models.BuildSigningKey(); err != nil {
+			return err
+		}
+		return repo.SigningKeys.Create(sk)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
+func (ats accessTokens) current(sk *models.SigningKey) bool {
+	return time.Since(sk.CreatedAt) < ats.config.KeyRotationInterval
+}
+
+// JWKS returns the public keys of every signing key tokens still in
+// effect may have been signed with
+func (ats accessTokens) JWKS() (*jwt.JWKS, error) {
+	if _, err := ats.signingKey(); err != nil {
+		return nil, err
+	}
+	skSl, err := ats.repo.SigningKeys.CreatedSince(time.Now().Add(
+		-(ats.config.KeyRotationInterval + ats.config.TTL),
+	))
+	if err != nil {
+		return nil, err
+	}
+	jwks := &jwt.JWKS{Keys: make([]jwt.JWK, len(skSl))}
+	for i := range skSl {
+		if jwks.Keys[i], err = skSl[i].JWK(); err != nil {
+			return nil, err
+		}
+	}
+	return jwks, nil
+}
+
+// Issued checks if accessToken claims to be issued by Will.IAM, without
+// verifying it
+func (ats accessTokens) Issued(accessToken string) bool {
+	if !jwt.IsJWT(accessToken) {
+		return false
+	}
+	claims, err := jwt.Unverified(accessToken)
+	return err == nil && claims.Issuer == ats.config.Issuer
+}
+
+// Authenticate verifies accessToken was issued by Will.IAM, not to an
+// OAuth2 client, hasn't expired nor been revoked, and belongs to an active
+// service account
+func (ats accessTokens) Authenticate(
+	accessToken string,
+) (*models.AccessTokenAuth, error) {
+	claims, sa, err := ats.verify(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Audience != "" {
+		return nil, errors.NewInvalidAccessTokenError(errClientAccessToken)
+	}
+	return &models.AccessTokenAuth{
+		ServiceAccountID: sa.ID,
+		AccessToken:      accessToken,
+		Email:            sa.Email,
+	}, nil
+}
+
+func (ats accessTokens) verify(
+	accessToken string,
+) (*jwt.Claims, *models.ServiceAccount, error) {
+	claims, err := ats.parse(accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	revoked, err := ats.repo.Tokens.JTIRevoked(claims.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, errors.NewInvalidAccessTokenError(errRevokedAccessToken)
+	}
+	sa, err := ats.repo.ServiceAccounts.Get(claims.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !sa.Active {
+		return nil, nil, errors.NewServiceAccountInactiveError(sa.ID)
+	}
+	return claims, sa, nil
+}
+
+// parse verifies accessToken signature, expiry and issuer
+func (ats accessTokens) parse(accessToken string) (*jwt.Claims, error) {
+	claims, err := jwt.Parse(accessToken, time.Now(), ats.publicKey)
+	switch err {
+	case nil:
+	case jwt.ErrMalformed, jwt.ErrUnknownKey, jwt.ErrInvalidSignature, jwt.ErrExpired:
+		return nil, errors.NewInvalidAccessTokenError(err)
+	default:
+		return nil, err
+	}
+	if claims.Issuer != ats.config.Issuer {
+		return nil, errors.NewInvalidAccessTokenError(jwt.ErrMalformed)
+	}
+	return claims, nil
+}
+
+// Introspect reports whether token, either issued by Will.IAM or by the
+// OAuth2 provider, is active, and whose it is
+func (ats accessTokens) Introspect(
+	token string,
+) (*models.TokenIntrospection, error) {
+	inactive := &models.TokenIntrospection{Active: false}
+	if ats.Issued(token) {
+		claims, sa, err := ats.verify(token)
+		if err != nil {
+			if tokenInactive(err) {
+				return inactive, nil
+			}
+			return nil, err
+		}
+		return &models.TokenIntrospection{
+			Active:           true,
+			Subject:          sa.ID,
+			ServiceAccountID: sa.ID,
+			Email:            sa.Email,
+			Audience:         claims.Audience,
+			ClientID:         claims.Audience,
+			ExpiresAt:        claims.ExpiresAt,
+			IssuedAt:         claims.IssuedAt,
+			Issuer:           claims.Issuer,
+			TokenType:        "Bearer",
+		}, nil
+	}
+	t, err := ats.repo.Tokens.Get(token)
+	if err != nil {
+		if tokenInactive(err) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if !t.ExpiredAt.IsZero() || !t.Expiry.After(time.Now()) {
+		return inactive, nil
+	}
+	sa, err := ats.repo.ServiceAccounts.ForEmail(t.Email)
+	if err != nil {
+		if tokenInactive(err) {
+			return inactive, nil
+		}
+		return nil, err
+	}
+	if !sa.Active {
+		return inactive, nil
+	}
+	return &models.TokenIntrospection{
+		Active:           true,
+		Subject:          sa.ID,
+		ServiceAccountID: sa.ID,
+		Email:            sa.Email,
+		ExpiresAt:        t.Expiry.Unix(),
+		TokenType:        t.TokenType,
+	}, nil
+}
+
+// tokenInactive checks if err means a token just isn't usable
+func tokenInactive(err error) bool {
+	switch err.(type) {
+	case *errors.EntityNotFoundError, *errors.InvalidAccessTokenError,
+		*errors.ServiceAccountInactiveError:
+		return true
+	}
+	return false
+}
+
+// tokenAuditState is what audit events keep of a revoked token, which
+// itself is never recorded
+type tokenAuditState struct {
+	ServiceAccountID string `json:"serviceAccountId,omitempty"`
+	Email            string `json:"email,omitempty"`
+}
+
+// Revoke makes token, either issued by Will.IAM or by the OAuth2 provider,
+// unusable right away; unknown, invalid or expired tokens are ignored
+func (ats accessTokens) Revoke(token string) error {
+	var claims *jwt.Claims
+	if ats.Issued(token) {
+		var err error
+		if claims, err = ats.parse(token); err != nil {
+			if tokenInactive(err) {
+				return nil
+			}
+			return err
+		}
+	}
+	return ats.repo.WithPGTx(ats.ctx, func(repo *repositories.All) error {
+		if claims != nil {
+			if err := repo.Tokens.RevokeJTI(claims.ID, claims.Expiry()); err != nil {
+				return err
+			}
+			return recordAuditEvent(
+				ats.ctx, repo, models.AuditActions.RevokeToken,
+				models.AuditTargetTypes.Token, claims.ID, nil,
+				tokenAuditState{ServiceAccountID: claims.Subject, Email: claims.Email},
+			)
+		}
+		revoked, err := repo.Tokens.Revoke(token)
+		if err != nil {
+			return err
+		}
+		for _, t := range revoked {
+			if err := recordAuditEvent(
+				ats.ctx, repo, models.AuditActions.RevokeToken,
+				models.AuditTargetTypes.Token, t.ID, nil,
+				tokenAuditState{Email: t.Email},
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ats accessTokens) publicKey(kid string) (*rsa.PublicKey, error) {
+	ats.publicKeys.mutex.RLock()
+	key, ok := ats.publicKeys.keys[kid]
+	ats.publicKeys.mutex.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if _, err := uuid.FromString(kid); err != nil {
+		return nil, jwt.ErrUnknownKey
+	}
+	sk, err := ats.repo.SigningKeys.Get(kid)
+	if _, ok := err.(*errors.EntityNotFoundError); ok {
+		return nil, jwt.ErrUnknownKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := sk.RSAPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	ats.publicKeys.mutex.Lock()
+	defer ats.publicKeys.mutex.Unlock()
+	ats.publicKeys.keys[kid] = &privateKey.PublicKey
+	return &privateKey.PublicKey, nil
+}
@@ -178,6 +178,7 @@ func (a am) listWillIAMPermissions(prefix string) ([]models.AM, error) {
 func (a am) listWillIAMActions(prefix string) ([]string, error) {
 	all := append(constants.RolesActions, constants.ServiceAccountsActions...)
 	all = append(all, constants.ServicesActions...)
+	all = append(all, constants.AuditActions...)
 	keep := []string{}
 	for i := range all {
 		if ok := strings.HasPrefix(all[i], prefix); ok {
@@ -208,6 +209,9 @@ func (a am) listWillIAMResourceHierarchies(
 	if actionsContains(constants.ServicesActions, action) {
 		return []models.AM{}, nil
 	}
+	if actionsContains(constants.AuditActions, action) {
+		return []models.AM{}, nil
+	}
 	return []models.AM{}, nil
 }
 
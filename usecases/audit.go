@@ -0,0 +1,227 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// Audit define entrypoints for audit log actions
+type Audit interface {
+	List(
+		*repositories.AuditEventsFilter, *repositories.ListOptions,
+	) ([]models.AuditEvent, int64, error)
+	Verify() (*models.AuditChainVerification, error)
+	WithContext(context.Context) Audit
+}
+
+// auditVerifyBatchSize is how many audit events Verify loads at a time
+const auditVerifyBatchSize = 1000
+
+type audit struct {
+	repo *repositories.All
+	ctx  context.Context
+}
+
+func (a audit) WithContext(ctx context.Context) Audit {
+	return &audit{a.repo.WithContext(ctx), ctx}
+}
+
+func (a audit) List(
+	f *repositories.AuditEventsFilter, lo *repositories.ListOptions,
+) ([]models.AuditEvent, int64, error) {
+	events, err := a.repo.AuditEvents.List(f, lo)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := a.repo.AuditEvents.ListCount(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	return events, count, nil
+}
+
+// Verify walks the audit hash chain, from the first chained event to the
+// last, reporting the first broken link. Events recorded before chaining
+// existed, with no hash, are skipped while no chained event was found
+func (a audit) Verify() (*models.AuditChainVerification, error) {
+	v := &models.AuditChainVerification{}
+	previousHash := ""
+	chained := false
+	var lastSeq int64
+	for {
+		events, err := a.repo.AuditEvents.ListChain(lastSeq, auditVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) == 0 {
+			v.Valid = true
+			return v, nil
+		}
+		lastSeq = events[len(events)-1].Seq
+		if !chained {
+			for len(events) > 0 && events[0].Hash == "" &&
+				events[0].PreviousHash == "" {
+				events = events[1:]
+			}
+			chained = len(events) > 0
+		}
+		var broken int
+		var reason string
+		previousHash, broken, reason = models.VerifyAuditChain(previousHash, events)
+		if broken >= 0 {
+			v.Checked += int64(broken)
+			v.BrokenAt = &events[broken]
+			v.Reason = reason
+			return v, nil
+		}
+		v.Checked += int64(len(events))
+	}
+}
+
+// NewAudit ctor
+func NewAudit(repo *repositories.All) Audit {
+	return &audit{repo: repo}
+}
+
+// AuditActor identifies who is making changes, and in which request
+type AuditActor struct {
+	ServiceAccountID string
+	RequestID        string
+}
+
+type auditActorCtxKeyType string
+
+const auditActorCtxKey = auditActorCtxKeyType("auditActor")
+
+// WithAuditActor returns a copy of ctx carrying actor, which is recorded
+// in every audit event of usecases called WithContext of it
+func WithAuditActor(ctx context.Context, actor AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorCtxKey, actor)
+}
+
+func getAuditActor(ctx context.Context) AuditActor {
+	if ctx == nil {
+		return AuditActor{}
+	}
+	actor, _ := ctx.Value(auditActorCtxKey).(AuditActor)
+	return actor
+}
+
+// recordAuditEvent appends an audit event for action over target, made
+// by the actor in ctx; before and after are JSON encoded, nil ones are
+// stored as NULL
+func recordAuditEvent(
+	ctx context.Context, repo *repositories.All,
+	action, targetType, targetID string, before, after interface{},
+) error {
+	actor := getAuditActor(ctx)
+	ae := &models.AuditEvent{
+		ActorServiceAccountID: actor.ServiceAccountID,
+		Action:                action,
+		TargetType:            targetType,
+		TargetID:              targetID,
+		RequestID:             actor.RequestID,
+	}
+	var err error
+	if ae.Before, err = marshalAuditState(before); err != nil {
+		return err
+	}
+	if ae.After, err = marshalAuditState(after); err != nil {
+		return err
+	}
+	return repo.AuditEvents.Create(ae)
+}
+
+func marshalAuditState(state interface{}) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// roleAuditState is what audit events keep of a role
+type roleAuditState struct {
+	Name               string              `json:"name"`
+	Permissions        []models.Permission `json:"permissions"`
+	IncludedRolesIDs   []string            `json:"includedRolesIds"`
+	ServiceAccountsIDs []string            `json:"serviceAccountsIds"`
+}
+
+func getRoleAuditState(
+	repo *repositories.All, roleID string,
+) (*roleAuditState, error) {
+	r, err := repo.Roles.Get(roleID)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := repo.Permissions.ForRole(roleID)
+	if err != nil {
+		return nil, err
+	}
+	irs, err := repo.Roles.GetIncludedRoles(roleID)
+	if err != nil {
+		return nil, err
+	}
+	rbs, err := repo.Roles.GetBindings(roleID)
+	if err != nil {
+		return nil, err
+	}
+	state := &roleAuditState{
+		Name:               r.Name,
+		Permissions:        ps,
+		IncludedRolesIDs:   make([]string, len(irs)),
+		ServiceAccountsIDs: make([]string, len(rbs)),
+	}
+	for i := range irs {
+		state.IncludedRolesIDs[i] = irs[i].ID
+	}
+	for i := range rbs {
+		state.ServiceAccountsIDs[i] = rbs[i].ServiceAccountID
+	}
+	return state, nil
+}
+
+// serviceAccountAuditState is what audit events keep of a service account;
+// key secrets are left out
+type serviceAccountAuditState struct {
+	Name        string              `json:"name"`
+	Email       string              `json:"email"`
+	BaseRoleID  string              `json:"baseRoleId"`
+	Active      bool                `json:"active"`
+	Permissions []models.Permission `json:"permissions"`
+	RolesIDs    []string            `json:"rolesIds"`
+}
+
+func getServiceAccountAuditState(
+	repo *repositories.All, saID string,
+) (*serviceAccountAuditState, error) {
+	sa, err := repo.ServiceAccounts.Get(saID)
+	if err != nil {
+		return nil, err
+	}
+	ps, err := repo.Permissions.ForRole(sa.BaseRoleID)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := repo.Roles.ForServiceAccountID(saID)
+	if err != nil {
+		return nil, err
+	}
+	state := &serviceAccountAuditState{
+		Name:        sa.Name,
+		Email:       sa.Email,
+		BaseRoleID:  sa.BaseRoleID,
+		Active:      sa.Active,
+		Permissions: ps,
+		RolesIDs:    []string{},
+	}
+	for i := range rs {
+		if rs[i].ID != sa.BaseRoleID {
+			state.RolesIDs = append(state.RolesIDs, rs[i].ID)
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,154 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// authorizationCodeTTL is how long clients have to redeem authorization
+// codes; RFC 6749 recommends 10 minutes at most
+const authorizationCodeTTL = time.Minute
+
+// AuthorizationCodes define entrypoints for the OAuth2 authorization code
+// flow with PKCE, whose clients are services with redirect URIs operators
+// allowed
+type AuthorizationCodes interface {
+	Client(string, string) (*models.Service, error)
+	Issue(*models.AuthorizationRequest, string) (string, error)
+	Redeem(string, string, string, string) (string, error)
+	WithContext(context.Context) AuthorizationCodes
+}
+
+// AuthorizationCodesConfig configures the authorization code flow
+// Clients: ids of the services allowed to be clients; people aren't asked
+// for consent, so registering redirect URIs isn't enough, as whoever can
+// edit a service could then get codes for anyone following a link
+type AuthorizationCodesConfig struct {
+	Clients []string
+}
+
+// GetAuthorizationCodesConfig reads AuthorizationCodesConfig from config
+func GetAuthorizationCodesConfig(config *viper.Viper) AuthorizationCodesConfig {
+	return AuthorizationCodesConfig{
+		Clients: config.GetStringSlice("authorizationCodes.clients"),
+	}
+}
+
+func (c AuthorizationCodesConfig) allows(clientID string) bool {
+	for _, id := range c.Clients {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+type authorizationCodes struct {
+	repo   *repositories.All
+	ctx    context.Context
+	config AuthorizationCodesConfig
+}
+
+func (acs authorizationCodes) WithContext(ctx context.Context) AuthorizationCodes {
+	return &authorizationCodes{acs.repo.WithContext(ctx), ctx, acs.config}
+}
+
+// Client returns the service of clientID, failing as an invalid_request if
+// there's none, it isn't an allowed client or redirectURI isn't one of its
+// redirect URIs
+func (acs authorizationCodes) Client(
+	clientID, redirectURI string,
+) (*models.Service, error) {
+	if _, err := uuid.FromString(clientID); err != nil {
+		return nil, errors.NewOAuth2Error("invalid_request", "unknown client_id")
+	}
+	if !acs.config.allows(clientID) {
+		return nil, errors.NewOAuth2Error(
+			"invalid_request", "client_id isn't an allowed client",
+		)
+	}
+	s, err := acs.repo.Services.Get(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if s.ID == "" {
+		return nil, errors.NewOAuth2Error("invalid_request", "unknown client_id")
+	}
+	if !s.AllowsRedirectURI(redirectURI) {
+		return nil, errors.NewOAuth2Error(
+			"invalid_request", "redirect_uri isn't registered by the client",
+		)
+	}
+	return s, nil
+}
+
+// Issue generates an authorization code answering ar on behalf of
+// service account saID, which must be active
+func (acs authorizationCodes) Issue(
+	ar *models.AuthorizationRequest, saID string,
+) (string, error) {
+	sa, err := acs.repo.ServiceAccounts.Get(saID)
+	if err != nil {
+		return "", err
+	}
+	if !sa.Active {
+		return "", errors.NewServiceAccountInactiveError(sa.ID)
+	}
+	ac, err := models.BuildAuthorizationCode(
+		ar, saID, time.Now().Add(authorizationCodeTTL),
+	)
+	if err != nil {
+		return "", err
+	}
+	if err := acs.repo.AuthorizationCodes.Create(ac); err != nil {
+		return "", err
+	}
+	return ac.Code, nil
+}
+
+// Redeem returns the id of the service account code was issued on behalf
+// of, failing as an invalid_grant unless code wasn't redeemed yet, isn't
+// expired, was issued to clientID for redirectURI and verifier is the
+// secret its challenge was derived from
+func (acs authorizationCodes) Redeem(
+	code, clientID, redirectURI, verifier string,
+) (string, error) {
+	ac, err := acs.repo.AuthorizationCodes.Redeem(
+		models.HashAuthorizationCode(code),
+	)
+	if _, ok := err.(*errors.EntityNotFoundError); ok {
+		return "", errors.NewOAuth2Error(
+			"invalid_grant", "code is invalid or was already redeemed",
+		)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !time.Now().Before(ac.ExpiresAt) {
+		return "", errors.NewOAuth2Error("invalid_grant", "code expired")
+	}
+	if ac.ClientID != clientID || ac.RedirectURI != redirectURI {
+		return "", errors.NewOAuth2Error(
+			"invalid_grant", "code wasn't issued to client_id for redirect_uri",
+		)
+	}
+	if !ac.VerifierMatches(verifier) {
+		return "", errors.NewOAuth2Error(
+			"invalid_grant", "code_verifier doesn't match code_challenge",
+		)
+	}
+	return ac.ServiceAccountID, nil
+}
+
+// NewAuthorizationCodes ctor
+func NewAuthorizationCodes(
+	repo *repositories.All, config AuthorizationCodesConfig,
+) AuthorizationCodes {
+	return &authorizationCodes{repo: repo, config: config}
+}
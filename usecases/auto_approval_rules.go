@@ -0,0 +1,118 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// AutoApprovalRules define entrypoints for rules granting permission
+// requests on creation
+type AutoApprovalRules interface {
+	Create(string, *models.AutoApprovalRule) error
+	Delete(string, string) error
+	List(string) ([]models.AutoApprovalRule, error)
+	WithContext(context.Context) AutoApprovalRules
+}
+
+type autoApprovalRules struct {
+	repo *repositories.All
+	ctx  context.Context
+}
+
+func (rs autoApprovalRules) WithContext(
+	ctx context.Context,
+) AutoApprovalRules {
+	return &autoApprovalRules{rs.repo.WithContext(ctx), ctx}
+}
+
+// Create stores r, made by saID, who must own r permission
+func (rs autoApprovalRules) Create(
+	saID string, r *models.AutoApprovalRule,
+) error {
+	r.CreatorServiceAccountID = saID
+	return rs.repo.WithPGTx(rs.ctx, func(repo *repositories.All) error {
+		if err := checkOwnsAutoApprovalRule(repo, saID, r); err != nil {
+			return err
+		}
+		if r.RoleID != "" {
+			if _, err := repo.Roles.Get(r.RoleID); err != nil {
+				return err
+			}
+		}
+		if err := repo.AutoApprovalRules.Create(r); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			rs.ctx, repo, models.AuditActions.CreateAutoApprovalRule,
+			models.AuditTargetTypes.AutoApprovalRule, r.ID, nil, r,
+		)
+	})
+}
+
+// Delete removes rule id, which saID must own the permission of
+func (rs autoApprovalRules) Delete(saID, id string) error {
+	return rs.repo.WithPGTx(rs.ctx, func(repo *repositories.All) error {
+		before, err := repo.AutoApprovalRules.Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkOwnsAutoApprovalRule(repo, saID, before); err != nil {
+			return err
+		}
+		if err := repo.AutoApprovalRules.Delete(id); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			rs.ctx, repo, models.AuditActions.DeleteAutoApprovalRule,
+			models.AuditTargetTypes.AutoApprovalRule, id, before, nil,
+		)
+	})
+}
+
+// List returns the rules saID owns the permission of
+func (rs autoApprovalRules) List(
+	saID string,
+) ([]models.AutoApprovalRule, error) {
+	all, err := rs.repo.AutoApprovalRules.List()
+	if err != nil {
+		return nil, err
+	}
+	rSl := []models.AutoApprovalRule{}
+	for i := range all {
+		switch err := checkOwnsAutoApprovalRule(rs.repo, saID, &all[i]); err.(type) {
+		case nil:
+			rSl = append(rSl, all[i])
+		case *errors.UserDoesntHavePermissionError:
+		default:
+			return nil, err
+		}
+	}
+	return rSl, nil
+}
+
+// checkOwnsAutoApprovalRule checks saID owns the permission r grants
+func checkOwnsAutoApprovalRule(
+	repo *repositories.All, saID string, r *models.AutoApprovalRule,
+) error {
+	p, err := models.BuildPermission(r.Permission)
+	if err != nil {
+		return err
+	}
+	p.OwnershipLevel = models.OwnershipLevels.Owner
+	has, err := repo.ServiceAccounts.HasPermission(saID, p)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return errors.NewUserDoesntHavePermissionError(p.String())
+	}
+	return nil
+}
+
+// NewAutoApprovalRules ctor
+func NewAutoApprovalRules(repo *repositories.All) AutoApprovalRules {
+	return &autoApprovalRules{repo: repo}
+}
@@ -0,0 +1,121 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// Housekeeping define entrypoints for the periodic cleanups run by the worker
+type Housekeeping interface {
+	ExpireGrants() (int, error)
+	ExpirePermissionsRequests(time.Duration) (int, error)
+	PurgeTokens(time.Duration) (int, error)
+	Stats() (*models.Stats, error)
+	WithContext(context.Context) Housekeeping
+}
+
+type housekeeping struct {
+	repo *repositories.All
+	ctx  context.Context
+}
+
+func (hk housekeeping) WithContext(ctx context.Context) Housekeeping {
+	return &housekeeping{hk.repo.WithContext(ctx), ctx}
+}
+
+// ExpireGrants removes permissions and role bindings whose time bound
+// ended, which checks already ignore, returning how many
+func (hk housekeeping) ExpireGrants() (int, error) {
+	var count int
+	err := hk.repo.WithPGTx(hk.ctx, func(repo *repositories.All) error {
+		ps, err := repo.Permissions.DeleteExpired()
+		if err != nil {
+			return err
+		}
+		for i := range ps {
+			if err := recordAuditEvent(
+				hk.ctx, repo, models.AuditActions.ExpirePermission,
+				models.AuditTargetTypes.Permission, ps[i].ID, ps[i], nil,
+			); err != nil {
+				return err
+			}
+		}
+		rbs, err := repo.Roles.UnbindExpired()
+		if err != nil {
+			return err
+		}
+		for i := range rbs {
+			if err := recordAuditEvent(
+				hk.ctx, repo, models.AuditActions.ExpireRoleBinding,
+				models.AuditTargetTypes.Role, rbs[i].RoleID, rbs[i], nil,
+			); err != nil {
+				return err
+			}
+		}
+		count = len(ps) + len(rbs)
+		return nil
+	})
+	return count, err
+}
+
+// ExpirePermissionsRequests expires requests left open for longer than ttl,
+// returning how many
+func (hk housekeeping) ExpirePermissionsRequests(
+	ttl time.Duration,
+) (int, error) {
+	var count int
+	err := hk.repo.WithPGTx(hk.ctx, func(repo *repositories.All) error {
+		var err error
+		count, err = expirePermissionsRequests(hk.ctx, repo, time.Now().Add(-ttl))
+		return err
+	})
+	return count, err
+}
+
+// PurgeTokens removes SSO tokens expired for longer than retention, along
+// with revocations and authorization codes that expired, returning how many
+// rows were removed
+func (hk housekeeping) PurgeTokens(retention time.Duration) (int, error) {
+	tokens, err := hk.repo.Tokens.PurgeExpired(time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+	jtis, err := hk.repo.Tokens.PurgeRevokedJTIs()
+	if err != nil {
+		return tokens, err
+	}
+	codes, err := hk.repo.AuthorizationCodes.DeleteExpired()
+	if err != nil {
+		return tokens + jtis, err
+	}
+	return tokens + jtis + codes, nil
+}
+
+// Stats counts service accounts, roles and open permissions requests
+func (hk housekeeping) Stats() (*models.Stats, error) {
+	sas, err := hk.repo.ServiceAccounts.ListCount()
+	if err != nil {
+		return nil, err
+	}
+	rs, err := hk.repo.Roles.ListCount()
+	if err != nil {
+		return nil, err
+	}
+	prs, err := hk.repo.PermissionsRequests.OpenCount()
+	if err != nil {
+		return nil, err
+	}
+	return &models.Stats{
+		ServiceAccounts:         sas,
+		Roles:                   rs,
+		OpenPermissionsRequests: prs,
+	}, nil
+}
+
+// NewHousekeeping ctor
+func NewHousekeeping(repo *repositories.All) Housekeeping {
+	return &housekeeping{repo: repo}
+}
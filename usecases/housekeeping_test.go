@@ -0,0 +1,121 @@
+// +build integration
+
+package usecases_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/topfreegames/Will.IAM/models"
+	helpers "github.com/topfreegames/Will.IAM/testing"
+)
+
+func TestHousekeepingExpireGrants(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	saM := &models.ServiceAccount{Name: "some name", Email: "test@domain.com"}
+	if err := saUC.Create(saM); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expired, err := models.BuildPermission("RL::Do::SomeService::x")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	expired.ExpiresAt = pg.NullTime{Time: time.Now().Add(time.Second)}
+	if err := saUC.CreatePermission(saM.ID, &expired); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	active, err := models.BuildPermission("RL::Do::SomeService::y")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := saUC.CreatePermission(saM.ID, &active); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	storage := helpers.GetStorage(t)
+	if _, err := storage.PG.DB.Exec(
+		`UPDATE permissions SET expires_at = now() - INTERVAL '1 hour'
+		WHERE id = ?`, expired.ID,
+	); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	count, err := helpers.GetHousekeepingUseCase(t).ExpireGrants()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 expired grant. Got %d", count)
+	}
+	var ids []string
+	storage.PG.DB.Query(&ids, "SELECT id FROM permissions WHERE id IN (?, ?)",
+		expired.ID, active.ID)
+	if len(ids) != 1 || ids[0] != active.ID {
+		t.Errorf("Expected only %s to remain. Got %v", active.ID, ids)
+	}
+	var events int
+	storage.PG.DB.Query(&events, `SELECT count(*) FROM audit_events
+	WHERE action = ? AND target_id = ?`,
+		models.AuditActions.ExpirePermission, expired.ID)
+	if events != 1 {
+		t.Errorf("Expected 1 audit event. Got %d", events)
+	}
+}
+
+func TestHousekeepingExpirePermissionsRequests(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	saM := &models.ServiceAccount{Name: "some name", Email: "test@domain.com"}
+	if err := saUC.Create(saM); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	prsUC := helpers.GetPermissionsRequestsUseCase(t)
+	stale := &models.PermissionRequest{
+		ServiceAccountID:  saM.ID,
+		Service:           "SomeService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            "Do",
+		ResourceHierarchy: models.BuildResourceHierarchy("x"),
+	}
+	recent := &models.PermissionRequest{
+		ServiceAccountID:  saM.ID,
+		Service:           "SomeService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            "Do",
+		ResourceHierarchy: models.BuildResourceHierarchy("y"),
+	}
+	for _, pr := range []*models.PermissionRequest{stale, recent} {
+		if err := prsUC.Create(pr); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	storage := helpers.GetStorage(t)
+	if _, err := storage.PG.DB.Exec(
+		`UPDATE permissions_requests SET created_at = now() - INTERVAL '2 days'
+		WHERE id = ?`, stale.ID,
+	); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	count, err := helpers.GetHousekeepingUseCase(t).
+		ExpirePermissionsRequests(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 expired request. Got %d", count)
+	}
+	for id, state := range map[string]models.PermissionRequestState{
+		stale.ID:  models.PermissionRequestStates.Expired,
+		recent.ID: models.PermissionRequestStates.Open,
+	} {
+		pr, err := helpers.GetRepo(t).PermissionsRequests.Get(id)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if pr.State != state {
+			t.Errorf("Expected %s to be %s. Got %s", id, state, pr.State)
+		}
+	}
+}
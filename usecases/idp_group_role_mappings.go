@@ -0,0 +1,98 @@
+package usecases
+
+import (
+	"context"
+	"strings"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// IdPGroupRoleMappings define entrypoints for mapping identity provider
+// groups to roles
+type IdPGroupRoleMappings interface {
+	Create(string, *models.IdPGroupRoleMapping) error
+	Delete(string, string) error
+	List(string) ([]models.IdPGroupRoleMapping, error)
+	WithContext(context.Context) IdPGroupRoleMappings
+}
+
+type idpGroupRoleMappings struct {
+	repo *repositories.All
+	ctx  context.Context
+}
+
+func (ms idpGroupRoleMappings) WithContext(
+	ctx context.Context,
+) IdPGroupRoleMappings {
+	return &idpGroupRoleMappings{ms.repo.WithContext(ctx), ctx}
+}
+
+// Create maps m.GroupName of m.Provider to roleID; members are bound to it
+// on their next login
+func (ms idpGroupRoleMappings) Create(
+	roleID string, m *models.IdPGroupRoleMapping,
+) error {
+	m.RoleID = roleID
+	return ms.repo.WithPGTx(ms.ctx, func(repo *repositories.All) error {
+		if _, err := repo.Roles.Get(roleID); err != nil {
+			return err
+		}
+		existing, err := repo.IdPGroupRoleMappings.ForRole(roleID)
+		if err != nil {
+			return err
+		}
+		for _, e := range existing {
+			if e.Provider == m.Provider &&
+				strings.EqualFold(e.GroupName, m.GroupName) {
+				return errors.NewDuplicateIdPGroupRoleMappingError(
+					m.Provider, m.GroupName,
+				)
+			}
+		}
+		if err := repo.IdPGroupRoleMappings.Create(m); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			ms.ctx, repo, models.AuditActions.CreateIdPGroupRoleMapping,
+			models.AuditTargetTypes.IdPGroupRoleMapping, m.ID, nil, m,
+		)
+	})
+}
+
+// Delete removes mapping id of roleID; bindings it gave are removed on
+// the next login of their service accounts
+func (ms idpGroupRoleMappings) Delete(roleID, id string) error {
+	return ms.repo.WithPGTx(ms.ctx, func(repo *repositories.All) error {
+		before, err := repo.IdPGroupRoleMappings.Get(id)
+		if err != nil {
+			return err
+		}
+		if before.RoleID != roleID {
+			return errors.NewEntityNotFoundError(models.IdPGroupRoleMapping{}, id)
+		}
+		if err := repo.IdPGroupRoleMappings.Delete(id); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			ms.ctx, repo, models.AuditActions.DeleteIdPGroupRoleMapping,
+			models.AuditTargetTypes.IdPGroupRoleMapping, id, before, nil,
+		)
+	})
+}
+
+// List returns the mappings to roleID
+func (ms idpGroupRoleMappings) List(
+	roleID string,
+) ([]models.IdPGroupRoleMapping, error) {
+	if _, err := ms.repo.Roles.Get(roleID); err != nil {
+		return nil, err
+	}
+	return ms.repo.IdPGroupRoleMappings.ForRole(roleID)
+}
+
+// NewIdPGroupRoleMappings ctor
+func NewIdPGroupRoleMappings(repo *repositories.All) IdPGroupRoleMappings {
+	return &idpGroupRoleMappings{repo: repo}
+}
@@ -2,7 +2,9 @@ package usecases
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/repositories"
 )
@@ -14,23 +16,52 @@ type Permissions interface {
 	Create(*models.Permission) error
 	Attribute(*PermissionsAttribute) error
 	AttributeToEmails(*PermissionsAttributeToEmails) error
+	Explain(string, string, models.Permission) (*models.PermissionExplanation, error)
 	WithContext(context.Context) Permissions
 }
 
 // PermissionsAttribute are used in PUT /permissions/attribute
 type PermissionsAttribute struct {
-	RolesIDs           []string            `json:"rolesIds"`
-	PermissionsStrings []string            `json:"permissions"`
-	PermissionsAliases map[string]string   `json:"permissionsAliases"`
-	Permissions        []models.Permission `json:"-"`
+	RolesIDs              []string                    `json:"rolesIds"`
+	PermissionsStrings    []string                    `json:"permissions"`
+	PermissionsAliases    map[string]string           `json:"permissionsAliases"`
+	PermissionsTimeBounds map[string]models.TimeBound `json:"permissionsTimeBounds"`
+	Permissions           []models.Permission         `json:"-"`
 }
 
 // PermissionsAttributeToEmails are used in PUT /permissions/attribute_to_emails
 type PermissionsAttributeToEmails struct {
-	Emails             []string            `json:"emails"`
-	PermissionsStrings []string            `json:"permissions"`
-	PermissionsAliases map[string]string   `json:"permissionsAliases"`
-	Permissions        []models.Permission `json:"-"`
+	Emails                []string                    `json:"emails"`
+	PermissionsStrings    []string                    `json:"permissions"`
+	PermissionsAliases    map[string]string           `json:"permissionsAliases"`
+	PermissionsTimeBounds map[string]models.TimeBound `json:"permissionsTimeBounds"`
+	Permissions           []models.Permission         `json:"-"`
+}
+
+// Validate PermissionsAttribute fields
+func (pa PermissionsAttribute) Validate() models.Validation {
+	v := &models.Validation{}
+	validateTimeBounds(v, "permissionsTimeBounds", pa.PermissionsTimeBounds)
+	return *v
+}
+
+// Validate PermissionsAttributeToEmails fields
+func (pa PermissionsAttributeToEmails) Validate() models.Validation {
+	v := &models.Validation{}
+	validateTimeBounds(v, "permissionsTimeBounds", pa.PermissionsTimeBounds)
+	return *v
+}
+
+func validateTimeBounds(
+	v *models.Validation, field string, tbs map[string]models.TimeBound,
+) {
+	for key, tb := range tbs {
+		if !tb.Valid() {
+			v.AddError(
+				fmt.Sprintf("%s.%s", field, key), "expiresAt must be after notBefore",
+			)
+		}
+	}
 }
 
 type permissions struct {
@@ -47,21 +78,49 @@ func (ps permissions) Get(id string) (*models.Permission, error) {
 }
 
 func (ps permissions) Delete(id string) error {
-	return ps.repo.Permissions.Delete(id)
+	return ps.repo.WithPGTx(ps.ctx, func(repo *repositories.All) error {
+		p, err := repo.Permissions.Get(id)
+		if err != nil {
+			return err
+		}
+		if err := repo.Permissions.Delete(id); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			ps.ctx, repo, models.AuditActions.DeletePermission,
+			models.AuditTargetTypes.Permission, id, p, nil,
+		)
+	})
 }
 
 func (ps permissions) Create(p *models.Permission) error {
-	return ps.repo.Permissions.Create(p)
+	return ps.repo.WithPGTx(ps.ctx, func(repo *repositories.All) error {
+		if err := repo.Permissions.Create(p); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			ps.ctx, repo, models.AuditActions.CreatePermission,
+			models.AuditTargetTypes.Permission, p.ID, nil, p,
+		)
+	})
 }
 
 func (ps permissions) Attribute(pa *PermissionsAttribute) error {
 	return ps.repo.WithPGTx(ps.ctx, func(repo *repositories.All) error {
 		for _, roleID := range pa.RolesIDs {
-			for _, permission := range pa.Permissions {
+			created := make([]models.Permission, len(pa.Permissions))
+			for i, permission := range pa.Permissions {
 				permission.RoleID = roleID
 				if err := repo.Permissions.Create(&permission); err != nil {
 					return err
 				}
+				created[i] = permission
+			}
+			if err := recordAuditEvent(
+				ps.ctx, repo, models.AuditActions.AttributePermissions,
+				models.AuditTargetTypes.Role, roleID, nil, created,
+			); err != nil {
+				return err
 			}
 		}
 		return nil
@@ -75,17 +134,60 @@ func (ps permissions) AttributeToEmails(pa *PermissionsAttributeToEmails) error
 	}
 	return ps.repo.WithPGTx(ps.ctx, func(repo *repositories.All) error {
 		for _, sa := range sas {
-			for _, permission := range pa.Permissions {
+			created := make([]models.Permission, len(pa.Permissions))
+			for i, permission := range pa.Permissions {
 				permission.RoleID = sa.BaseRoleID
 				if err := repo.Permissions.Create(&permission); err != nil {
 					return err
 				}
+				created[i] = permission
+			}
+			if err := recordAuditEvent(
+				ps.ctx, repo, models.AuditActions.AttributePermissionsToEmails,
+				models.AuditTargetTypes.ServiceAccount, sa.ID, nil, created,
+			); err != nil {
+				return err
 			}
 		}
 		return nil
 	})
 }
 
+// Explain tells why saID has, or lacks, permission. requesterID must be
+// saID itself, an owner of permission or able to EditServiceAccount saID
+func (ps permissions) Explain(
+	requesterID, saID string, permission models.Permission,
+) (*models.PermissionExplanation, error) {
+	if requesterID != saID {
+		ownerPermission := permission
+		ownerPermission.OwnershipLevel = models.OwnershipLevels.Owner
+		editPermission, err := models.BuildPermission(
+			models.BuildWillIAMPermissionLender("EditServiceAccount", saID),
+		)
+		if err != nil {
+			return nil, err
+		}
+		has, err := serviceAccountHasPermissions(
+			ps.repo, requesterID, []models.Permission{ownerPermission, editPermission},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if !has[0] && !has[1] {
+			return nil, errors.NewUserDoesntHavePermissionError(editPermission.String())
+		}
+	}
+	grants, err := ps.repo.Permissions.GrantsForServiceAccount(
+		saID, permission.Service,
+	)
+	if err != nil {
+		return nil, err
+	}
+	pe := models.ExplainPermission(permission, grants)
+	pe.ServiceAccountID = saID
+	return &pe, nil
+}
+
 // NewPermissions ctor
 func NewPermissions(repo *repositories.All) Permissions {
 	return &permissions{repo: repo}
@@ -3,32 +3,97 @@ package usecases
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/go-pg/pg"
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/repositories"
 )
 
 // PermissionsRequests define entrypoints for PermissionsRequests actions
 type PermissionsRequests interface {
+	Cancel(saID string, prID string) error
 	Create(*models.PermissionRequest) error
 	Deny(saID string, prID string) error
-	Grant(saID string, prID string) error
+	Grant(saID string, prID string, duration time.Duration) error
+	ListForServiceAccount(
+		*repositories.ListOptions, string,
+	) ([]models.PermissionRequest, int64, error)
 	ListOpenRequestsVisibleTo(
 		*repositories.ListOptions, string,
 	) ([]models.PermissionRequest, int64, error)
 	WithContext(context.Context) PermissionsRequests
 }
 
+// PermissionsRequestsConfig configures permissions requests
+// TTL: how long requests stay open before expiring; zero keeps them open
+// until moderated or cancelled
+// ApprovalPolicies: permissions that need more than one owner approving
+// requests for them
+type PermissionsRequestsConfig struct {
+	TTL              time.Duration
+	ApprovalPolicies []models.ApprovalPolicy
+}
+
+func loadDefaultConfigPermissionsRequests(config *viper.Viper) {
+	config.SetDefault("permissionsRequests.ttl", "720h")
+}
+
+// GetPermissionsRequestsConfig reads PermissionsRequestsConfig from config;
+// approval policies are listed as {permission, approvals} pairs
+func GetPermissionsRequestsConfig(
+	config *viper.Viper,
+) (PermissionsRequestsConfig, error) {
+	loadDefaultConfigPermissionsRequests(config)
+	prc := PermissionsRequestsConfig{
+		TTL: config.GetDuration("permissionsRequests.ttl"),
+	}
+	policies := []struct {
+		Permission string
+		Approvals  int
+	}{}
+	if err := config.UnmarshalKey(
+		"permissionsRequests.approvalPolicies", &policies,
+	); err != nil {
+		return PermissionsRequestsConfig{}, err
+	}
+	for _, p := range policies {
+		ap, err := models.BuildApprovalPolicy(p.Permission, p.Approvals)
+		if err != nil {
+			return PermissionsRequestsConfig{}, err
+		}
+		prc.ApprovalPolicies = append(prc.ApprovalPolicies, ap)
+	}
+	return prc, nil
+}
+
 type permissionsRequests struct {
-	repo *repositories.All
-	ctx  context.Context
+	repo   *repositories.All
+	ctx    context.Context
+	config PermissionsRequestsConfig
 }
 
 func (prs permissionsRequests) WithContext(ctx context.Context) PermissionsRequests {
-	return &permissionsRequests{prs.repo.WithContext(ctx), ctx}
+	return &permissionsRequests{prs.repo.WithContext(ctx), ctx, prs.config}
+}
+
+// expireStale expires requests past TTL, which the worker may not have
+// expired yet, so they can't be moderated nor keep equal ones from opening
+func (prs permissionsRequests) expireStale(repo *repositories.All) error {
+	if prs.config.TTL <= 0 {
+		return nil
+	}
+	_, err := expirePermissionsRequests(
+		prs.ctx, repo, time.Now().Add(-prs.config.TTL),
+	)
+	return err
 }
 
 // Create checks if pr.saID has open request OR has permission, if not it opens a permission request
+// A request an auto-approval rule applies to is granted right away, with the
+// rule ID as its moderator
 func (prs permissionsRequests) Create(pr *models.PermissionRequest) error {
 	return prs.repo.WithPGTx(prs.ctx, func(repo *repositories.All) error {
 		pr.State = models.PermissionRequestStates.Open
@@ -39,7 +104,135 @@ func (prs permissionsRequests) Create(pr *models.PermissionRequest) error {
 			// TODO(ghostec): replace by proper error
 			return fmt.Errorf("user already has requested permission")
 		}
-		return repo.PermissionsRequests.Create(pr)
+		if err := prs.expireStale(repo); err != nil {
+			return err
+		}
+		if err := repo.PermissionsRequests.Create(pr); err != nil {
+			return err
+		}
+		if pr.ID == "" {
+			// an equal request was already open
+			return nil
+		}
+		if err := recordAuditEvent(
+			prs.ctx, repo, models.AuditActions.CreatePermissionRequest,
+			models.AuditTargetTypes.PermissionRequest, pr.ID, nil, pr,
+		); err != nil {
+			return err
+		}
+		rule, err := prs.autoApprovalRule(repo, pr)
+		if err != nil || rule == nil {
+			return err
+		}
+		duration := time.Duration(pr.Duration)
+		if rule.Duration > 0 {
+			duration = time.Duration(rule.Duration)
+		}
+		if err := grantPermissionRequest(
+			prs.ctx, repo, pr, rule.ID, duration,
+		); err != nil {
+			return err
+		}
+		pr.State = models.PermissionRequestStates.Granted
+		pr.ModeratorServiceAccountID = rule.ID
+		return nil
+	})
+}
+
+// autoApprovalRule finds a rule granting pr: one covering the permission
+// requested and accepting its requester, whose creator still owns that
+// permission. Requests approval policies ask several owners for are left
+// to them
+func (prs permissionsRequests) autoApprovalRule(
+	repo *repositories.All, pr *models.PermissionRequest,
+) (*models.AutoApprovalRule, error) {
+	p := pr.Permission()
+	if models.RequiredApprovals(prs.config.ApprovalPolicies, p) > 1 {
+		return nil, nil
+	}
+	rules, err := repo.AutoApprovalRules.List()
+	if err != nil {
+		return nil, err
+	}
+	covering := []models.AutoApprovalRule{}
+	for _, r := range rules {
+		if r.Covers(p) {
+			covering = append(covering, r)
+		}
+	}
+	if len(covering) == 0 {
+		return nil, nil
+	}
+	sa, err := repo.ServiceAccounts.Get(pr.ServiceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	rolesIDs, err := boundRolesIDs(repo, pr.ServiceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	ownerPermission := p
+	ownerPermission.OwnershipLevel = models.OwnershipLevels.Owner
+	for i := range covering {
+		if !covering[i].Accepts(sa.VerifiedHostedDomain, rolesIDs) {
+			continue
+		}
+		has, err := repo.ServiceAccounts.HasPermission(
+			covering[i].CreatorServiceAccountID, ownerPermission,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			return &covering[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// boundRolesIDs returns ids of the roles saID bindings in effect are to,
+// along with the ones they include
+func boundRolesIDs(repo *repositories.All, saID string) ([]string, error) {
+	rbs, err := repo.Roles.BindingsForServiceAccountID(saID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rolesIDs := []string{}
+	for _, rb := range rbs {
+		if rb.Active(now) {
+			rolesIDs = append(rolesIDs, rb.RoleID)
+		}
+	}
+	included, err := repo.Roles.IncludedRolesIDs(rolesIDs)
+	if err != nil {
+		return nil, err
+	}
+	return append(rolesIDs, included...), nil
+}
+
+// Cancel closes prID, which only saID, its requester, can do
+func (prs permissionsRequests) Cancel(saID, prID string) error {
+	return prs.repo.WithPGTx(prs.ctx, func(repo *repositories.All) error {
+		if err := prs.expireStale(repo); err != nil {
+			return err
+		}
+		pr, err := repo.PermissionsRequests.GetForUpdate(prID)
+		if err != nil {
+			return err
+		}
+		if pr.ServiceAccountID != saID {
+			return errors.NewNotPermissionRequesterError(prID)
+		}
+		if pr.State != models.PermissionRequestStates.Open {
+			return errors.NewPermissionRequestClosedError(prID)
+		}
+		if err := repo.PermissionsRequests.Cancel(prID); err != nil {
+			return err
+		}
+		return recordPermissionRequestAuditEvent(
+			prs.ctx, repo, models.AuditActions.CancelPermissionRequest, pr,
+		)
 	})
 }
 
@@ -47,13 +240,15 @@ func (prs permissionsRequests) Create(pr *models.PermissionRequest) error {
 // requested in prID, and if so will DENY it to the pr.ServiceAccountID base role
 func (prs permissionsRequests) Deny(saID, prID string) error {
 	return prs.repo.WithPGTx(prs.ctx, func(repo *repositories.All) error {
-		pr, err := repo.PermissionsRequests.Get(prID)
+		if err := prs.expireStale(repo); err != nil {
+			return err
+		}
+		pr, err := repo.PermissionsRequests.GetForUpdate(prID)
 		if err != nil {
 			return err
 		}
 		if pr.State != models.PermissionRequestStates.Open {
-			// TODO(ghostec): replace by proper error
-			return fmt.Errorf("permission request is closed")
+			return errors.NewPermissionRequestClosedError(prID)
 		}
 		ownerPermission := pr.Permission()
 		ownerPermission.OwnershipLevel = models.OwnershipLevels.Owner
@@ -65,21 +260,39 @@ func (prs permissionsRequests) Deny(saID, prID string) error {
 			// TODO(ghostec): replace by proper error
 			return fmt.Errorf("user isn't owner of permission")
 		}
-		return repo.PermissionsRequests.Deny(saID, prID)
+		if err := repo.PermissionsRequests.Deny(saID, prID); err != nil {
+			return err
+		}
+		return recordPermissionRequestAuditEvent(
+			prs.ctx, repo, models.AuditActions.DenyPermissionRequest, pr,
+		)
 	})
 }
 
 // Grant will check if saID (moderator_service_account_id) is owner of the permission
 // requested in prID, and if so will GRANT it to the pr.ServiceAccountID base role
-func (prs permissionsRequests) Grant(saID, prID string) error {
+// Requesters can't grant their own requests. When an approval policy asks for
+// more owners, saID approval is recorded and the permission is only granted
+// once enough distinct owners approved it; approvals of service accounts that
+// no longer own the permission don't count
+// The permission expires after the shortest duration approvers chose or, if
+// none did, the duration requested; it doesn't expire when neither is set
+func (prs permissionsRequests) Grant(
+	saID, prID string, duration time.Duration,
+) error {
 	return prs.repo.WithPGTx(prs.ctx, func(repo *repositories.All) error {
-		pr, err := repo.PermissionsRequests.Get(prID)
+		if err := prs.expireStale(repo); err != nil {
+			return err
+		}
+		pr, err := repo.PermissionsRequests.GetForUpdate(prID)
 		if err != nil {
 			return err
 		}
 		if pr.State != models.PermissionRequestStates.Open {
-			// TODO(ghostec): replace by proper error
-			return fmt.Errorf("permission request is closed")
+			return errors.NewPermissionRequestClosedError(prID)
+		}
+		if pr.ServiceAccountID == saID {
+			return errors.NewSelfApprovalError(prID)
 		}
 		ownerPermission := pr.Permission()
 		ownerPermission.OwnershipLevel = models.OwnershipLevels.Owner
@@ -91,14 +304,120 @@ func (prs permissionsRequests) Grant(saID, prID string) error {
 			// TODO(ghostec): replace by proper error
 			return fmt.Errorf("user isn't owner of permission")
 		}
-		p := pr.Permission()
-		if err := createPermissionForServiceAccount(repo, pr.ServiceAccountID, &p); err != nil {
+		a := &models.PermissionRequestApproval{
+			PermissionRequestID: prID,
+			ServiceAccountID:    saID,
+			Duration:            models.Duration(duration),
+		}
+		if err := repo.PermissionsRequests.Approve(a); err != nil {
+			return err
+		}
+		approvals, err := ownersApprovals(repo, prID, ownerPermission)
+		if err != nil {
 			return err
 		}
-		return repo.PermissionsRequests.Grant(saID, prID)
+		required := models.RequiredApprovals(
+			prs.config.ApprovalPolicies, pr.Permission(),
+		)
+		if len(approvals) < required {
+			if a.ID == "" {
+				// saID had already approved it
+				return nil
+			}
+			return recordAuditEvent(
+				prs.ctx, repo, models.AuditActions.ApprovePermissionRequest,
+				models.AuditTargetTypes.PermissionRequest, prID, nil, a,
+			)
+		}
+		return grantPermissionRequest(
+			prs.ctx, repo, pr, saID, grantDuration(pr, approvals),
+		)
 	})
 }
 
+// grantPermissionRequest gives pr permission to its requester, expiring
+// after duration unless it's zero, and closes pr as granted by moderatorID
+func grantPermissionRequest(
+	ctx context.Context, repo *repositories.All, pr *models.PermissionRequest,
+	moderatorID string, duration time.Duration,
+) error {
+	p := pr.Permission()
+	if duration > 0 {
+		p.ExpiresAt = pg.NullTime{Time: time.Now().Add(duration)}
+	}
+	if err := createPermissionForServiceAccount(repo, pr.ServiceAccountID, &p); err != nil {
+		return err
+	}
+	if err := repo.PermissionsRequests.Grant(
+		moderatorID, pr.ID, models.Duration(duration),
+	); err != nil {
+		return err
+	}
+	return recordPermissionRequestAuditEvent(
+		ctx, repo, models.AuditActions.GrantPermissionRequest, pr,
+	)
+}
+
+// ownersApprovals returns approvals of prID made by service accounts still
+// holding ownerPermission
+func ownersApprovals(
+	repo *repositories.All, prID string, ownerPermission models.Permission,
+) ([]models.PermissionRequestApproval, error) {
+	approvals, err := repo.PermissionsRequests.Approvals(prID)
+	if err != nil {
+		return nil, err
+	}
+	owners := []models.PermissionRequestApproval{}
+	for _, a := range approvals {
+		has, err := repo.ServiceAccounts.HasPermission(
+			a.ServiceAccountID, ownerPermission,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if has {
+			owners = append(owners, a)
+		}
+	}
+	return owners, nil
+}
+
+// grantDuration returns the shortest duration approvals chose, or pr
+// requested one if none did
+func grantDuration(
+	pr *models.PermissionRequest, approvals []models.PermissionRequestApproval,
+) time.Duration {
+	var shortest time.Duration
+	for _, a := range approvals {
+		d := time.Duration(a.Duration)
+		if d > 0 && (shortest == 0 || d < shortest) {
+			shortest = d
+		}
+	}
+	if shortest > 0 {
+		return shortest
+	}
+	return time.Duration(pr.Duration)
+}
+
+// recordPermissionRequestAuditEvent records action over before, which
+// is reloaded to be the after state
+func recordPermissionRequestAuditEvent(
+	ctx context.Context, repo *repositories.All,
+	action string, before *models.PermissionRequest,
+) error {
+	after, err := repo.PermissionsRequests.Get(before.ID)
+	if err != nil {
+		return err
+	}
+	return recordAuditEvent(
+		ctx, repo, action, models.AuditTargetTypes.PermissionRequest,
+		before.ID, before, after,
+	)
+}
+
+// ListOpenRequestsVisibleTo lists open requests saID owns the permission of,
+// with how many approvals each has and needs
 func (prs permissionsRequests) ListOpenRequestsVisibleTo(
 	lo *repositories.ListOptions, saID string,
 ) ([]models.PermissionRequest, int64, error) {
@@ -106,6 +425,11 @@ func (prs permissionsRequests) ListOpenRequestsVisibleTo(
 	if err != nil {
 		return nil, 0, err
 	}
+	for i := range ors {
+		ors[i].RequiredApprovals = models.RequiredApprovals(
+			prs.config.ApprovalPolicies, ors[i].Permission(),
+		)
+	}
 	count, err := prs.repo.PermissionsRequests.ListOpenRequestsVisibleToCount(saID)
 	if err != nil {
 		return nil, 0, err
@@ -113,7 +437,47 @@ func (prs permissionsRequests) ListOpenRequestsVisibleTo(
 	return ors, count, nil
 }
 
+// ListForServiceAccount lists requests made by saID, whatever their state
+func (prs permissionsRequests) ListForServiceAccount(
+	lo *repositories.ListOptions, saID string,
+) ([]models.PermissionRequest, int64, error) {
+	prSl, err := prs.repo.PermissionsRequests.ListForServiceAccount(lo, saID)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := prs.repo.PermissionsRequests.ListForServiceAccountCount(saID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return prSl, count, nil
+}
+
+// expirePermissionsRequests expires requests still open that were made
+// before createdBefore, returning how many
+func expirePermissionsRequests(
+	ctx context.Context, repo *repositories.All, createdBefore time.Time,
+) (int, error) {
+	prSl, err := repo.PermissionsRequests.ExpireOpen(createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	for i := range prSl {
+		before := prSl[i]
+		before.State = models.PermissionRequestStates.Open
+		if err := recordAuditEvent(
+			ctx, repo, models.AuditActions.ExpirePermissionRequest,
+			models.AuditTargetTypes.PermissionRequest, prSl[i].ID,
+			before, prSl[i],
+		); err != nil {
+			return 0, err
+		}
+	}
+	return len(prSl), nil
+}
+
 // NewPermissionsRequests ctor
-func NewPermissionsRequests(repo *repositories.All) PermissionsRequests {
-	return &permissionsRequests{repo: repo}
+func NewPermissionsRequests(
+	repo *repositories.All, config PermissionsRequestsConfig,
+) PermissionsRequests {
+	return &permissionsRequests{repo: repo, config: config}
 }
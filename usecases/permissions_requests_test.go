@@ -3,7 +3,9 @@
 package usecases_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/repositories"
@@ -132,6 +134,59 @@ func TestPermissionsRequestsCreateDuplicate(t *testing.T) {
 	}
 }
 
+func TestPermissionsRequestsCreateWhenEqualExpired(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	saM := &models.ServiceAccount{
+		Name:  "some name",
+		Email: "test@domain.com",
+	}
+	if err := saUC.Create(saM); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	prsUC := usecases.NewPermissionsRequests(
+		helpers.GetRepo(t), usecases.PermissionsRequestsConfig{TTL: time.Hour},
+	).WithContext(context.Background())
+	build := func() *models.PermissionRequest {
+		return &models.PermissionRequest{
+			ServiceAccountID:  saM.ID,
+			Service:           "SomeService",
+			OwnershipLevel:    models.OwnershipLevels.Lender,
+			Action:            "Do",
+			ResourceHierarchy: models.BuildResourceHierarchy("x::y"),
+		}
+	}
+	stale := build()
+	if err := prsUC.Create(stale); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	storage := helpers.GetStorage(t)
+	if _, err := storage.PG.DB.Exec(
+		`UPDATE permissions_requests SET created_at = now() - INTERVAL '2 hours'
+		WHERE id = ?`, stale.ID,
+	); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	if err := prsUC.Grant(rootSA.ID, stale.ID, 0); err == nil || err.Error() != "permission request is closed" {
+		t.Error("Expected error to be 'permission request is closed'")
+	}
+	pr := build()
+	if err := prsUC.Create(pr); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if pr.ID == "" {
+		t.Fatal("Expected a new request to be opened")
+	}
+	got, err := helpers.GetRepo(t).PermissionsRequests.Get(stale.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if got.State != models.PermissionRequestStates.Expired {
+		t.Errorf("Expected State to be expired. Got %s", got.State)
+	}
+}
+
 func TestPermissionsRequestsCreateWhenAlreadyHasPermission(t *testing.T) {
 	helpers.CleanupPG(t)
 	saUC := helpers.GetServiceAccountsUseCase(t)
@@ -422,7 +477,7 @@ func TestPermissionsRequestsGrant(t *testing.T) {
 		return
 	}
 	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
-	if err := prsUC.Grant(rootSA.ID, pr.ID); err != nil {
+	if err := prsUC.Grant(rootSA.ID, pr.ID, 0); err != nil {
 		t.Errorf("Unexpected error: %s", err.Error())
 		return
 	}
@@ -561,6 +616,200 @@ func TestPermissionsRequestsDeny(t *testing.T) {
 	}
 }
 
+func TestPermissionsRequestsGrantForDuration(t *testing.T) {
+	tt := []struct {
+		name      string
+		requested time.Duration
+		granted   time.Duration
+		expected  time.Duration
+	}{
+		{"requested", 8 * time.Hour, 0, 8 * time.Hour},
+		{"chosen by moderator", 8 * time.Hour, time.Hour, time.Hour},
+		{"permanent", 0, 0, 0},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.CleanupPG(t)
+			saUC := helpers.GetServiceAccountsUseCase(t)
+			saM := &models.ServiceAccount{
+				Name:  "some name",
+				Email: "test@domain.com",
+			}
+			if err := saUC.Create(saM); err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			prsUC := helpers.GetPermissionsRequestsUseCase(t)
+			pr := &models.PermissionRequest{
+				ServiceAccountID:  saM.ID,
+				Service:           "SomeService",
+				OwnershipLevel:    models.OwnershipLevels.Lender,
+				Action:            "Do",
+				ResourceHierarchy: models.BuildResourceHierarchy("x::y"),
+				Duration:          models.Duration(tt.requested),
+			}
+			if err := prsUC.Create(pr); err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+			before := time.Now()
+			if err := prsUC.Grant(rootSA.ID, pr.ID, tt.granted); err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			repo := helpers.GetRepo(t)
+			ps, err := repo.Permissions.ForServiceAccount(saM.ID)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			if len(ps) != 1 {
+				t.Fatalf("Expected 1 permission. Got %d", len(ps))
+			}
+			if tt.expected == 0 {
+				if !ps[0].ExpiresAt.IsZero() {
+					t.Errorf("Expected permission not to expire. Got %s", ps[0].ExpiresAt)
+				}
+			} else if ps[0].ExpiresAt.Before(before.Add(tt.expected)) ||
+				ps[0].ExpiresAt.After(time.Now().Add(tt.expected)) {
+				t.Errorf("Expected permission to expire in %s. Got %s", tt.expected, ps[0].ExpiresAt)
+			}
+			got, err := repo.PermissionsRequests.Get(pr.ID)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			if time.Duration(got.Duration) != tt.expected {
+				t.Errorf("Expected Duration to be %s. Got %s", tt.expected, time.Duration(got.Duration))
+			}
+		})
+	}
+}
+
+func TestPermissionsRequestsGrantWithApprovalPolicy(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	saM := &models.ServiceAccount{
+		Name:  "some name",
+		Email: "test@domain.com",
+	}
+	if err := saUC.Create(saM); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	policy, err := models.BuildApprovalPolicy("SomeService::RL::Do::*", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	prsUC := usecases.NewPermissionsRequests(
+		helpers.GetRepo(t), usecases.PermissionsRequestsConfig{
+			ApprovalPolicies: []models.ApprovalPolicy{policy},
+		},
+	).WithContext(context.Background())
+	pr := &models.PermissionRequest{
+		ServiceAccountID:  saM.ID,
+		Service:           "SomeService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            "Do",
+		ResourceHierarchy: models.BuildResourceHierarchy("x::y"),
+	}
+	if err := prsUC.Create(pr); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if err := prsUC.Grant(saM.ID, pr.ID, 0); err == nil {
+		t.Error("Expected requester not to be able to grant their own request")
+	}
+	first := helpers.CreateRootServiceAccountWithKeyPair(t, "first", "first@test.com")
+	second := helpers.CreateRootServiceAccountWithKeyPair(t, "second", "second@test.com")
+	sasUC := helpers.GetServiceAccountsUseCase(t)
+	for _, approver := range []*models.ServiceAccount{first, first} {
+		if err := prsUC.Grant(approver.ID, pr.ID, 0); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		has, err := sasUC.HasPermissionString(saM.ID, pr.Permission().String())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if has {
+			t.Fatal("Expected saM to NOT have permission before 2 approvals")
+		}
+	}
+	ors, _, err := prsUC.ListOpenRequestsVisibleTo(&repositories.ListOptions{}, second.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(ors) != 1 || ors[0].Approvals != 1 || ors[0].RequiredApprovals != 2 {
+		t.Fatalf("Expected 1 open request with 1 of 2 approvals. Got %#v", ors)
+	}
+	if err := prsUC.Grant(second.ID, pr.ID, 0); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	has, err := sasUC.HasPermissionString(saM.ID, pr.Permission().String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if !has {
+		t.Error("Expected saM to have permission after 2 approvals")
+	}
+	approvals, err := helpers.GetRepo(t).PermissionsRequests.Approvals(pr.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(approvals) != 2 {
+		t.Errorf("Expected 2 approvals. Got %d", len(approvals))
+	}
+}
+
+func TestPermissionsRequestsGrantWithApprovalPolicyWhenApproverIsNoLongerOwner(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	saM := &models.ServiceAccount{
+		Name:  "some name",
+		Email: "test@domain.com",
+	}
+	if err := saUC.Create(saM); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	policy, err := models.BuildApprovalPolicy("SomeService::RL::Do::*", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	prsUC := usecases.NewPermissionsRequests(
+		helpers.GetRepo(t), usecases.PermissionsRequestsConfig{
+			ApprovalPolicies: []models.ApprovalPolicy{policy},
+		},
+	).WithContext(context.Background())
+	pr := &models.PermissionRequest{
+		ServiceAccountID:  saM.ID,
+		Service:           "SomeService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            "Do",
+		ResourceHierarchy: models.BuildResourceHierarchy("x::y"),
+	}
+	if err := prsUC.Create(pr); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	first := helpers.CreateRootServiceAccountWithKeyPair(t, "first", "first@test.com")
+	second := helpers.CreateRootServiceAccountWithKeyPair(t, "second", "second@test.com")
+	third := helpers.CreateRootServiceAccountWithKeyPair(t, "third", "third@test.com")
+	if err := prsUC.Grant(first.ID, pr.ID, 0); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if _, err := helpers.GetStorage(t).PG.DB.Exec(
+		"DELETE FROM permissions WHERE role_id = ?", first.BaseRoleID,
+	); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	sasUC := helpers.GetServiceAccountsUseCase(t)
+	for i, approver := range []*models.ServiceAccount{second, third} {
+		if err := prsUC.Grant(approver.ID, pr.ID, 0); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		has, err := sasUC.HasPermissionString(saM.ID, pr.Permission().String())
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+		if expected := i == 1; has != expected {
+			t.Errorf("Expected saM having permission after %s approved to be %t", approver.Name, expected)
+		}
+	}
+}
+
 func TestPermissionsRequestsGrantWhenPRIsNotOpen(t *testing.T) {
 	helpers.CleanupPG(t)
 	saUC := helpers.GetServiceAccountsUseCase(t)
@@ -589,7 +838,7 @@ func TestPermissionsRequestsGrantWhenPRIsNotOpen(t *testing.T) {
 		t.Errorf("Unexpected error: %s", err.Error())
 		return
 	}
-	if err := prsUC.Grant(rootSA.ID, pr.ID); err == nil || err.Error() != "permission request is closed" {
+	if err := prsUC.Grant(rootSA.ID, pr.ID, 0); err == nil || err.Error() != "permission request is closed" {
 		t.Error("Expected error to be 'permission request is closed'")
 		return
 	}
@@ -648,3 +897,121 @@ func TestPermissionsRequestsDenyWhenPRIsNotOpen(t *testing.T) {
 		return
 	}
 }
+
+func TestPermissionsRequestsCancelAndDenyLeaveClosedPRs(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	saM := &models.ServiceAccount{
+		Name:  "some name",
+		Email: "test@domain.com",
+	}
+	if err := saUC.Create(saM); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	prsUC := helpers.GetPermissionsRequestsUseCase(t)
+	pr := &models.PermissionRequest{
+		ServiceAccountID:  saM.ID,
+		Service:           "SomeService",
+		OwnershipLevel:    models.OwnershipLevels.Lender,
+		Action:            "Do",
+		ResourceHierarchy: models.BuildResourceHierarchy("x::y"),
+		Message:           "Please I need it",
+	}
+	if err := prsUC.Create(pr); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+	if err := prsUC.Grant(rootSA.ID, pr.ID, 0); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	// as a cancellation or denial that read pr before it was granted would
+	repo := helpers.GetRepo(t)
+	if err := repo.PermissionsRequests.Cancel(pr.ID); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	if err := repo.PermissionsRequests.Deny(rootSA.ID, pr.ID); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	got, err := repo.PermissionsRequests.Get(pr.ID)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	if got.State != models.PermissionRequestStates.Granted {
+		t.Errorf("Expected state to be %s. Got %s", models.PermissionRequestStates.Granted, got.State)
+	}
+}
+
+func TestPermissionsRequestsCreateWithAutoApprovalRule(t *testing.T) {
+	tt := []struct {
+		name    string
+		email   string
+		domain  string
+		level   models.OwnershipLevel
+		granted bool
+	}{
+		{"matching", "test@domain.com", "domain.com", models.OwnershipLevels.Lender, true},
+		{"other domain", "test@other.com", "other.com", models.OwnershipLevels.Lender, false},
+		{"unverified domain", "test@domain.com", "", models.OwnershipLevels.Lender, false},
+		{"higher level", "test@domain.com", "domain.com", models.OwnershipLevels.Owner, false},
+	}
+	for _, tt := range tt {
+		t.Run(tt.name, func(t *testing.T) {
+			helpers.CleanupPG(t)
+			rootSA := helpers.CreateRootServiceAccountWithKeyPair(t, "rootSAKeyPair", "rootSAKeyPair@test.com")
+			rule := &models.AutoApprovalRule{
+				Permission:   "SomeService::RL::Do::*",
+				HostedDomain: "domain.com",
+			}
+			if err := helpers.GetAutoApprovalRulesUseCase(t).Create(rootSA.ID, rule); err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			saUC := helpers.GetServiceAccountsUseCase(t)
+			saM := &models.ServiceAccount{Name: "some name", Email: tt.email}
+			if err := saUC.Create(saM); err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			if err := saUC.SetVerifiedHostedDomain(saM.ID, tt.domain); err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			pr := &models.PermissionRequest{
+				ServiceAccountID:  saM.ID,
+				Service:           "SomeService",
+				OwnershipLevel:    tt.level,
+				Action:            "Do",
+				ResourceHierarchy: models.BuildResourceHierarchy("x::y"),
+			}
+			if err := helpers.GetPermissionsRequestsUseCase(t).Create(pr); err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			repo := helpers.GetRepo(t)
+			got, err := repo.PermissionsRequests.Get(pr.ID)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			has, err := repo.ServiceAccounts.HasPermission(saM.ID, pr.Permission())
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err.Error())
+			}
+			if has != tt.granted {
+				t.Errorf("Expected HasPermission to be %t", tt.granted)
+			}
+			if !tt.granted {
+				if got.State != models.PermissionRequestStates.Open {
+					t.Errorf("Expected State to be open. Got %s", got.State)
+				}
+				return
+			}
+			if got.State != models.PermissionRequestStates.Granted {
+				t.Errorf("Expected State to be granted. Got %s", got.State)
+			}
+			if got.ModeratorServiceAccountID != rule.ID {
+				t.Errorf("Expected moderator to be rule %s. Got %s", rule.ID, got.ModeratorServiceAccountID)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package usecases
 import (
 	"context"
 
+	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/repositories"
 )
@@ -37,6 +38,9 @@ func (rs roles) Create(rwn *RoleWithNested) error {
 			return err
 		}
 		rwn.ID = role.ID
+		if err := includeRoles(repo, role.ID, rwn.IncludedRolesIDs); err != nil {
+			return err
+		}
 		for i := range rwn.Permissions {
 			rwn.Permissions[i].RoleID = role.ID
 			if err := createPermission(repo, &rwn.Permissions[i]); err != nil {
@@ -47,31 +51,75 @@ func (rs roles) Create(rwn *RoleWithNested) error {
 			if err := repo.Roles.Bind(&models.RoleBinding{
 				RoleID:           role.ID,
 				ServiceAccountID: rwn.ServiceAccountsIDs[i],
+				TimeBound:        rwn.ServiceAccountsTimeBounds[rwn.ServiceAccountsIDs[i]],
 			}); err != nil {
 				return err
 			}
 		}
-		return nil
+		after, err := getRoleAuditState(repo, role.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			rs.ctx, repo, models.AuditActions.CreateRole,
+			models.AuditTargetTypes.Role, role.ID, nil, after,
+		)
 	})
 }
 
 func (rs roles) CreatePermission(roleID string, p *models.Permission) error {
 	p.RoleID = roleID
-	return createPermission(rs.repo, p)
+	return rs.repo.WithPGTx(rs.ctx, func(repo *repositories.All) error {
+		if err := createPermission(repo, p); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			rs.ctx, repo, models.AuditActions.CreatePermission,
+			models.AuditTargetTypes.Permission, p.ID, nil, p,
+		)
+	})
 }
 
 func createPermission(repo *repositories.All, p *models.Permission) error {
 	return repo.Permissions.Create(p)
 }
 
+// includeRoles makes roleID include every role in includedRolesIDs, failing
+// if roleID would end up including itself
+func includeRoles(
+	repo *repositories.All, roleID string, includedRolesIDs []string,
+) error {
+	transitive, err := repo.Roles.IncludedRolesIDs(includedRolesIDs)
+	if err != nil {
+		return err
+	}
+	for _, id := range append(transitive, includedRolesIDs...) {
+		if id == roleID {
+			return errors.NewRoleInclusionCycleError(roleID)
+		}
+	}
+	for _, id := range includedRolesIDs {
+		if err := repo.Roles.Include(&models.RoleInclusion{
+			RoleID:         roleID,
+			IncludedRoleID: id,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // RoleWithNested is the required data to update a role
 type RoleWithNested struct {
-	ID                 string              `json:"-"`
-	Name               string              `json:"name"`
-	PermissionsStrings []string            `json:"permissions"`
-	PermissionsAliases map[string]string   `json:"permissionsAliases"`
-	Permissions        []models.Permission `json:"-"`
-	ServiceAccountsIDs []string            `json:"serviceAccountsIds"`
+	ID                        string                      `json:"-"`
+	Name                      string                      `json:"name"`
+	PermissionsStrings        []string                    `json:"permissions"`
+	PermissionsAliases        map[string]string           `json:"permissionsAliases"`
+	PermissionsTimeBounds     map[string]models.TimeBound `json:"permissionsTimeBounds"`
+	Permissions               []models.Permission         `json:"-"`
+	IncludedRolesIDs          []string                    `json:"includedRolesIds"`
+	ServiceAccountsIDs        []string                    `json:"serviceAccountsIds"`
+	ServiceAccountsTimeBounds map[string]models.TimeBound `json:"serviceAccountsTimeBounds"`
 }
 
 // Validate RoleWithNested fields
@@ -80,11 +128,23 @@ func (rwn RoleWithNested) Validate() models.Validation {
 	if rwn.Name == "" {
 		v.AddError("name", "required")
 	}
+	validateTimeBounds(v, "permissionsTimeBounds", rwn.PermissionsTimeBounds)
+	validateTimeBounds(v, "serviceAccountsTimeBounds", rwn.ServiceAccountsTimeBounds)
 	return *v
 }
 
 func (rs roles) Update(rwn *RoleWithNested) error {
 	return rs.repo.WithPGTx(rs.ctx, func(repo *repositories.All) error {
+		before, err := getRoleAuditState(repo, rwn.ID)
+		if err != nil {
+			return err
+		}
+		if err := repo.Roles.DropInclusions(rwn.ID); err != nil {
+			return err
+		}
+		if err := includeRoles(repo, rwn.ID, rwn.IncludedRolesIDs); err != nil {
+			return err
+		}
 		if err := repo.Roles.DropPermissions(rwn.ID); err != nil {
 			return err
 		}
@@ -94,6 +154,14 @@ func (rs roles) Update(rwn *RoleWithNested) error {
 				return err
 			}
 		}
+		rbs, err := repo.Roles.GetBindings(rwn.ID)
+		if err != nil {
+			return err
+		}
+		fromProvider := map[string]string{}
+		for _, rb := range rbs {
+			fromProvider[rb.ServiceAccountID] = rb.FromProvider
+		}
 		if err := repo.Roles.DropBindings(rwn.ID); err != nil {
 			return err
 		}
@@ -101,12 +169,24 @@ func (rs roles) Update(rwn *RoleWithNested) error {
 			if err := repo.Roles.Bind(&models.RoleBinding{
 				RoleID:           rwn.ID,
 				ServiceAccountID: rwn.ServiceAccountsIDs[i],
+				FromProvider:     fromProvider[rwn.ServiceAccountsIDs[i]],
+				TimeBound:        rwn.ServiceAccountsTimeBounds[rwn.ServiceAccountsIDs[i]],
 			}); err != nil {
 				return err
 			}
 		}
 		role := &models.Role{ID: rwn.ID, Name: rwn.Name}
-		return repo.Roles.Update(role)
+		if err := repo.Roles.Update(role); err != nil {
+			return err
+		}
+		after, err := getRoleAuditState(repo, rwn.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			rs.ctx, repo, models.AuditActions.UpdateRole,
+			models.AuditTargetTypes.Role, rwn.ID, before, after,
+		)
 	})
 }
 
@@ -165,6 +245,7 @@ func (rs roles) Get(id string) (map[string]interface{}, error) {
 		return nil, err
 	}
 	permissionsAliases := map[string]string{}
+	permissionsTimeBounds := map[string]models.TimeBound{}
 	permissions := make([]string, len(pSl))
 	for i := range pSl {
 		str := pSl[i].String()
@@ -172,6 +253,9 @@ func (rs roles) Get(id string) (map[string]interface{}, error) {
 		if pSl[i].Alias != "" {
 			permissionsAliases[str] = pSl[i].Alias
 		}
+		if pSl[i].Bounded() {
+			permissionsTimeBounds[str] = pSl[i].TimeBound
+		}
 	}
 	sas, err := rs.GetServiceAccounts(id)
 	if err != nil {
@@ -186,12 +270,36 @@ func (rs roles) Get(id string) (map[string]interface{}, error) {
 			"email":   sa.Email,
 		}
 	}
+	included, err := rs.repo.Roles.GetIncludedRoles(id)
+	if err != nil {
+		return nil, err
+	}
+	includedFiltered := make([]map[string]interface{}, len(included))
+	for i, ir := range included {
+		includedFiltered[i] = map[string]interface{}{
+			"id":   ir.ID,
+			"name": ir.Name,
+		}
+	}
+	rbs, err := rs.repo.Roles.GetBindings(id)
+	if err != nil {
+		return nil, err
+	}
+	sasTimeBounds := map[string]models.TimeBound{}
+	for _, rb := range rbs {
+		if rb.Bounded() {
+			sasTimeBounds[rb.ServiceAccountID] = rb.TimeBound
+		}
+	}
 	return map[string]interface{}{
-		"id":                 r.ID,
-		"name":               r.Name,
-		"permissions":        permissions,
-		"permissionsAliases": permissionsAliases,
-		"serviceAccounts":    sasFiltered,
+		"id":                        r.ID,
+		"name":                      r.Name,
+		"permissions":               permissions,
+		"permissionsAliases":        permissionsAliases,
+		"permissionsTimeBounds":     permissionsTimeBounds,
+		"includedRoles":             includedFiltered,
+		"serviceAccounts":           sasFiltered,
+		"serviceAccountsTimeBounds": sasTimeBounds,
 	}, nil
 }
 
@@ -5,6 +5,7 @@ package usecases_test
 import (
 	"testing"
 
+	"github.com/topfreegames/Will.IAM/errors"
 	"github.com/topfreegames/Will.IAM/models"
 	helpers "github.com/topfreegames/Will.IAM/testing"
 	"github.com/topfreegames/Will.IAM/usecases"
@@ -46,3 +47,126 @@ func TestRolesCreatePermission(t *testing.T) {
 		t.Errorf("Expected permission to be %s. Got %s", pStr, ps[0].String())
 	}
 }
+
+func TestRolesIncludedRolesPermissions(t *testing.T) {
+	helpers.CleanupPG(t)
+	rsUC := helpers.GetRolesUseCase(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	viewerPs, err := models.BuildPermissions([]string{"Maestro::RL::ListSchedulers::*"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	viewer := &usecases.RoleWithNested{Name: "maestro-viewer", Permissions: viewerPs}
+	if err := rsUC.Create(viewer); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	editor := &usecases.RoleWithNested{
+		Name: "maestro-editor", IncludedRolesIDs: []string{viewer.ID},
+	}
+	if err := rsUC.Create(editor); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	sa, err := saUC.CreateKeyPairType("some sa")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	admin := &usecases.RoleWithNested{
+		Name:               "maestro-admin",
+		IncludedRolesIDs:   []string{editor.ID},
+		ServiceAccountsIDs: []string{sa.ID},
+	}
+	if err := rsUC.Create(admin); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	has, err := saUC.HasPermissionString(sa.ID, "Maestro::RL::ListSchedulers::NA")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if !has {
+		t.Fatalf("Expected service account to have permission from included roles")
+	}
+	ps, err := saUC.GetPermissions(sa.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(ps) != 1 || ps[0].RoleID != viewer.ID {
+		t.Fatalf("Expected 1 permission from role %s. Got %v", viewer.ID, ps)
+	}
+}
+
+func TestRolesUpdateRejectsInclusionCycle(t *testing.T) {
+	helpers.CleanupPG(t)
+	rsUC := helpers.GetRolesUseCase(t)
+	viewer := &usecases.RoleWithNested{Name: "maestro-viewer"}
+	if err := rsUC.Create(viewer); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	editor := &usecases.RoleWithNested{
+		Name: "maestro-editor", IncludedRolesIDs: []string{viewer.ID},
+	}
+	if err := rsUC.Create(editor); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	admin := &usecases.RoleWithNested{
+		Name: "maestro-admin", IncludedRolesIDs: []string{editor.ID},
+	}
+	if err := rsUC.Create(admin); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	for _, includedRoleID := range []string{viewer.ID, admin.ID} {
+		viewer.IncludedRolesIDs = []string{includedRoleID}
+		err := rsUC.Update(viewer)
+		if _, ok := err.(*errors.RoleInclusionCycleError); !ok {
+			t.Fatalf("Expected RoleInclusionCycleError. Got %v", err)
+		}
+	}
+	included, err := helpers.GetRepo(t).Roles.GetIncludedRoles(viewer.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(included) != 0 {
+		t.Fatalf("Expected viewer to include no roles. Got %v", included)
+	}
+}
+
+func TestRolesUpdateKeepsProviderOfBindings(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	fromGroup := &models.ServiceAccount{Name: "from group", Email: "group@domain.com"}
+	manual := &models.ServiceAccount{Name: "manual", Email: "manual@domain.com"}
+	for _, sa := range []*models.ServiceAccount{fromGroup, manual} {
+		if err := saUC.Create(sa); err != nil {
+			t.Fatalf("Unexpected error: %s", err.Error())
+		}
+	}
+	rsUC := helpers.GetRolesUseCase(t)
+	rwn := &usecases.RoleWithNested{Name: "developers"}
+	if err := rsUC.Create(rwn); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	repo := helpers.GetRepo(t)
+	if err := repo.Roles.Bind(&models.RoleBinding{
+		RoleID: rwn.ID, ServiceAccountID: fromGroup.ID, FromProvider: "ldap",
+	}); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+
+	rwn.ServiceAccountsIDs = []string{fromGroup.ID, manual.ID}
+	if err := rsUC.Update(rwn); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	rbs, err := repo.Roles.GetBindings(rwn.ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	want := map[string]string{fromGroup.ID: "ldap", manual.ID: ""}
+	if len(rbs) != len(want) {
+		t.Fatalf("Expected %d bindings. Got %d", len(want), len(rbs))
+	}
+	for _, rb := range rbs {
+		if rb.FromProvider != want[rb.ServiceAccountID] {
+			t.Errorf("Expected binding of %s from %q. Got %q", rb.ServiceAccountID, want[rb.ServiceAccountID], rb.FromProvider)
+		}
+	}
+}
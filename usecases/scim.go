@@ -0,0 +1,351 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/topfreegames/Will.IAM/errors"
+	"github.com/topfreegames/Will.IAM/models"
+	"github.com/topfreegames/Will.IAM/repositories"
+)
+
+// SCIM define entrypoints for identity providers provisioning people, as
+// OAuth2 type service accounts, and groups, as roles created through SCIM;
+// roles made otherwise, and bindings of key pair service accounts, are
+// out of their reach
+type SCIM interface {
+	CreateGroup(*models.Role, []string) error
+	CreateUser(*models.ServiceAccount) error
+	DeleteGroup(string) error
+	DeleteUser(string) error
+	GetGroup(string) (*models.Role, []models.ServiceAccount, error)
+	GetUser(string) (*models.ServiceAccount, []models.Role, error)
+	GroupForName(string) (*models.Role, error)
+	GroupNameTaken(string, string) (bool, error)
+	ListGroups(*repositories.ListOptions) ([]models.Role, int64, error)
+	ListUsers(*repositories.ListOptions) ([]models.ServiceAccount, int64, error)
+	UpdateGroup(*models.Role, []string) error
+	UpdateUser(*models.ServiceAccount) error
+	UserForEmail(string) (*models.ServiceAccount, error)
+	WithContext(context.Context) SCIM
+}
+
+type scim struct {
+	repo *repositories.All
+	ctx  context.Context
+}
+
+func (s scim) WithContext(ctx context.Context) SCIM {
+	return &scim{s.repo.WithContext(ctx), ctx}
+}
+
+// getUser gets service account id, failing as not found for key pair ones
+func getUser(
+	repo *repositories.All, id string,
+) (*models.ServiceAccount, error) {
+	sa, err := repo.ServiceAccounts.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if sa.KeyID != "" {
+		return nil, errors.NewEntityNotFoundError(models.ServiceAccount{}, id)
+	}
+	sa.AuthenticationType = models.AuthenticationTypes.OAuth2
+	return sa, nil
+}
+
+// getGroup gets role id, failing as not found for roles not created
+// through SCIM
+func getGroup(repo *repositories.All, id string) (*models.Role, error) {
+	r, err := repo.Roles.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if r.IsBaseRole || !r.SCIMManaged {
+		return nil, errors.NewEntityNotFoundError(models.Role{}, id)
+	}
+	return r, nil
+}
+
+// CreateUser creates sa, deactivated right away if it isn't Active
+func (s scim) CreateUser(sa *models.ServiceAccount) error {
+	active := sa.Active
+	sa.AuthenticationType = models.AuthenticationTypes.OAuth2
+	return s.repo.WithPGTx(s.ctx, func(repo *repositories.All) error {
+		if err := createServiceAccount(sa, repo); err != nil {
+			return err
+		}
+		if !active {
+			if err := repo.ServiceAccounts.SetActive(sa.ID, false); err != nil {
+				return err
+			}
+			sa.Active = false
+		}
+		after, err := getServiceAccountAuditState(repo, sa.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			s.ctx, repo, models.AuditActions.CreateServiceAccount,
+			models.AuditTargetTypes.ServiceAccount, sa.ID, nil, after,
+		)
+	})
+}
+
+// UpdateUser sets the name, email and active of service account sa.ID to
+// the ones of sa
+func (s scim) UpdateUser(sa *models.ServiceAccount) error {
+	return s.repo.WithPGTx(s.ctx, func(repo *repositories.All) error {
+		before, err := getServiceAccountAuditState(repo, sa.ID)
+		if err != nil {
+			return err
+		}
+		current, err := getUser(repo, sa.ID)
+		if err != nil {
+			return err
+		}
+		current.Name = sa.Name
+		current.Email = sa.Email
+		if err := repo.ServiceAccounts.Update(current); err != nil {
+			return err
+		}
+		if current.Active != sa.Active {
+			if err := repo.ServiceAccounts.SetActive(sa.ID, sa.Active); err != nil {
+				return err
+			}
+		}
+		after, err := getServiceAccountAuditState(repo, sa.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			s.ctx, repo, models.AuditActions.UpdateServiceAccount,
+			models.AuditTargetTypes.ServiceAccount, sa.ID, before, after,
+		)
+	})
+}
+
+// DeleteUser deletes service account id as ServiceAccounts.Delete does
+func (s scim) DeleteUser(id string) error {
+	return s.repo.WithPGTx(s.ctx, func(repo *repositories.All) error {
+		if _, err := getUser(repo, id); err != nil {
+			return err
+		}
+		return deleteServiceAccount(s.ctx, repo, id)
+	})
+}
+
+// GetUser returns service account id along with the roles bound to it
+func (s scim) GetUser(
+	id string,
+) (*models.ServiceAccount, []models.Role, error) {
+	sa, err := getUser(s.repo, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	rs, err := s.repo.Roles.ForServiceAccountID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sa, rs, nil
+}
+
+// UserForEmail returns the OAuth2 type service account of email
+func (s scim) UserForEmail(email string) (*models.ServiceAccount, error) {
+	sa, err := s.repo.ServiceAccounts.ForEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if sa.KeyID != "" {
+		return nil, errors.NewEntityNotFoundError(models.ServiceAccount{}, email)
+	}
+	return sa, nil
+}
+
+// ListUsers lists OAuth2 type service accounts
+func (s scim) ListUsers(
+	lo *repositories.ListOptions,
+) ([]models.ServiceAccount, int64, error) {
+	saSl, err := s.repo.ServiceAccounts.ListOAuth2Type(lo)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := s.repo.ServiceAccounts.ListOAuth2TypeCount()
+	if err != nil {
+		return nil, 0, err
+	}
+	return saSl, count, nil
+}
+
+// CreateGroup creates r, managed by SCIM, with the service accounts
+// membersIDs bound to it
+func (s scim) CreateGroup(r *models.Role, membersIDs []string) error {
+	r.SCIMManaged = true
+	return s.repo.WithPGTx(s.ctx, func(repo *repositories.All) error {
+		if err := repo.Roles.Create(r); err != nil {
+			return err
+		}
+		for _, saID := range membersIDs {
+			if _, err := getUser(repo, saID); err != nil {
+				return err
+			}
+			if err := repo.Roles.Bind(&models.RoleBinding{
+				RoleID: r.ID, ServiceAccountID: saID,
+			}); err != nil {
+				return err
+			}
+		}
+		after, err := getRoleAuditState(repo, r.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			s.ctx, repo, models.AuditActions.CreateRole,
+			models.AuditTargetTypes.Role, r.ID, nil, after,
+		)
+	})
+}
+
+// UpdateGroup renames role r.ID to r.Name and makes membersIDs the OAuth2
+// type service accounts bound to it; bindings of the ones that already
+// were are kept as they are, time bounds included, and key pair ones are
+// never touched
+func (s scim) UpdateGroup(r *models.Role, membersIDs []string) error {
+	return s.repo.WithPGTx(s.ctx, func(repo *repositories.All) error {
+		before, err := getRoleAuditState(repo, r.ID)
+		if err != nil {
+			return err
+		}
+		current, err := getGroup(repo, r.ID)
+		if err != nil {
+			return err
+		}
+		if current.Name != r.Name {
+			current.Name = r.Name
+			if err := repo.Roles.Update(current); err != nil {
+				return err
+			}
+		}
+		wanted := map[string]bool{}
+		for _, saID := range membersIDs {
+			wanted[saID] = true
+		}
+		members, err := repo.Roles.GetServiceAccounts(r.ID)
+		if err != nil {
+			return err
+		}
+		for _, sa := range members {
+			if wanted[sa.ID] || sa.KeyID != "" {
+				delete(wanted, sa.ID)
+				continue
+			}
+			if err := repo.Roles.Unbind(r.ID, sa.ID); err != nil {
+				return err
+			}
+		}
+		for saID := range wanted {
+			if _, err := getUser(repo, saID); err != nil {
+				return err
+			}
+			if err := repo.Roles.Bind(&models.RoleBinding{
+				RoleID: r.ID, ServiceAccountID: saID,
+			}); err != nil {
+				return err
+			}
+		}
+		after, err := getRoleAuditState(repo, r.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			s.ctx, repo, models.AuditActions.UpdateRole,
+			models.AuditTargetTypes.Role, r.ID, before, after,
+		)
+	})
+}
+
+// DeleteGroup deletes role id, along with its permissions and bindings
+func (s scim) DeleteGroup(id string) error {
+	return s.repo.WithPGTx(s.ctx, func(repo *repositories.All) error {
+		if _, err := getGroup(repo, id); err != nil {
+			return err
+		}
+		before, err := getRoleAuditState(repo, id)
+		if err != nil {
+			return err
+		}
+		if err := repo.Roles.Delete(id); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			s.ctx, repo, models.AuditActions.DeleteRole,
+			models.AuditTargetTypes.Role, id, before, nil,
+		)
+	})
+}
+
+// GetGroup returns role id along with the OAuth2 type service accounts
+// bound to it
+func (s scim) GetGroup(
+	id string,
+) (*models.Role, []models.ServiceAccount, error) {
+	r, err := getGroup(s.repo, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	members, err := s.repo.Roles.GetServiceAccounts(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	sas := []models.ServiceAccount{}
+	for _, sa := range members {
+		if sa.KeyID == "" {
+			sas = append(sas, sa)
+		}
+	}
+	return r, sas, nil
+}
+
+// GroupForName returns the role named name, if created through SCIM
+func (s scim) GroupForName(name string) (*models.Role, error) {
+	r, err := s.repo.Roles.ForName(name)
+	if err != nil {
+		return nil, err
+	}
+	if !r.SCIMManaged {
+		return nil, errors.NewEntityNotFoundError(models.Role{}, name)
+	}
+	return r, nil
+}
+
+// GroupNameTaken tells if a role other than id, whether created through
+// SCIM or not, is named name
+func (s scim) GroupNameTaken(name, id string) (bool, error) {
+	r, err := s.repo.Roles.ForName(name)
+	if _, ok := err.(*errors.EntityNotFoundError); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return r.ID != id, nil
+}
+
+// ListGroups lists roles created through SCIM
+func (s scim) ListGroups(
+	lo *repositories.ListOptions,
+) ([]models.Role, int64, error) {
+	rsSl, err := s.repo.Roles.ListSCIMManaged(lo)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := s.repo.Roles.ListSCIMManagedCount()
+	if err != nil {
+		return nil, 0, err
+	}
+	return rsSl, count, nil
+}
+
+// NewSCIM ctor
+func NewSCIM(repo *repositories.All) SCIM {
+	return &scim{repo: repo}
+}
@@ -3,6 +3,8 @@ package usecases
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/topfreegames/Will.IAM/errors"
@@ -20,6 +22,7 @@ type ServiceAccounts interface {
 	CreateOAuth2Type(string, string) (*models.ServiceAccount, error)
 	CreatePermission(string, *models.Permission) error
 	CreateWithNested(*ServiceAccountWithNested) error
+	Delete(string) error
 	ForEmail(string) (*models.ServiceAccount, error)
 	Get(string) (*models.ServiceAccount, error)
 	GetPermissions(string) ([]models.Permission, error)
@@ -39,6 +42,9 @@ type ServiceAccounts interface {
 	Search(
 		string, *repositories.ListOptions,
 	) ([]models.ServiceAccount, int64, error)
+	SetActive(string, bool) error
+	SetVerifiedHostedDomain(string, string) error
+	SyncGroupRoles(string, string, []string) error
 	WithContext(context.Context) ServiceAccounts
 }
 
@@ -67,16 +73,21 @@ func NewServiceAccounts(
 
 // ServiceAccountWithNested is the required data to update a role
 type ServiceAccountWithNested struct {
-	ID                 string                    `json:"id"`
-	Name               string                    `json:"name"`
-	Email              string                    `json:"email"`
-	Picture            string                    `json:"picture"`
-	PermissionsStrings []string                  `json:"permissions"`
-	PermissionsAliases map[string]string         `json:"permissionsAliases"`
-	Permissions        []models.Permission       `json:"-"`
-	RolesIDs           []string                  `json:"rolesIds,omitempty"`
-	Roles              []models.Role             `json:"roles"`
-	AuthenticationType models.AuthenticationType `json:"authenticationType"`
+	ID                    string                      `json:"id"`
+	Name                  string                      `json:"name"`
+	Email                 string                      `json:"email"`
+	Picture               string                      `json:"picture"`
+	PermissionsStrings    []string                    `json:"permissions"`
+	PermissionsAliases    map[string]string           `json:"permissionsAliases"`
+	PermissionsTimeBounds map[string]models.TimeBound `json:"permissionsTimeBounds"`
+	Permissions           []models.Permission         `json:"-"`
+	RolesIDs              []string                    `json:"rolesIds,omitempty"`
+	RolesTimeBounds       map[string]models.TimeBound `json:"rolesTimeBounds"`
+	Roles                 []models.Role               `json:"roles"`
+	AuthenticationType    models.AuthenticationType   `json:"authenticationType"`
+	Active                bool                        `json:"active"`
+	KeyID                 string                      `json:"keyId,omitempty"`
+	KeySecret             string                      `json:"keySecret,omitempty"`
 }
 
 // Validate ServiceAccountWithNested fields
@@ -92,6 +103,8 @@ func (sawn ServiceAccountWithNested) Validate() models.Validation {
 		sawn.Email == "" {
 		v.AddError("email", "required")
 	}
+	validateTimeBounds(v, "permissionsTimeBounds", sawn.PermissionsTimeBounds)
+	validateTimeBounds(v, "rolesTimeBounds", sawn.RolesTimeBounds)
 	return *v
 }
 
@@ -112,10 +125,14 @@ func (sas serviceAccounts) CreateWithNested(
 			}
 		}
 		sawn.ID = sa.ID
+		sawn.Active = sa.Active
+		sawn.KeyID = sa.KeyID
+		sawn.KeySecret = sa.KeySecret
 		for i := range sawn.RolesIDs {
 			if err := repo.Roles.Bind(&models.RoleBinding{
 				ServiceAccountID: sawn.ID,
 				RoleID:           sawn.RolesIDs[i],
+				TimeBound:        sawn.RolesTimeBounds[sawn.RolesIDs[i]],
 			}); err != nil {
 				return err
 			}
@@ -126,7 +143,14 @@ func (sas serviceAccounts) CreateWithNested(
 				return err
 			}
 		}
-		return nil
+		after, err := getServiceAccountAuditState(repo, sa.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			sas.ctx, repo, models.AuditActions.CreateServiceAccount,
+			models.AuditTargetTypes.ServiceAccount, sa.ID, nil, after,
+		)
 	})
 }
 
@@ -134,6 +158,10 @@ func (sas serviceAccounts) UpdateWithNested(
 	sawn *ServiceAccountWithNested,
 ) error {
 	return sas.repo.WithPGTx(sas.ctx, func(repo *repositories.All) error {
+		before, err := getServiceAccountAuditState(repo, sawn.ID)
+		if err != nil {
+			return err
+		}
 		sa, err := repo.ServiceAccounts.Get(sawn.ID)
 		if err != nil {
 			return err
@@ -143,6 +171,14 @@ func (sas serviceAccounts) UpdateWithNested(
 		if err := repo.ServiceAccounts.Update(sa); err != nil {
 			return err
 		}
+		rbs, err := repo.Roles.BindingsForServiceAccountID(sawn.ID)
+		if err != nil {
+			return err
+		}
+		fromProvider := map[string]string{}
+		for _, rb := range rbs {
+			fromProvider[rb.RoleID] = rb.FromProvider
+		}
 		if err := repo.ServiceAccounts.DropBindings(sawn.ID); err != nil {
 			return err
 		}
@@ -153,6 +189,8 @@ func (sas serviceAccounts) UpdateWithNested(
 			if err := repo.Roles.Bind(&models.RoleBinding{
 				ServiceAccountID: sa.ID,
 				RoleID:           roleID,
+				FromProvider:     fromProvider[roleID],
+				TimeBound:        sawn.RolesTimeBounds[roleID],
 			}); err != nil {
 				return err
 			}
@@ -166,10 +204,180 @@ func (sas serviceAccounts) UpdateWithNested(
 				return err
 			}
 		}
+		after, err := getServiceAccountAuditState(repo, sa.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			sas.ctx, repo, models.AuditActions.UpdateServiceAccount,
+			models.AuditTargetTypes.ServiceAccount, sa.ID, before, after,
+		)
+	})
+}
+
+// SetActive activates or deactivates serviceAccountID; deactivated service
+// accounts keep their roles and permissions, but can't authenticate
+func (sas serviceAccounts) SetActive(serviceAccountID string, active bool) error {
+	return sas.repo.WithPGTx(sas.ctx, func(repo *repositories.All) error {
+		before, err := getServiceAccountAuditState(repo, serviceAccountID)
+		if err != nil {
+			return err
+		}
+		if err := repo.ServiceAccounts.SetActive(serviceAccountID, active); err != nil {
+			return err
+		}
+		after, err := getServiceAccountAuditState(repo, serviceAccountID)
+		if err != nil {
+			return err
+		}
+		action := models.AuditActions.DeactivateServiceAccount
+		if active {
+			action = models.AuditActions.ActivateServiceAccount
+		}
+		return recordAuditEvent(
+			sas.ctx, repo, action, models.AuditTargetTypes.ServiceAccount,
+			serviceAccountID, before, after,
+		)
+	})
+}
+
+// SetVerifiedHostedDomain records hostedDomain, which an OAuth2 provider
+// vouched serviceAccountID belongs to when it logged in; auto approval rules
+// match requesters by it
+func (sas serviceAccounts) SetVerifiedHostedDomain(
+	serviceAccountID, hostedDomain string,
+) error {
+	return sas.repo.ServiceAccounts.SetVerifiedHostedDomain(
+		serviceAccountID, hostedDomain,
+	)
+}
+
+// SyncGroupRoles reconciles the bindings serviceAccountID got from its
+// groups at provider with the roles provider maps them to, compared case
+// insensitively: missing ones are created and the ones provider made to
+// roles none of groups map to anymore are removed. Bindings granted
+// manually, or made from groups of other providers, are left alone
+func (sas serviceAccounts) SyncGroupRoles(
+	serviceAccountID, provider string, groups []string,
+) error {
+	if provider == "" {
+		// manual bindings have no provider, they must never be synced
 		return nil
+	}
+	inGroup := map[string]bool{}
+	for _, g := range groups {
+		inGroup[strings.ToLower(g)] = true
+	}
+	return sas.repo.WithPGTx(sas.ctx, func(repo *repositories.All) error {
+		ms, err := repo.IdPGroupRoleMappings.ForProvider(provider)
+		if err != nil {
+			return err
+		}
+		wanted := map[string]bool{}
+		for _, m := range ms {
+			if inGroup[strings.ToLower(m.GroupName)] {
+				wanted[m.RoleID] = true
+			}
+		}
+		rbs, err := repo.Roles.BindingsForServiceAccountID(serviceAccountID)
+		if err != nil {
+			return err
+		}
+		before, err := getServiceAccountAuditState(repo, serviceAccountID)
+		if err != nil {
+			return err
+		}
+		changed := false
+		bound := map[string]bool{}
+		for _, rb := range rbs {
+			bound[rb.RoleID] = true
+			if rb.FromProvider != provider || wanted[rb.RoleID] {
+				continue
+			}
+			if err := repo.Roles.Unbind(rb.RoleID, serviceAccountID); err != nil {
+				return err
+			}
+			changed = true
+		}
+		for roleID := range wanted {
+			if bound[roleID] {
+				continue
+			}
+			if err := repo.Roles.Bind(&models.RoleBinding{
+				RoleID:           roleID,
+				ServiceAccountID: serviceAccountID,
+				FromProvider:     provider,
+			}); err != nil {
+				return err
+			}
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		after, err := getServiceAccountAuditState(repo, serviceAccountID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			sas.ctx, repo, models.AuditActions.SyncGroupRoles,
+			models.AuditTargetTypes.ServiceAccount, serviceAccountID, before, after,
+		)
 	})
 }
 
+// Delete removes serviceAccountID along with its base role, role bindings,
+// access keys, tokens and open permission requests; closed requests are
+// kept, without their requester. Service accounts backing a service can't
+// be deleted
+func (sas serviceAccounts) Delete(serviceAccountID string) error {
+	return sas.repo.WithPGTx(sas.ctx, func(repo *repositories.All) error {
+		return deleteServiceAccount(sas.ctx, repo, serviceAccountID)
+	})
+}
+
+func deleteServiceAccount(
+	ctx context.Context, repo *repositories.All, serviceAccountID string,
+) error {
+	before, err := getServiceAccountAuditState(repo, serviceAccountID)
+	if err != nil {
+		return err
+	}
+	sa, err := repo.ServiceAccounts.Get(serviceAccountID)
+	if err != nil {
+		return err
+	}
+	backed, err := repo.Services.ForServiceAccount(serviceAccountID)
+	if err != nil {
+		return err
+	}
+	if len(backed) > 0 {
+		return errors.NewServiceAccountBacksServiceError(
+			serviceAccountID, backed[0].ID,
+		)
+	}
+	if err := repo.PermissionsRequests.DeleteOpenForServiceAccount(
+		serviceAccountID,
+	); err != nil {
+		return err
+	}
+	if sa.Email != "" {
+		if err := repo.Tokens.DeleteForEmail(sa.Email); err != nil {
+			return err
+		}
+	}
+	if err := repo.ServiceAccounts.Delete(serviceAccountID); err != nil {
+		return err
+	}
+	if err := repo.Roles.Delete(sa.BaseRoleID); err != nil {
+		return err
+	}
+	return recordAuditEvent(
+		ctx, repo, models.AuditActions.DeleteServiceAccount,
+		models.AuditTargetTypes.ServiceAccount, serviceAccountID, before, nil,
+	)
+}
+
 // GetWithNested returns a service account by id with permissions and roles
 func (sas serviceAccounts) GetWithNested(
 	serviceAccountID string,
@@ -183,6 +391,7 @@ func (sas serviceAccounts) GetWithNested(
 		return nil, err
 	}
 	permissionsAliases := map[string]string{}
+	permissionsTimeBounds := map[string]models.TimeBound{}
 	permissions := make([]string, len(pSl))
 	for i := range pSl {
 		str := pSl[i].String()
@@ -190,28 +399,50 @@ func (sas serviceAccounts) GetWithNested(
 		if pSl[i].Alias != "" {
 			permissionsAliases[str] = pSl[i].Alias
 		}
+		if pSl[i].Bounded() {
+			permissionsTimeBounds[str] = pSl[i].TimeBound
+		}
 	}
 	roles, err := sas.repo.Roles.ForServiceAccountID(serviceAccountID)
 	if err != nil {
 		return nil, err
 	}
+	rbs, err := sas.repo.Roles.BindingsForServiceAccountID(serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	rolesTimeBounds := map[string]models.TimeBound{}
+	for _, rb := range rbs {
+		if rb.Bounded() {
+			rolesTimeBounds[rb.RoleID] = rb.TimeBound
+		}
+	}
 	return &ServiceAccountWithNested{
-		ID:                 sa.ID,
-		Name:               sa.Name,
-		Email:              sa.Email,
-		Picture:            sa.Picture,
-		Roles:              roles,
-		AuthenticationType: sa.AuthenticationType,
-		PermissionsStrings: permissions,
-		PermissionsAliases: permissionsAliases,
+		ID:                    sa.ID,
+		Name:                  sa.Name,
+		Email:                 sa.Email,
+		Picture:               sa.Picture,
+		Roles:                 roles,
+		RolesTimeBounds:       rolesTimeBounds,
+		AuthenticationType:    sa.AuthenticationType,
+		Active:                sa.Active,
+		PermissionsStrings:    permissions,
+		PermissionsAliases:    permissionsAliases,
+		PermissionsTimeBounds: permissionsTimeBounds,
 	}, nil
 }
 
+// HasAllOwnerRolesPermissions checks if saID owns every permission of
+// rolesIDs, including the ones from roles they include
 func (sas serviceAccounts) HasAllOwnerRolesPermissions(
 	saID string, rolesIDs []string,
 ) (bool, error) {
+	included, err := sas.repo.Roles.IncludedRolesIDs(rolesIDs)
+	if err != nil {
+		return false, err
+	}
 	ps := []models.Permission{}
-	for _, roleID := range rolesIDs {
+	for _, roleID := range append(rolesIDs, included...) {
 		rps, err := sas.repo.Permissions.ForRole(roleID)
 		if err != nil {
 			return false, err
@@ -223,7 +454,17 @@ func (sas serviceAccounts) HasAllOwnerRolesPermissions(
 
 func (sas serviceAccounts) Create(sa *models.ServiceAccount) error {
 	return sas.repo.WithPGTx(sas.ctx, func(repo *repositories.All) error {
-		return createServiceAccount(sa, repo)
+		if err := createServiceAccount(sa, repo); err != nil {
+			return err
+		}
+		after, err := getServiceAccountAuditState(repo, sa.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			sas.ctx, repo, models.AuditActions.CreateServiceAccount,
+			models.AuditTargetTypes.ServiceAccount, sa.ID, nil, after,
+		)
 	})
 }
 
@@ -242,6 +483,21 @@ func createServiceAccount(
 	if err := repo.ServiceAccounts.Create(sa); err != nil {
 		return err
 	}
+	if sa.KeyID != "" {
+		ak := &models.AccessKey{
+			ServiceAccountID: sa.ID,
+			Label:            "default",
+			KeyID:            sa.KeyID,
+			KeySecret:        sa.KeySecret,
+			Active:           true,
+		}
+		if err := ak.HashKeySecret(); err != nil {
+			return err
+		}
+		if err := repo.AccessKeys.Create(ak); err != nil {
+			return err
+		}
+	}
 	if err := repo.Roles.Bind(&models.RoleBinding{
 		RoleID:           r.ID,
 		ServiceAccountID: sa.ID,
@@ -381,6 +637,8 @@ func (sas *serviceAccounts) AuthenticateAccessToken(
 		}
 	} else if err != nil {
 		return nil, err
+	} else if !sa.Active {
+		return nil, errors.NewServiceAccountInactiveError(sa.ID)
 	} else if authResult.Picture != "" && authResult.Picture != sa.Picture {
 		sa.Picture = authResult.Picture
 		if err = sas.repo.ServiceAccounts.Update(sa); err != nil {
@@ -394,14 +652,42 @@ func (sas *serviceAccounts) AuthenticateAccessToken(
 	}, nil
 }
 
-// AuthenticateKeyPair verifies if key pair is valid
+// unknownKeyIDAccessKey has a key secret hash to check secrets of unknown
+// key ids against
+var unknownKeyIDAccessKey = func() *models.AccessKey {
+	ak, err := models.BuildAccessKey("", "unknown")
+	if err != nil {
+		panic(err)
+	}
+	return ak
+}()
+
+// AuthenticateKeyPair verifies if key pair is one of the usable access keys
+// of a service account
 func (sas *serviceAccounts) AuthenticateKeyPair(
 	keyID, keySecret string,
 ) (*models.AccessKeyPairAuth, error) {
-	sa, err := sas.repo.ServiceAccounts.ForKeyPair(keyID, keySecret)
+	ak, err := sas.repo.AccessKeys.ForKeyID(keyID)
+	if err != nil {
+		if _, ok := err.(*errors.EntityNotFoundError); ok {
+			// as slow as a wrong secret, not to tell key ids apart
+			unknownKeyIDAccessKey.KeySecretMatches(keySecret)
+		}
+		return nil, err
+	}
+	if !ak.KeySecretMatches(keySecret) || !ak.Usable(time.Now()) {
+		return nil, errors.NewEntityNotFoundError(models.AccessKey{}, keyID)
+	}
+	sa, err := sas.repo.ServiceAccounts.Get(ak.ServiceAccountID)
 	if err != nil {
 		return nil, err
 	}
+	if !sa.Active {
+		return nil, errors.NewServiceAccountInactiveError(sa.ID)
+	}
+	if err := sas.repo.AccessKeys.Touch(ak.ID); err != nil {
+		return nil, err
+	}
 	return &models.AccessKeyPairAuth{
 		ServiceAccountID: sa.ID,
 		Name:             sa.Name,
@@ -417,7 +703,11 @@ func (sas serviceAccounts) HasPermissionString(
 	if err != nil {
 		return false, err
 	}
-	return sas.repo.ServiceAccounts.HasPermission(serviceAccountID, ps)
+	has, err := sas.HasPermissions(serviceAccountID, []models.Permission{ps})
+	if err != nil {
+		return false, err
+	}
+	return has[0], nil
 }
 
 func (sas serviceAccounts) HasAllOwnerPermissions(
@@ -503,7 +793,17 @@ func serviceAccountGetPermissions(
 func (sas serviceAccounts) CreatePermission(
 	serviceAccountID string, permission *models.Permission,
 ) error {
-	return createPermissionForServiceAccount(sas.repo, serviceAccountID, permission)
+	return sas.repo.WithPGTx(sas.ctx, func(repo *repositories.All) error {
+		if err := createPermissionForServiceAccount(
+			repo, serviceAccountID, permission,
+		); err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			sas.ctx, repo, models.AuditActions.CreatePermission,
+			models.AuditTargetTypes.Permission, permission.ID, nil, permission,
+		)
+	})
 }
 
 func createPermissionForServiceAccount(
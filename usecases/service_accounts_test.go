@@ -3,9 +3,12 @@
 package usecases_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/go-pg/pg"
 	"github.com/topfreegames/Will.IAM/models"
 	"github.com/topfreegames/Will.IAM/repositories"
 	helpers "github.com/topfreegames/Will.IAM/testing"
@@ -146,6 +149,49 @@ var saHasPermissionTestCases = []saHasPermissionTestCase{
 		permission:                "Service1::RL::Do1::x::*",
 		want:                      false,
 	},
+	// Deny
+	saHasPermissionTestCase{
+		name:                      "Deny containing requested hierarchy",
+		serviceAccountPermissions: []string{"Service1::RO::*::*", "Service1::DENY::Do1::x::*"},
+		permission:                "Service1::RL::Do1::x::y",
+		want:                      false,
+	},
+	saHasPermissionTestCase{
+		name:                      "Deny contained by requested hierarchy",
+		serviceAccountPermissions: []string{"Service1::RO::*::*", "Service1::DENY::Do1::x::y"},
+		permission:                "Service1::RO::Do1::x::*",
+		want:                      false,
+	},
+	saHasPermissionTestCase{
+		name:                      "Deny with * action",
+		serviceAccountPermissions: []string{"Service1::RL::Do1::*", "Service1::DENY::*::x::*"},
+		permission:                "Service1::RL::Do1::x::y",
+		want:                      false,
+	},
+	saHasPermissionTestCase{
+		name:                      "Deny different hierarchy",
+		serviceAccountPermissions: []string{"Service1::RO::*::*", "Service1::DENY::Do1::x::*"},
+		permission:                "Service1::RL::Do1::y::z",
+		want:                      true,
+	},
+	saHasPermissionTestCase{
+		name:                      "Deny different action",
+		serviceAccountPermissions: []string{"Service1::RO::*::*", "Service1::DENY::Do2::x::*"},
+		permission:                "Service1::RL::Do1::x::y",
+		want:                      true,
+	},
+	saHasPermissionTestCase{
+		name:                      "Deny hierarchy with LIKE wildcard",
+		serviceAccountPermissions: []string{"Service1::RO::*::*", "Service1::DENY::Do1::x_y"},
+		permission:                "Service1::RL::Do1::xzy",
+		want:                      true,
+	},
+	saHasPermissionTestCase{
+		name:                      "Deny alone",
+		serviceAccountPermissions: []string{"Service1::DENY::Do1::*"},
+		permission:                "Service1::DENY::Do1::*",
+		want:                      false,
+	},
 }
 
 func TestServiceAccountsHasPermissionWhenPermissionsOnBaseRole(t *testing.T) {
@@ -215,6 +261,97 @@ func TestServiceAccountsHasPermissionWhenPermissionsOnNonBaseRole(t *testing.T)
 	}
 }
 
+func TestServiceAccountsHasPermissionWithTimeBounds(t *testing.T) {
+	hourAgo := pg.NullTime{Time: time.Now().Add(-time.Hour)}
+	inAnHour := pg.NullTime{Time: time.Now().Add(time.Hour)}
+	testCases := []struct {
+		name                 string
+		permissionTimeBound  models.TimeBound
+		roleBindingTimeBound models.TimeBound
+		want                 bool
+	}{
+		{
+			name: "should have permission when both are unbounded",
+			want: true,
+		},
+		{
+			name: "should have permission when both are in effect",
+			permissionTimeBound: models.TimeBound{
+				NotBefore: hourAgo, ExpiresAt: inAnHour,
+			},
+			roleBindingTimeBound: models.TimeBound{ExpiresAt: inAnHour},
+			want:                 true,
+		},
+		{
+			name:                "should not have permission when it expired",
+			permissionTimeBound: models.TimeBound{ExpiresAt: hourAgo},
+			want:                false,
+		},
+		{
+			name:                "should not have permission before it starts",
+			permissionTimeBound: models.TimeBound{NotBefore: inAnHour},
+			want:                false,
+		},
+		{
+			name:                 "should not have permission when role binding expired",
+			roleBindingTimeBound: models.TimeBound{ExpiresAt: hourAgo},
+			want:                 false,
+		},
+		{
+			name:                 "should not have permission before role binding starts",
+			roleBindingTimeBound: models.TimeBound{NotBefore: inAnHour},
+			want:                 false,
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			helpers.CleanupPG(t)
+
+			saUC := helpers.GetServiceAccountsUseCase(t)
+			sa1 := &models.ServiceAccount{
+				Name:  "sa1",
+				Email: "test@domain.com",
+			}
+			if err := saUC.Create(sa1); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			p, err := models.BuildPermission("Service::RL::Do::x::*")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			p.TimeBound = testCase.permissionTimeBound
+			rl1 := &usecases.RoleWithNested{
+				Name:               "role1",
+				Permissions:        []models.Permission{p},
+				ServiceAccountsIDs: []string{sa1.ID},
+				ServiceAccountsTimeBounds: map[string]models.TimeBound{
+					sa1.ID: testCase.roleBindingTimeBound,
+				},
+			}
+			rsUC := helpers.GetRolesUseCase(t)
+			if err := rsUC.Create(rl1); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			has, err := saUC.HasPermissionString(sa1.ID, "Service::RL::Do::x::y")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if has != testCase.want {
+				t.Fatalf("Expected has to be %v. Got %v", testCase.want, has)
+			}
+			hasSl, err := saUC.HasPermissionsStrings(
+				sa1.ID, []string{"Service::RL::Do::x::y"},
+			)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if hasSl[0] != testCase.want {
+				t.Fatalf("Expected hasSl[0] to be %v. Got %v", testCase.want, hasSl[0])
+			}
+		})
+	}
+}
+
 type saListWithPermissionTestCase struct {
 	name                      string
 	serviceAccountPermissions [][]string
@@ -309,7 +446,7 @@ func TestServiceAccountsListWithPermissionWhenPermissionOnBaseRole(t *testing.T)
 			}
 			for j := range list {
 				if list[j].Name != testCase.want[j] {
-					t.Errorf("Expected list[%d] to be %s. Got %s", j, list[j], testCase.want[j])
+					t.Errorf("Expected list[%d] to be %s. Got %s", j, testCase.want[j], list[j].Name)
 				}
 			}
 		})
@@ -366,9 +503,126 @@ func TestServiceAccountsListWithPermissionWhenPermissionOnNonBaseRole(t *testing
 			}
 			for j := range list {
 				if list[j].Name != testCase.want[j] {
-					t.Errorf("Expected list[%d] to be %s. Got %s.", j, list[j], testCase.want[j])
+					t.Errorf("Expected list[%d] to be %s. Got %s.", j, testCase.want[j], list[j].Name)
 				}
 			}
 		})
 	}
 }
+
+func TestServiceAccountsSyncGroupRoles(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	rsUC := helpers.GetRolesUseCase(t)
+	sa := &models.ServiceAccount{Name: "some name", Email: "test@domain.com"}
+	if err := saUC.Create(sa); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	roleIDs := map[string]string{}
+	for _, name := range []string{"developers", "admins", "manual", "other"} {
+		rwn := &usecases.RoleWithNested{Name: name}
+		if err := rsUC.Create(rwn); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		roleIDs[name] = rwn.ID
+	}
+	repo := helpers.GetRepo(t)
+	for _, m := range []models.IdPGroupRoleMapping{
+		{Provider: "ldap", GroupName: "CN=Developers", RoleID: roleIDs["developers"]},
+		{Provider: "ldap", GroupName: "cn=admins", RoleID: roleIDs["admins"]},
+		{Provider: "ldap", GroupName: "cn=admins", RoleID: roleIDs["manual"]},
+		{Provider: "google", GroupName: "cn=admins", RoleID: roleIDs["other"]},
+	} {
+		if err := repo.IdPGroupRoleMappings.Create(&m); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := repo.Roles.Bind(&models.RoleBinding{
+		RoleID: roleIDs["manual"], ServiceAccountID: sa.ID,
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.Roles.Bind(&models.RoleBinding{
+		RoleID: roleIDs["other"], ServiceAccountID: sa.ID, FromProvider: "google",
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		groups []string
+		want   map[string]bool
+	}{
+		{[]string{"cn=developers", "CN=Admins"}, map[string]bool{
+			"developers": true, "admins": true, "manual": true, "other": true,
+		}},
+		{[]string{"cn=developers", "cn=other"}, map[string]bool{
+			"developers": true, "admins": false, "manual": true, "other": true,
+		}},
+		{[]string{}, map[string]bool{
+			"developers": false, "admins": false, "manual": true, "other": true,
+		}},
+	}
+	for _, tt := range testCases {
+		if err := saUC.SyncGroupRoles(sa.ID, "ldap", tt.groups); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rbs, err := repo.Roles.BindingsForServiceAccountID(sa.ID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		bound := map[string]bool{}
+		for _, rb := range rbs {
+			bound[rb.RoleID] = true
+			if rb.RoleID == roleIDs["manual"] && rb.FromProvider != "" {
+				t.Errorf("Expected manual binding to stay manual for groups %v", tt.groups)
+			}
+		}
+		for name, want := range tt.want {
+			if bound[roleIDs[name]] != want {
+				t.Errorf("Expected bound to %s %t for groups %v", name, want, tt.groups)
+			}
+		}
+	}
+}
+
+func TestServiceAccountsSyncGroupRolesAfterMappingDeleted(t *testing.T) {
+	helpers.CleanupPG(t)
+	saUC := helpers.GetServiceAccountsUseCase(t)
+	sa := &models.ServiceAccount{Name: "some name", Email: "test@domain.com"}
+	if err := saUC.Create(sa); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rwn := &usecases.RoleWithNested{Name: "developers"}
+	if err := helpers.GetRolesUseCase(t).Create(rwn); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msUC := usecases.NewIdPGroupRoleMappings(helpers.GetRepo(t)).
+		WithContext(context.Background())
+	m := &models.IdPGroupRoleMapping{Provider: "ldap", GroupName: "cn=developers"}
+	if err := msUC.Create(rwn.ID, m); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	repo := helpers.GetRepo(t)
+	groups := []string{"cn=developers"}
+	for _, want := range []bool{true, false} {
+		if err := saUC.SyncGroupRoles(sa.ID, "ldap", groups); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rbs, err := repo.Roles.BindingsForServiceAccountID(sa.ID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		bound := false
+		for _, rb := range rbs {
+			bound = bound || rb.RoleID == rwn.ID
+		}
+		if bound != want {
+			t.Errorf("Expected bound to developers %t", want)
+		}
+		if want {
+			if err := msUC.Delete(rwn.ID, m.ID); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	}
+}
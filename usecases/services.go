@@ -57,7 +57,10 @@ func (ss services) Create(service *models.Service) error {
 		repo.Permissions.Create(
 			buildFullAccessPermissionForRoleID(creatorSA.BaseRoleID),
 		)
-		return nil
+		return recordAuditEvent(
+			ss.ctx, repo, models.AuditActions.CreateService,
+			models.AuditTargetTypes.Service, service.ID, nil, service,
+		)
 	})
 }
 
@@ -70,7 +73,23 @@ func (ss services) Get(id string) (*models.Service, error) {
 }
 
 func (ss services) Update(service *models.Service) error {
-	return ss.repo.Services.Update(service)
+	return ss.repo.WithPGTx(ss.ctx, func(repo *repositories.All) error {
+		before, err := repo.Services.Get(service.ID)
+		if err != nil {
+			return err
+		}
+		if err := repo.Services.Update(service); err != nil {
+			return err
+		}
+		after, err := repo.Services.Get(service.ID)
+		if err != nil {
+			return err
+		}
+		return recordAuditEvent(
+			ss.ctx, repo, models.AuditActions.UpdateService,
+			models.AuditTargetTypes.Service, service.ID, before, after,
+		)
+	})
 }
 
 // NewServices services' ctor
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/topfreegames/Will.IAM/usecases"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+func loadDefaultConfigWorker(config *viper.Viper) {
+	config.SetDefault("worker.tick", "10s")
+	config.SetDefault("worker.jobs.purgeTokens.enabled", true)
+	config.SetDefault("worker.jobs.purgeTokens.interval", "1h")
+	config.SetDefault("worker.jobs.purgeTokens.retention", "24h")
+	config.SetDefault("worker.jobs.expireGrants.enabled", true)
+	config.SetDefault("worker.jobs.expireGrants.interval", "1m")
+	config.SetDefault("worker.jobs.expirePermissionsRequests.enabled", true)
+	config.SetDefault("worker.jobs.expirePermissionsRequests.interval", "1h")
+	config.SetDefault("worker.jobs.metrics.enabled", true)
+	config.SetDefault("worker.jobs.metrics.interval", "1m")
+}
+
+// GetTick returns how often the worker checks for due jobs
+func GetTick(config *viper.Viper) time.Duration {
+	loadDefaultConfigWorker(config)
+	return config.GetDuration("worker.tick")
+}
+
+// Jobs returns the jobs enabled in config
+// purgeTokens: removes tokens expired for longer than retention
+// expireGrants: removes time-bound permissions and role bindings that ended
+// expirePermissionsRequests: expires requests left open for longer than
+// permissionsRequests.ttl
+// metrics: reports Will.IAM stats as gauges
+func Jobs(
+	config *viper.Viper, hk usecases.Housekeeping, mr middleware.MetricsReporter,
+) []Job {
+	loadDefaultConfigWorker(config)
+	all := []Job{
+		{Name: "purgeTokens", Run: func(ctx context.Context) error {
+			_, err := hk.WithContext(ctx).PurgeTokens(
+				config.GetDuration("worker.jobs.purgeTokens.retention"),
+			)
+			return err
+		}},
+		{Name: "expireGrants", Run: func(ctx context.Context) error {
+			_, err := hk.WithContext(ctx).ExpireGrants()
+			return err
+		}},
+		{Name: "expirePermissionsRequests", Run: func(ctx context.Context) error {
+			prsConfig, err := usecases.GetPermissionsRequestsConfig(config)
+			if err != nil || prsConfig.TTL <= 0 {
+				return err
+			}
+			_, err = hk.WithContext(ctx).ExpirePermissionsRequests(prsConfig.TTL)
+			return err
+		}},
+		{Name: "metrics", Run: func(ctx context.Context) error {
+			s, err := hk.WithContext(ctx).Stats()
+			if err != nil {
+				return err
+			}
+			mr.Gauge("service_accounts", float64(s.ServiceAccounts))
+			mr.Gauge("roles", float64(s.Roles))
+			mr.Gauge("open_permissions_requests", float64(s.OpenPermissionsRequests))
+			return nil
+		}},
+	}
+	jobs := []Job{}
+	for _, j := range all {
+		prefix := fmt.Sprintf("worker.jobs.%s", j.Name)
+		if !config.GetBool(prefix + ".enabled") {
+			continue
+		}
+		j.Interval = config.GetDuration(prefix + ".interval")
+		j.Run = withAuditActor(j.Name, j.Run)
+		jobs = append(jobs, j)
+	}
+	return jobs
+}
+
+// withAuditActor makes changes done by job run be audited as the worker's
+func withAuditActor(
+	job string, run func(context.Context) error,
+) func(context.Context) error {
+	return func(ctx context.Context) error {
+		return run(usecases.WithAuditActor(ctx, usecases.AuditActor{
+			RequestID: "worker:" + job,
+		}))
+	}
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/topfreegames/extensions/middleware"
+)
+
+// Metrics reported by the worker
+var Metrics = struct {
+	JobDuration string
+	JobErrors   string
+	Leader      string
+}{
+	JobDuration: "worker_job_duration",
+	JobErrors:   "worker_job_errors",
+	Leader:      "worker_leader",
+}
+
+// Job is a task the worker runs every Interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(context.Context) error
+}
+
+// Elector elects a single worker, among all running, to run jobs; Elect is
+// called on every tick and tells if this one is the leader
+type Elector interface {
+	Elect() (bool, error)
+	Resign() error
+}
+
+// Worker runs jobs on schedule while it's the elected leader
+type Worker struct {
+	elector         Elector
+	tick            time.Duration
+	jobs            []Job
+	lastRuns        map[string]time.Time
+	logger          logrus.FieldLogger
+	metricsReporter middleware.MetricsReporter
+}
+
+// New Worker ctor; jobs are checked for being due every tick
+func New(
+	elector Elector, tick time.Duration, logger logrus.FieldLogger,
+	mr middleware.MetricsReporter,
+) *Worker {
+	return &Worker{
+		elector:         elector,
+		tick:            tick,
+		lastRuns:        map[string]time.Time{},
+		logger:          logger,
+		metricsReporter: mr,
+	}
+}
+
+// Register schedules j, which is first run on the next tick
+func (w *Worker) Register(j Job) {
+	w.jobs = append(w.jobs, j)
+}
+
+// Run runs jobs on schedule until ctx is done, then resigns
+func (w *Worker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+	for {
+		w.Tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return w.elector.Resign()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick runs jobs due at now, if this worker is the leader
+func (w *Worker) Tick(ctx context.Context, now time.Time) {
+	leader, err := w.elector.Elect()
+	if err != nil {
+		w.logger.WithError(err).Error("worker election failed")
+	}
+	w.gauge(Metrics.Leader, leader)
+	if !leader {
+		// whoever leads next runs everything when its own schedule says so
+		w.lastRuns = map[string]time.Time{}
+		return
+	}
+	for _, j := range w.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if last, ok := w.lastRuns[j.Name]; ok && now.Sub(last) < j.Interval {
+			continue
+		}
+		w.lastRuns[j.Name] = now
+		w.run(ctx, j)
+	}
+}
+
+func (w *Worker) run(ctx context.Context, j Job) {
+	l := w.logger.WithField("job", j.Name)
+	tag := "job:" + j.Name
+	start := time.Now()
+	err := j.Run(ctx)
+	if w.metricsReporter != nil {
+		w.metricsReporter.Timing(Metrics.JobDuration, time.Since(start), tag)
+	}
+	if err != nil {
+		l.WithError(err).Error("job failed")
+		if w.metricsReporter != nil {
+			w.metricsReporter.Increment(Metrics.JobErrors, tag)
+		}
+		return
+	}
+	l.Debug("job done")
+}
+
+func (w *Worker) gauge(metric string, b bool) {
+	if w.metricsReporter == nil {
+		return
+	}
+	var v float64
+	if b {
+		v = 1
+	}
+	w.metricsReporter.Gauge(metric, v)
+}
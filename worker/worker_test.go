@@ -0,0 +1,76 @@
+// +build unit
+
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/topfreegames/Will.IAM/worker"
+)
+
+type electorMock struct {
+	leader bool
+	err    error
+}
+
+func (e *electorMock) Elect() (bool, error) {
+	return e.leader, e.err
+}
+
+func (e *electorMock) Resign() error {
+	e.leader = false
+	return nil
+}
+
+func TestWorkerTick(t *testing.T) {
+	runs := map[string]int{}
+	job := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			runs[name]++
+			return err
+		}
+	}
+	elector := &electorMock{}
+	w := worker.New(elector, time.Second, logrus.New(), nil)
+	w.Register(worker.Job{Name: "often", Interval: time.Minute, Run: job("often", nil)})
+	w.Register(worker.Job{Name: "seldom", Interval: time.Hour, Run: job("seldom", nil)})
+	w.Register(worker.Job{
+		Name: "failing", Interval: time.Minute, Run: job("failing", errors.New("failed")),
+	})
+
+	now := time.Now()
+	ctx := context.Background()
+	w.Tick(ctx, now)
+	if len(runs) != 0 {
+		t.Errorf("Expected no runs while not leader. Got %v", runs)
+	}
+
+	elector.leader = true
+	w.Tick(ctx, now)
+	w.Tick(ctx, now.Add(30*time.Second))
+	w.Tick(ctx, now.Add(time.Minute))
+	expected := map[string]int{"often": 2, "seldom": 1, "failing": 2}
+	for name, count := range expected {
+		if runs[name] != count {
+			t.Errorf("Expected %s to run %d times. Got %d", name, count, runs[name])
+		}
+	}
+
+	elector.err = errors.New("connection lost")
+	elector.leader = false
+	w.Tick(ctx, now.Add(2*time.Minute))
+	if runs["often"] != 2 {
+		t.Errorf("Expected no runs after losing leadership. Got %v", runs)
+	}
+
+	elector.err = nil
+	elector.leader = true
+	w.Tick(ctx, now.Add(2*time.Minute))
+	if runs["seldom"] != 2 {
+		t.Errorf("Expected jobs to run once leader again. Got %v", runs)
+	}
+}